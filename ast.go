@@ -1,7 +1,7 @@
 package lox
 
-//go:generate go run ./cmd/gen_ast -spec ./cmd/gen_ast/expr.spec -dest expr.go -extensions typename
-//go:generate go run ./cmd/gen_ast -spec ./cmd/gen_ast/stmt.spec -dest stmt.go
+//go:generate go run ./cmd/gen_ast -spec ./cmd/gen_ast/expr.spec -dest expr.go -extensions typename,span
+//go:generate go run ./cmd/gen_ast -spec ./cmd/gen_ast/stmt.spec -dest stmt.go -extensions span
 //go:generate go run ./cmd/gen_ast -spec ./cmd/gen_ast/type.spec -dest type.go
 
 // ---- String
@@ -17,6 +17,11 @@ func (e *FunctionExpr) String() string   { return PrintExpr(e) }
 func (e *GetExpr) String() string        { return PrintExpr(e) }
 func (e *SetExpr) String() string        { return PrintExpr(e) }
 func (e *ThisExpr) String() string       { return PrintExpr(e) }
+func (e *SuperExpr) String() string      { return PrintExpr(e) }
+func (e *ListExpr) String() string       { return PrintExpr(e) }
+func (e *IndexExpr) String() string      { return PrintExpr(e) }
+func (e *SetIndexExpr) String() string   { return PrintExpr(e) }
+func (e *MapExpr) String() string        { return PrintExpr(e) }
 
 func (s ExpressionStmt) String() string { return PrintStmts(s) }
 func (s PrintStmt) String() string      { return PrintStmts(s) }
@@ -35,4 +40,8 @@ func (t BoolType) String() string     { return PrintType(t) }
 func (t NumberType) String() string   { return PrintType(t) }
 func (t StringType) String() string   { return PrintType(t) }
 func (t FunctionType) String() string { return PrintType(t) }
+func (t ListType) String() string     { return PrintType(t) }
+func (t MapType) String() string      { return PrintType(t) }
 func (t *RefType) String() string     { return PrintType(t) }
+func (t *ClassType) String() string   { return PrintType(t) }
+func (t InstanceType) String() string { return PrintType(t) }
@@ -104,6 +104,32 @@ func (p *astPrinter) VisitThisExpr(expr *ThisExpr) {
 	p.str.WriteString("this")
 }
 
+func (p *astPrinter) VisitMapExpr(expr *MapExpr) {
+	parts := []any{"map"}
+	for i, key := range expr.Keys {
+		parts = append(parts, key, expr.Values[i])
+	}
+	p.parenthesize(multiLine, parts...)
+}
+
+func (p *astPrinter) VisitSuperExpr(expr *SuperExpr) {
+	p.parenthesize(singleLine, "super", expr.Method)
+}
+
+func (p *astPrinter) VisitListExpr(expr *ListExpr) {
+	parts := []any{"list"}
+	parts = append(parts, moveArray[Expr](expr.Elements...)...)
+	p.parenthesize(multiLine, parts...)
+}
+
+func (p *astPrinter) VisitIndexExpr(expr *IndexExpr) {
+	p.parenthesize(singleLine, "index", expr.Object, expr.Index)
+}
+
+func (p *astPrinter) VisitSetIndexExpr(expr *SetIndexExpr) {
+	p.parenthesize(singleLine, "setindex", expr.Object, expr.Index, expr.Value)
+}
+
 // ---- Stmt
 
 func (p *astPrinter) VisitExpressionStmt(stmt ExpressionStmt) {
@@ -163,7 +189,43 @@ func (p *astPrinter) VisitReturnStmt(stmt ReturnStmt) {
 }
 
 func (p *astPrinter) VisitClassStmt(stmt ClassStmt) {
-	panic("lox.(*ASTPrinter).visitClassStmt is not implemented")
+	parts := []any{"class", stmt.Name}
+	if stmt.Superclass != nil {
+		parts = append(parts, "<", stmt.Superclass)
+	}
+	for _, decl := range stmt.StaticVars {
+		parts = append(parts, staticMember{decl})
+	}
+	for _, method := range stmt.StaticMethods {
+		parts = append(parts, staticMember{method})
+	}
+	parts = append(parts, moveArray[VarStmt](stmt.Vars...)...)
+	parts = append(parts, moveArray[FunctionStmt](stmt.Methods...)...)
+	p.parenthesize(multiLine, parts...)
+}
+
+// staticMember wraps a class member, to be printed within a 'static' form.
+type staticMember struct {
+	member Stmt
+}
+
+func (p *astPrinter) VisitImportStmt(stmt ImportStmt) {
+	p.parenthesize(singleLine, "import", stmt.Path, stmt.Name)
+}
+
+func (p *astPrinter) VisitThrowStmt(stmt ThrowStmt) {
+	p.parenthesize(singleLine, "throw", stmt.Value)
+}
+
+func (p *astPrinter) VisitTryStmt(stmt TryStmt) {
+	parts := []any{"try", BlockStmt{Statements: stmt.Body}}
+	if stmt.CatchName != nil {
+		parts = append(parts, "catch", *stmt.CatchName, BlockStmt{Statements: stmt.Catch})
+	}
+	if stmt.Finally != nil {
+		parts = append(parts, "finally", BlockStmt{Statements: stmt.Finally})
+	}
+	p.parenthesize(multiLine, parts...)
 }
 
 // ---- Type
@@ -188,9 +250,28 @@ func (p *astPrinter) VisitFunctionType(t FunctionType) {
 	p.parenthesize(singleLine, "Fun", t.Params, t.Return)
 }
 
+func (p *astPrinter) VisitListType(t ListType) {
+	p.parenthesize(singleLine, "List", t.Element)
+}
+
+func (p *astPrinter) VisitMapType(t MapType) {
+	p.parenthesize(singleLine, "Map", t.Key, t.Value)
+}
+
+func (p *astPrinter) VisitClassType(t *ClassType) {
+	p.parenthesize(singleLine, "Class", t.Name)
+}
+
+func (p *astPrinter) VisitInstanceType(t InstanceType) {
+	p.str.WriteString(t.Class.Name.Lexeme)
+}
+
 func (p *astPrinter) VisitRefType(x *RefType) {
 	if x.Value == nil {
 		fmt.Fprintf(p.str, "_%d", x.ID)
+		for _, attr := range x.Attrs {
+			fmt.Fprintf(p.str, "{%s}", attr)
+		}
 	} else {
 		p.str.WriteRune('&')
 		p.printStuff(x.Value)
@@ -247,6 +328,8 @@ func (p *astPrinter) printStuff(x any) {
 	case []Type:
 		parts := moveArray[Type](stuff...)
 		p.parenthesize(singleLine, parts...)
+	case staticMember:
+		p.parenthesize(singleLine, "static", stuff.member)
 	case Token:
 		p.str.WriteString(stuff.Lexeme)
 	case string:
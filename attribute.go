@@ -0,0 +1,11 @@
+package lox
+
+// Attribute is data attached to an unbound RefType, like a constraint on the types it may be
+// bound to. Attributes are interpreted by the type checker, that verifies them when their
+// ref is bound.
+type Attribute interface {
+	// MapTypes returns a copy of the attribute with each of its types replaced by f's result.
+	MapTypes(f func(Type) Type) Attribute
+	// String returns the attribute as it's printed next to its ref.
+	String() string
+}
@@ -2,17 +2,63 @@ package lox
 
 import (
 	"fmt"
-	"math/rand"
 	"time"
 )
 
-var builtin = NewEnvironment(dynamicEnvironment)
+var builtins = []struct {
+	name string
+	fn   Callable
+}{
+	{"clock", clockFunc{}},
+	{"type", typeFunc{}},
+	{"random", randomFunc{}},
+	{"randomSeed", randomSeedFunc{}},
+	{"len", lenFunc{}},
+	{"keys", keysFunc{}},
+}
+
+// BuiltinNames returns the names of all builtins, that are available by default in
+// every interpreter.
+func BuiltinNames() []string {
+	names := make([]string, len(builtins))
+	for i, b := range builtins {
+		names[i] = b.name
+	}
+	return names
+}
+
+// SystemClock returns the current time in seconds, with microsecond precision.
+func SystemClock() float64 {
+	return float64(time.Now().UnixMicro()) / 1e6
+}
+
+// FakeClock returns a clock for deterministic runs, that starts at zero and advances by
+// one millisecond at each call.
+func FakeClock() func() float64 {
+	var ticks int
+	return func() float64 {
+		t := float64(ticks) / 1e3
+		ticks++
+		return t
+	}
+}
 
-func init() {
-	builtin.Define("clock", clockFunc{})
-	builtin.Define("type", typeFunc{})
-	builtin.Define("random", randomFunc{})
-	builtin.Define("randomSeed", randomSeedFunc{})
+// newBuiltinEnvironment returns an environment with the builtins in names, or with all
+// builtins if names is nil. Unknown names are ignored.
+func newBuiltinEnvironment(names []string) *Environment {
+	env := NewEnvironment(dynamicEnvironment)
+	if names == nil {
+		names = BuiltinNames()
+	}
+	for _, name := range names {
+		for _, b := range builtins {
+			if b.name == name {
+				env.Define(name, b.fn)
+				break
+			}
+		}
+	}
+	return env
 }
 
 // ----
@@ -21,7 +67,7 @@ type clockFunc struct{}
 
 func (f clockFunc) Arity() int { return 0 }
 func (f clockFunc) Call(i *Interpreter, args []any) any {
-	return float64(time.Now().UnixMicro()) / 1e6
+	return i.clock()
 }
 func (f clockFunc) String() string { return "<native fn clock>" }
 
@@ -39,7 +85,7 @@ func (f typeFunc) Call(i *Interpreter, args []any) any {
 		return NumberType{}
 	case string:
 		return StringType{}
-	case instance:
+	case *instance:
 		return v.class
 	case class:
 		return v.meta
@@ -47,15 +93,14 @@ func (f typeFunc) Call(i *Interpreter, args []any) any {
 		return metaType{}
 	case metaType:
 		return metaType{}
+	case *list:
+		return ListType{Element: &RefType{ID: 1}}
+	case *dict:
+		return MapType{Key: &RefType{ID: 1}, Value: &RefType{ID: 2}}
 	case function:
-		params := make([]Type, v.Arity())
-		for i := 0; i < v.Arity(); i++ {
-			params[i] = &RefType{ID: i + 1}
-		}
-		return FunctionType{
-			Params: params,
-			Return: &RefType{ID: v.Arity() + 1},
-		}
+		return genericFunctionType(v.Arity())
+	case *native:
+		return genericFunctionType(v.Arity())
 	default:
 		if arg == nil {
 			return NilType{}
@@ -71,7 +116,7 @@ type randomFunc struct{}
 
 func (f randomFunc) Arity() int { return 0 }
 func (f randomFunc) Call(i *Interpreter, args []any) any {
-	return rand.Float64()
+	return i.rand.Float64()
 }
 func (f randomFunc) String() string { return "<native fn random>" }
 
@@ -86,7 +131,40 @@ func (f randomSeedFunc) Call(i *Interpreter, args []any) any {
 	if !ok {
 		panic(runtimeError{Token{}, fmt.Sprintf("unhandled randomSeed(%[1]v) (%[1]T)", arg)})
 	}
-	rand.Seed(int64(seed))
+	i.rand.Seed(int64(seed))
 	return nil
 }
 func (f randomSeedFunc) String() string { return "<native fn randomSeed>" }
+
+// ----
+
+type lenFunc struct{}
+
+func (f lenFunc) Arity() int { return 1 }
+func (f lenFunc) Call(i *Interpreter, args []any) any {
+	switch v := args[0].(type) {
+	case *list:
+		return float64(len(v.elements))
+	case *dict:
+		return float64(v.entries.Len())
+	case string:
+		return float64(len(v))
+	default:
+		panic(runtimeError{i.callToken(), fmt.Sprintf("unhandled len(%[1]v) (%[1]T)", v)})
+	}
+}
+func (f lenFunc) String() string { return "<native fn len>" }
+
+// ----
+
+type keysFunc struct{}
+
+func (f keysFunc) Arity() int { return 1 }
+func (f keysFunc) Call(i *Interpreter, args []any) any {
+	d, ok := args[0].(*dict)
+	if !ok {
+		panic(runtimeError{i.callToken(), fmt.Sprintf("unhandled keys(%[1]v) (%[1]T)", args[0])})
+	}
+	return d.keys()
+}
+func (f keysFunc) String() string { return "<native fn keys>" }
@@ -24,7 +24,7 @@ func (s objectState) get(obj object, name Token) any {
 	if ok {
 		return v
 	}
-	m, ok := s.behavior.methods[name.Lexeme]
+	m, ok := s.behavior.findMethod(name.Lexeme)
 	if ok {
 		return m.bind(obj)
 	}
@@ -37,6 +37,7 @@ func (s objectState) set(name Token, value any) {
 
 type objectBehavior struct {
 	methods map[string]function
+	parent  *objectBehavior
 }
 
 func newObjectBehavior() objectBehavior {
@@ -45,6 +46,16 @@ func newObjectBehavior() objectBehavior {
 	}
 }
 
+// findMethod looks up a method in this behavior, falling back to its ancestors.
+func (b objectBehavior) findMethod(name string) (function, bool) {
+	for curr := &b; curr != nil; curr = curr.parent {
+		if m, ok := curr.methods[name]; ok {
+			return m, true
+		}
+	}
+	return function{}, false
+}
+
 // ----
 
 type metaType struct{}
@@ -79,15 +90,27 @@ type fieldInitializer struct {
 type class struct {
 	meta             metaClass
 	static           objectState
+	superclass       *class
 	fieldInits       []fieldInitializer
 	instanceBehavior objectBehavior
 }
 
-func newClass(meta metaClass) class {
+func newClass(meta metaClass, superclass *class) class {
+	instanceBehavior := newObjectBehavior()
+	var fieldInits []fieldInitializer
+	if superclass != nil {
+		// Both instance and static methods are inherited, and field initializers
+		// from the superclass are run before the ones declared in this class.
+		meta.parent = &superclass.meta.objectBehavior
+		instanceBehavior.parent = &superclass.instanceBehavior
+		fieldInits = append(fieldInits, superclass.fieldInits...)
+	}
 	return class{
 		meta:             meta,
 		static:           newObjectState(meta.objectBehavior),
-		instanceBehavior: newObjectBehavior(),
+		superclass:       superclass,
+		fieldInits:       fieldInits,
+		instanceBehavior: instanceBehavior,
 	}
 }
 
@@ -104,7 +127,7 @@ func (cl class) set(name Token, value any) {
 }
 
 func (cl class) Arity() int {
-	if init, ok := cl.instanceBehavior.methods["init"]; ok {
+	if init, ok := cl.instanceBehavior.findMethod("init"); ok {
 		return init.Arity()
 	}
 	return 0
@@ -115,7 +138,7 @@ func (cl class) Call(i *Interpreter, args []any) any {
 	for _, fieldInit := range cl.fieldInits {
 		is.set(fieldInit.name, fieldInit.value)
 	}
-	if init, ok := cl.instanceBehavior.methods["init"]; ok {
+	if init, ok := cl.instanceBehavior.findMethod("init"); ok {
 		init.bind(is).Call(i, args)
 	}
 	return is
@@ -128,21 +151,22 @@ type instance struct {
 	state objectState
 }
 
-func newInstance(class class) instance {
-	return instance{
+// Instances are compared by identity, so they are always handled by reference.
+func newInstance(class class) *instance {
+	return &instance{
 		class: class,
 		state: newObjectState(class.instanceBehavior),
 	}
 }
 
-func (is instance) String() string {
+func (is *instance) String() string {
 	return fmt.Sprintf("<instance %s>", is.class.meta.name)
 }
 
-func (is instance) get(name Token) any {
+func (is *instance) get(name Token) any {
 	return is.state.get(is, name)
 }
 
-func (is instance) set(name Token, value any) {
+func (is *instance) set(name Token, value any) {
 	is.state.set(name, value)
 }
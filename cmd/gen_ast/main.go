@@ -56,6 +56,8 @@ package lox
 type {{.Name}} interface {
 	Accept(v {{.Name | lower}}Visitor)
     {{if .Extensions.typename}}TypeName() string{{end}}
+    {{if .Extensions.span}}Pos() Position
+    End() Position{{end}}
 }
 {{- end}}
 
@@ -73,6 +75,7 @@ type {{.Name|lower}}Visitor interface{
         {{range .Fields -}}
             {{.Name}} {{.Type}}
         {{end -}}
+        {{if $.Extensions.span}}Span Span{{end}}
     }
 
 {{end -}}
@@ -94,6 +97,15 @@ type {{.Name|lower}}Visitor interface{
     {{end -}}
 {{end}}
 {{- end}}
+
+{{block "span" . -}}
+{{if .Extensions.span}}
+    {{range .Schemas -}}
+        func ({{$.VarName}} {{schemaType $ .}}) Pos() Position { return {{$.VarName}}.Span.Start }
+        func ({{$.VarName}} {{schemaType $ .}}) End() Position { return {{$.VarName}}.Span.End }
+    {{end -}}
+{{end}}
+{{- end}}
 `
 
 var tmpl = template.Must(
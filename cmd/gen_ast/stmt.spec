@@ -8,4 +8,7 @@ Break(Keyword: Token)
 Continue(Keyword: Token)
 Function(Name: Token, Params: []Token, Body: []Stmt)
 Return(Keyword: Token, Result: Expr)
-Class(Name: Token, Methods: []FunctionStmt, Vars: []VarStmt, StaticMethods: []FunctionStmt, StaticVars: []VarStmt)
+Class(Name: Token, Superclass: *VariableExpr, Methods: []FunctionStmt, Vars: []VarStmt, StaticMethods: []FunctionStmt, StaticVars: []VarStmt)
+Throw(Keyword: Token, Value: Expr)
+Try(Keyword: Token, Body: []Stmt, CatchName: *Token, Catch: []Stmt, Finally: []Stmt)
+Import(Keyword: Token, Path: Token, Name: Token)
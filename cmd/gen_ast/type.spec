@@ -3,4 +3,8 @@ Bool(Token: Token)
 Number(Token: Token)
 String(Token: Token)
 Function(Params: []Type, Return: Type)
-*Ref(Value: Type, ID: int)
+List(Element: Type)
+Map(Key: Type, Value: Type)
+*Ref(Value: Type, ID: int, Attrs: []Attribute) // Attrs constrain the values of an unbound ref.
+*Class(Name: Token, Superclass: *ClassType, Fields: map[string]Type, Methods: map[string]Type, Statics: map[string]Type)
+Instance(Class: *ClassType)
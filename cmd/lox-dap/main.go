@@ -0,0 +1,18 @@
+// Command lox-dap is a debug adapter for Lox scripts, that speaks the Debug Adapter
+// Protocol over stdin and stdout.
+//
+// It supports launching a script, line breakpoints in the main script, stack traces,
+// inspecting local and global variables, stepping, pausing and continuing.
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	// Stdout is reserved for the protocol, and the log is written to stderr.
+	if err := newServer(os.Stdin, os.Stdout).serve(); err != nil {
+		log.Fatal(err)
+	}
+}
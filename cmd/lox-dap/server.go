@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brunokim/kilox"
+)
+
+// Scripts are single-threaded, so there's a single thread with a fixed ID.
+const threadID = 1
+
+var errNotStopped = errors.New("program is not stopped")
+
+// Actions for requests that resume a stopped script.
+var stepActions = map[string]lox.StepAction{
+	"continue": lox.Resume,
+	"next":     lox.StepOver,
+	"stepIn":   lox.StepIn,
+	"stepOut":  lox.StepOut,
+}
+
+// stopCommand is sent to the interpreter goroutine while it's stopped, either to inspect
+// its state or to resume the execution.
+type stopCommand struct {
+	inspect func(d *lox.Debugger)
+	done    chan struct{}
+	action  lox.StepAction
+}
+
+// server handles requests from a single client, running a single script.
+//
+// Requests are handled in the goroutine calling serve, and the script runs in another one.
+// While the script is stopped, its state is inspected by sending commands to the debugger's
+// stop function.
+type server struct {
+	in *bufio.Reader
+
+	outMu sync.Mutex
+	out   io.Writer
+	seq   int
+
+	i      *lox.Interpreter
+	d      *lox.Debugger
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	program      string
+	text         string
+	stmts        []lox.Stmt
+	isEntry      bool
+	isLaunched   bool
+	isConfigured bool
+	isRunning    bool
+	// Breakpoint lines, by absolute source path.
+	breakpoints map[string][]int
+
+	mu        sync.Mutex
+	isStopped bool
+	commands  chan stopCommand
+	finished  chan struct{}
+
+	// Producers of the variables for each reference, starting at 1. They are only valid
+	// while stopped, and only accessed from the interpreter goroutine.
+	handles []func(d *lox.Debugger) []lox.Variable
+}
+
+func newServer(in io.Reader, out io.Writer) *server {
+	s := &server{
+		in:          bufio.NewReader(in),
+		out:         out,
+		breakpoints: make(map[string][]int),
+		commands:    make(chan stopCommand),
+		finished:    make(chan struct{}),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.i = lox.NewInterpreter(lox.Options{Stdout: outputWriter{s, "stdout"}})
+	s.d = lox.NewDebugger(s.i, s.onStop)
+	return s
+}
+
+// serve handles requests until the client disconnects or closes the input.
+func (s *server) serve() error {
+	for {
+		bs, err := readMessage(s.in)
+		if err == io.EOF {
+			s.terminate()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(bs, &req); err != nil {
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		body, err := s.handle(req)
+		resp := &response{
+			Type:       "response",
+			RequestSeq: req.Seq,
+			Success:    err == nil,
+			Command:    req.Command,
+			Body:       body,
+		}
+		if err != nil {
+			resp.Message = err.Error()
+		}
+		s.send(resp)
+		switch req.Command {
+		case "initialize":
+			s.send(&event{Type: "event", Event: "initialized"})
+		case "launch", "configurationDone":
+			s.start()
+		case "continue", "next", "stepIn", "stepOut":
+			// The script is only resumed after the response, so that it precedes the next
+			// stopped event.
+			if err == nil {
+				s.resume(stepActions[req.Command])
+			}
+		case "disconnect", "terminate":
+			return nil
+		}
+	}
+}
+
+func (s *server) handle(req request) (any, error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]any{"supportsConfigurationDoneRequest": true}, nil
+	case "launch":
+		var args launchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		var args setBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args)
+	case "configurationDone":
+		s.isConfigured = true
+		return nil, nil
+	case "threads":
+		return map[string]any{"threads": []thread{{threadID, "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		var args scopesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args)
+	case "variables":
+		var args variablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args)
+	case "continue":
+		return map[string]any{"allThreadsContinued": true}, s.leaveStop()
+	case "next", "stepIn", "stepOut":
+		return nil, s.leaveStop()
+	case "pause":
+		s.d.Pause()
+		return nil, nil
+	case "disconnect", "terminate":
+		s.terminate()
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+// ----
+
+func (s *server) launch(args launchArguments) error {
+	if s.isLaunched {
+		return errors.New("program already launched")
+	}
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+	bs, err := os.ReadFile(program)
+	if err != nil {
+		return err
+	}
+	text := string(bs)
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		return errors.New(lox.FormatError(text, err))
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		return errors.New(lox.FormatError(text, err))
+	}
+	if err := lox.NewResolver(s.i).Resolve(stmts); err != nil {
+		return errors.New(lox.FormatError(text, err))
+	}
+	s.i.SetSearchPath(append([]string{filepath.Dir(program)}, args.SearchPath...)...)
+	s.program, s.text, s.stmts = program, text, stmts
+	s.isLaunched = true
+	s.syncBreakpoints()
+	if args.StopOnEntry {
+		s.isEntry = true
+		s.d.Pause()
+	}
+	return nil
+}
+
+// start runs the script after it's launched and the client finished the configuration.
+func (s *server) start() {
+	if !s.isLaunched || !s.isConfigured || s.isRunning {
+		return
+	}
+	s.isRunning = true
+	go s.run()
+}
+
+func (s *server) run() {
+	err := s.i.InterpretContext(s.ctx, s.stmts)
+	exitCode := 0
+	if err != nil && s.ctx.Err() == nil {
+		var b strings.Builder
+		b.WriteString(lox.FormatError(s.text, err))
+		b.WriteString("\n")
+		for _, frame := range lox.StackTrace(err) {
+			fmt.Fprintf(&b, "    %v\n", frame)
+		}
+		s.send(&event{Type: "event", Event: "output", Body: outputEvent{"stderr", b.String()}})
+		exitCode = 65
+	}
+	close(s.finished)
+	s.send(&event{Type: "event", Event: "exited", Body: exitedEvent{exitCode}})
+	s.send(&event{Type: "event", Event: "terminated"})
+}
+
+// terminate stops the script, if it's running, and waits for it to finish.
+func (s *server) terminate() {
+	s.cancel()
+	if s.isRunning {
+		<-s.finished
+	}
+}
+
+func (s *server) setBreakpoints(args setBreakpointsArguments) (any, error) {
+	path, err := filepath.Abs(args.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+	// Breakpoints are only supported in the main script.
+	verified := !s.isLaunched || path == s.program
+	var lines []int
+	var bps []breakpoint
+	for _, bp := range args.Breakpoints {
+		lines = append(lines, bp.Line)
+		bps = append(bps, breakpoint{Verified: verified, Line: bp.Line})
+	}
+	s.breakpoints[path] = lines
+	s.syncBreakpoints()
+	return map[string]any{"breakpoints": bps}, nil
+}
+
+func (s *server) syncBreakpoints() {
+	if !s.isLaunched {
+		return
+	}
+	for _, line := range s.d.Breakpoints() {
+		s.d.ClearBreakpoint(line)
+	}
+	for _, line := range s.breakpoints[s.program] {
+		s.d.SetBreakpoint(line)
+	}
+}
+
+// ----
+
+func (s *server) onStop(d *lox.Debugger, stop lox.Stop) lox.StepAction {
+	reason := stop.Reason.String()
+	if stop.Reason == lox.PauseStop && s.isEntry {
+		reason = "entry"
+		s.isEntry = false
+	}
+	s.mu.Lock()
+	s.isStopped = true
+	s.mu.Unlock()
+	s.send(&event{Type: "event", Event: "stopped", Body: stoppedEvent{reason, threadID, true}})
+	for {
+		select {
+		case cmd := <-s.commands:
+			if cmd.inspect != nil {
+				cmd.inspect(d)
+				close(cmd.done)
+				continue
+			}
+			s.handles = nil
+			return cmd.action
+		case <-s.ctx.Done():
+			s.mu.Lock()
+			s.isStopped = false
+			s.mu.Unlock()
+			return lox.Resume
+		}
+	}
+}
+
+// leaveStop marks a stopped script as about to be resumed, so that it's no longer inspected.
+func (s *server) leaveStop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isStopped {
+		return errNotStopped
+	}
+	s.isStopped = false
+	return nil
+}
+
+// resume continues a script after leaveStop, with the given action.
+func (s *server) resume(action lox.StepAction) {
+	select {
+	case s.commands <- stopCommand{action: action}:
+	case <-s.ctx.Done():
+	}
+}
+
+// inspect runs f in the interpreter goroutine, while it's stopped.
+func (s *server) inspect(f func(d *lox.Debugger)) error {
+	s.mu.Lock()
+	isStopped := s.isStopped
+	s.mu.Unlock()
+	if !isStopped {
+		return errNotStopped
+	}
+	done := make(chan struct{})
+	select {
+	case s.commands <- stopCommand{inspect: f, done: done}:
+	case <-s.ctx.Done():
+		return errNotStopped
+	}
+	<-done
+	return nil
+}
+
+func (s *server) stackTrace() (any, error) {
+	var frames []stackFrame
+	err := s.inspect(func(d *lox.Debugger) {
+		for k, frame := range d.StackTrace() {
+			name := frame.Function
+			if name == "" {
+				name = "<script>"
+			}
+			path := d.Module(k)
+			if path == "" {
+				path = s.program
+			}
+			column := frame.Token.Span.Start.Column
+			if column < 1 {
+				column = 1
+			}
+			frames = append(frames, stackFrame{k, name, source{filepath.Base(path), path}, frame.Token.Line, column})
+		}
+	})
+	return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}, err
+}
+
+func (s *server) scopes(args scopesArguments) (any, error) {
+	var scopes []scope
+	err := s.inspect(func(d *lox.Debugger) {
+		frame := args.FrameID
+		locals := s.newHandle(func(d *lox.Debugger) []lox.Variable { return d.Locals(frame) })
+		globals := s.newHandle(func(d *lox.Debugger) []lox.Variable { return d.Globals(frame) })
+		scopes = []scope{{"Locals", locals, false}, {"Globals", globals, false}}
+	})
+	return map[string]any{"scopes": scopes}, err
+}
+
+func (s *server) variables(args variablesArguments) (any, error) {
+	vars := []variable{}
+	var errRef error
+	err := s.inspect(func(d *lox.Debugger) {
+		ref := args.VariablesReference
+		if ref < 1 || ref > len(s.handles) {
+			errRef = fmt.Errorf("invalid variables reference %d", ref)
+			return
+		}
+		for _, v := range s.handles[ref-1](d) {
+			value := v.Value
+			var ref int
+			if lox.Members(value) != nil {
+				ref = s.newHandle(func(*lox.Debugger) []lox.Variable { return lox.Members(value) })
+			}
+			vars = append(vars, variable{Name: v.Name, Value: formatValue(value), VariablesReference: ref})
+		}
+	})
+	if err == nil {
+		err = errRef
+	}
+	return map[string]any{"variables": vars}, err
+}
+
+func (s *server) newHandle(f func(d *lox.Debugger) []lox.Variable) int {
+	s.handles = append(s.handles, f)
+	return len(s.handles)
+}
+
+// ----
+
+func (s *server) send(msg any) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	if err := writeMessage(s.out, msg); err != nil {
+		// The client is gone, so there's no one to report to.
+		s.cancel()
+	}
+}
+
+// outputWriter sends everything written to it as output events.
+type outputWriter struct {
+	s        *server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.send(&event{Type: "event", Event: "output", Body: outputEvent{w.category, string(p)}})
+	return len(p), nil
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
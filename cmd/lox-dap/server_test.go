@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const script = `class Point {
+    init(x, y) {
+        this.x = x;
+        this.y = y;
+    }
+}
+fun add(a, b) {
+    var sum = a + b;
+    return sum;
+}
+var p = Point(1, 2);
+print add(p.x, p.y);
+print "done";
+`
+
+// message is a response or event received by the client, with all fields that are checked.
+type message struct {
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client sends requests to a server running in another goroutine, and collects the
+// output events it receives.
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	seq      int
+	messages chan message
+	output   string
+	// Messages received while waiting for another one.
+	pending []message
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := newServer(inR, outW)
+	go func() {
+		if err := s.serve(); err != nil {
+			t.Errorf("serve: %v", err)
+		}
+		outW.Close()
+	}()
+	c := &client{t: t, w: inW, messages: make(chan message)}
+	go func() {
+		defer close(c.messages)
+		r := bufio.NewReader(outR)
+		for {
+			bs, err := readMessage(r)
+			if err != nil {
+				return
+			}
+			var msg message
+			if err := json.Unmarshal(bs, &msg); err != nil {
+				t.Errorf("invalid message %s: %v", bs, err)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	return c
+}
+
+// request sends a request and waits for its response, returning its body.
+func (c *client) request(command string, args any) json.RawMessage {
+	c.seq++
+	req := map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args}
+	if err := writeMessage(c.w, req); err != nil {
+		c.t.Fatalf("%s: %v", command, err)
+	}
+	msg := c.expect(func(msg message) bool { return msg.Type == "response" && msg.RequestSeq == c.seq })
+	if !msg.Success {
+		c.t.Fatalf("%s: %s", command, msg.Message)
+	}
+	return msg.Body
+}
+
+// waitEvent waits for an event with the given name, returning its body.
+func (c *client) waitEvent(name string) json.RawMessage {
+	return c.expect(func(msg message) bool { return msg.Type == "event" && msg.Event == name }).Body
+}
+
+func (c *client) expect(match func(msg message) bool) message {
+	c.t.Helper()
+	for i, msg := range c.pending {
+		if match(msg) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return msg
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("server closed the connection")
+			}
+			if msg.Event == "output" {
+				var body outputEvent
+				json.Unmarshal(msg.Body, &body)
+				c.output += body.Output
+			}
+			if match(msg) {
+				return msg
+			}
+			c.pending = append(c.pending, msg)
+		case <-timeout:
+			c.t.Fatal("timeout waiting for message")
+		}
+	}
+}
+
+func decode[T any](t *testing.T, bs json.RawMessage) T {
+	var v T
+	if err := json.Unmarshal(bs, &v); err != nil {
+		t.Fatalf("invalid body %s: %v", bs, err)
+	}
+	return v
+}
+
+func TestDebugSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.lox")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.request("initialize", map[string]any{"adapterID": "lox"})
+	c.waitEvent("initialized")
+	c.request("launch", map[string]any{"program": path})
+	bps := decode[struct{ Breakpoints []breakpoint }](t, c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []map[string]any{{"line": 9}},
+	}))
+	if diff := cmp.Diff([]breakpoint{{Verified: true, Line: 9}}, bps.Breakpoints); diff != "" {
+		t.Errorf("breakpoints: (-want, +got)%s", diff)
+	}
+	c.request("configurationDone", nil)
+
+	stopped := decode[stoppedEvent](t, c.waitEvent("stopped"))
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("want stop at breakpoint, got %q", stopped.Reason)
+	}
+	trace := decode[struct{ StackFrames []stackFrame }](t, c.request("stackTrace", map[string]any{"threadId": threadID}))
+	var frames []string
+	for _, frame := range trace.StackFrames {
+		if frame.Source.Path != path {
+			t.Errorf("want frame source %q, got %q", path, frame.Source.Path)
+		}
+		frames = append(frames, fmt.Sprintf("%s:%d", frame.Name, frame.Line))
+	}
+	if diff := cmp.Diff([]string{"add:9", "<script>:12"}, frames); diff != "" {
+		t.Errorf("stack trace: (-want, +got)%s", diff)
+	}
+
+	scopes := decode[struct{ Scopes []scope }](t, c.request("scopes", map[string]any{"frameId": 0}))
+	locals := c.variables(scopes.Scopes[0].VariablesReference)
+	if diff := cmp.Diff(map[string]string{"a": "1", "b": "2", "sum": "3"}, values(locals)); diff != "" {
+		t.Errorf("locals: (-want, +got)%s", diff)
+	}
+	globals := c.variables(scopes.Scopes[1].VariablesReference)
+	wantGlobals := map[string]string{"Point": "<class Point>", "add": "<fn add>", "p": "<instance Point>"}
+	if diff := cmp.Diff(wantGlobals, values(globals)); diff != "" {
+		t.Errorf("globals: (-want, +got)%s", diff)
+	}
+	var fields []variable
+	for _, v := range globals {
+		if v.Name == "p" {
+			fields = c.variables(v.VariablesReference)
+		}
+	}
+	if diff := cmp.Diff(map[string]string{"x": "1", "y": "2"}, values(fields)); diff != "" {
+		t.Errorf("fields: (-want, +got)%s", diff)
+	}
+
+	// Stepping over the return stops at the next statement in the script.
+	c.request("next", map[string]any{"threadId": threadID})
+	c.waitEvent("stopped")
+	trace = decode[struct{ StackFrames []stackFrame }](t, c.request("stackTrace", map[string]any{"threadId": threadID}))
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 13 {
+		t.Errorf("want stop at line 13, got %+v", trace.StackFrames)
+	}
+
+	c.request("continue", map[string]any{"threadId": threadID})
+	exited := decode[exitedEvent](t, c.waitEvent("exited"))
+	if exited.ExitCode != 0 {
+		t.Errorf("want exit code 0, got %d", exited.ExitCode)
+	}
+	c.waitEvent("terminated")
+	if diff := cmp.Diff("3\ndone\n", c.output); diff != "" {
+		t.Errorf("output: (-want, +got)%s", diff)
+	}
+	c.request("disconnect", nil)
+}
+
+func TestStopOnEntryAndDisconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "loop.lox")
+	if err := os.WriteFile(path, []byte("var i = 0;\nwhile (true) {\n    i = i + 1;\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.request("initialize", nil)
+	c.request("launch", map[string]any{"program": path, "stopOnEntry": true})
+	c.request("configurationDone", nil)
+	stopped := decode[stoppedEvent](t, c.waitEvent("stopped"))
+	if stopped.Reason != "entry" {
+		t.Errorf("want stop at entry, got %q", stopped.Reason)
+	}
+	// Pausing a running script stops it within the loop.
+	c.request("continue", map[string]any{"threadId": threadID})
+	c.request("pause", map[string]any{"threadId": threadID})
+	stopped = decode[stoppedEvent](t, c.waitEvent("stopped"))
+	if stopped.Reason != "pause" {
+		t.Errorf("want stop at pause, got %q", stopped.Reason)
+	}
+	// Disconnecting terminates the script before responding.
+	c.request("disconnect", nil)
+}
+
+func (c *client) variables(ref int) []variable {
+	body := c.request("variables", map[string]any{"variablesReference": ref})
+	return decode[struct{ Variables []variable }](c.t, body).Variables
+}
+
+func values(vars []variable) map[string]string {
+	m := make(map[string]string)
+	for _, v := range vars {
+		m[v.Name] = v.Value
+	}
+	return m
+}
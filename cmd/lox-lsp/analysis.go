@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/lint"
+	"github.com/brunokim/kilox/typing"
+)
+
+// document is the result of analyzing a source file.
+type document struct {
+	uri  string
+	text string
+	// Byte offset where each line starts.
+	lineStarts  []int
+	symbols     []lox.Symbol
+	types       map[lox.Expr]lox.Type
+	diagnostics []lox.Diagnostic
+}
+
+// analyze scans, parses, resolves, lints and, if typecheck is set, checks the types of text,
+// stopping at the first phase with errors. The linter runs even if there are resolve errors,
+// that are usually local to a statement, but the type checker requires a resolved program.
+func analyze(uri, text string, typecheck bool) *document {
+	doc := &document{uri: uri, text: text, lineStarts: []int{0}}
+	for i, ch := range text {
+		if ch == '\n' {
+			doc.lineStarts = append(doc.lineStarts, i+1)
+		}
+	}
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		doc.diagnostics = lox.Diagnostics(err)
+		return doc
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		doc.diagnostics = lox.Diagnostics(err)
+		return doc
+	}
+	r := lox.NewResolver(lox.NewInterpreter())
+	resolveErr := r.Resolve(stmts)
+	doc.symbols = r.Symbols()
+	doc.diagnostics = lox.Diagnostics(resolveErr)
+	err = lint.NewLinter(lint.Config{}).Lint(stmts)
+	doc.diagnostics = append(doc.diagnostics, lox.Diagnostics(err)...)
+	if typecheck && resolveErr == nil {
+		types, err := typing.NewChecker().Check(stmts)
+		doc.types = types
+		doc.diagnostics = append(doc.diagnostics, lox.Diagnostics(err)...)
+	}
+	return doc
+}
+
+// ----
+
+// symbolAt returns the symbol whose name contains offset or, failing that, ends at it, since
+// the cursor is frequently placed right after a name.
+func (doc *document) symbolAt(offset int) (lox.Symbol, bool) {
+	for _, sym := range doc.symbols {
+		if sym.Name.Span.Contains(offset) {
+			return sym, true
+		}
+	}
+	for _, sym := range doc.symbols {
+		if sym.Name.Span.End.Offset == offset {
+			return sym, true
+		}
+	}
+	return lox.Symbol{}, false
+}
+
+// definitions returns the declaration of a variable, or all declarations of properties with
+// the same name, since properties are only resolved at runtime.
+func (doc *document) definitions(sym lox.Symbol) []location {
+	var locs []location
+	if sym.Kind == lox.VariableSymbol {
+		if sym.Decl.Lexeme != "" {
+			locs = append(locs, doc.location(sym.Decl.Span))
+		}
+		return locs
+	}
+	for _, other := range doc.symbols {
+		if other.Kind == lox.PropertySymbol && other.IsDecl && other.Name.Lexeme == sym.Name.Lexeme {
+			locs = append(locs, doc.location(other.Name.Span))
+		}
+	}
+	return locs
+}
+
+// references returns all occurrences of the same variable, or of properties with the same name.
+func (doc *document) references(sym lox.Symbol, includeDecl bool) []location {
+	var locs []location
+	for _, other := range doc.symbols {
+		if !sameSymbol(sym, other) || (other.IsDecl && !includeDecl) {
+			continue
+		}
+		locs = append(locs, doc.location(other.Name.Span))
+	}
+	return locs
+}
+
+func sameSymbol(a, b lox.Symbol) bool {
+	if a.Kind != b.Kind || a.Name.Lexeme != b.Name.Lexeme {
+		return false
+	}
+	if a.Kind == lox.PropertySymbol {
+		return true
+	}
+	// Undeclared globals, like builtins, are matched by name.
+	return a.Decl.Span == b.Decl.Span
+}
+
+// hover returns the inferred type of a variable, as a markdown code block. All occurrences
+// of a variable have the same type, so declarations use the type of any reference.
+func (doc *document) hover(sym lox.Symbol) (string, bool) {
+	if sym.Kind != lox.VariableSymbol {
+		return "", false
+	}
+	t, ok := doc.types[sym.Expr]
+	for _, other := range doc.symbols {
+		if ok {
+			break
+		}
+		if other.Expr != nil && sameSymbol(sym, other) {
+			t, ok = doc.types[other.Expr]
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("```\n%s: %s\n```", sym.Name.Lexeme, typing.FormatType(t)), true
+}
+
+// ----
+
+// offset returns the byte offset of an LSP position, whose character is counted in UTF-16
+// code units.
+func (doc *document) offset(pos position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(doc.lineStarts) {
+		return len(doc.text)
+	}
+	start := doc.lineStarts[pos.Line]
+	units := 0
+	for i, ch := range doc.text[start:] {
+		if ch == '\n' || units >= pos.Character {
+			return start + i
+		}
+		units += utf16Len(ch)
+	}
+	return len(doc.text)
+}
+
+func (doc *document) position(pos lox.Position) position {
+	if pos.Line < 1 || pos.Line > len(doc.lineStarts) {
+		return position{}
+	}
+	start, end := doc.lineStarts[pos.Line-1], pos.Offset
+	if end < start || end > len(doc.text) {
+		end = start
+	}
+	units := 0
+	for _, ch := range doc.text[start:end] {
+		units += utf16Len(ch)
+	}
+	return position{pos.Line - 1, units}
+}
+
+func (doc *document) range_(span lox.Span) range_ {
+	return range_{doc.position(span.Start), doc.position(span.End)}
+}
+
+func (doc *document) location(span lox.Span) location {
+	return location{doc.uri, doc.range_(span)}
+}
+
+func (doc *document) lspDiagnostics() []diagnostic {
+	diags := []diagnostic{}
+	for _, d := range doc.diagnostics {
+		severity := severityError
+		if d.Severity == lox.SeverityWarning {
+			severity = severityWarning
+		}
+		diags = append(diags, diagnostic{
+			Range:    doc.range_(d.Span),
+			Severity: severity,
+			Code:     d.Code,
+			Source:   "lox",
+			Message:  strings.TrimSpace(d.Message),
+		})
+	}
+	return diags
+}
+
+func utf16Len(ch rune) int {
+	if ch >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,24 @@
+// Command lox-lsp is a language server for Lox, that speaks the Language Server Protocol
+// over stdin and stdout.
+//
+// It publishes diagnostics from all phases up to type checking, including the warnings of
+// the linter, whenever a document changes, and provides go-to-definition and find-references
+// for variables, functions, classes and properties, and hover with the inferred types of
+// variables.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+)
+
+var typecheck = flag.Bool("typing", true, "check types, reporting type errors and showing inferred types on hover")
+
+func main() {
+	flag.Parse()
+	// Stdout is reserved for the protocol, and the log is written to stderr.
+	if err := newServer(os.Stdin, os.Stdout, *typecheck).serve(); err != nil {
+		log.Fatal(err)
+	}
+}
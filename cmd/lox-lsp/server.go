@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/brunokim/kilox"
+)
+
+// server handles requests from a single client, analyzing each open document whenever
+// it changes.
+type server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	typecheck bool
+	docs      map[string]*document
+}
+
+func newServer(in io.Reader, out io.Writer, typecheck bool) *server {
+	return &server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		typecheck: typecheck,
+		docs:      make(map[string]*document),
+	}
+}
+
+// serve handles messages until the client sends an exit notification, or closes the input.
+func (s *server) serve() error {
+	for {
+		bs, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var msg message
+		if err := json.Unmarshal(bs, &msg); err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		result, rpcErr := s.handle(msg)
+		if msg.ID == nil {
+			// Notifications don't have a response.
+			continue
+		}
+		resp := response{JSONRPC: "2.0", ID: msg.ID, Result: result, Error: rpcErr}
+		if err := writeMessage(s.out, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) handle(msg message) (any, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // Full document sync.
+				"definitionProvider": true,
+				"referencesProvider": true,
+				"hoverProvider":      true,
+			},
+			"serverInfo": map[string]any{"name": "lox-lsp"},
+		}, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		n := len(params.ContentChanges)
+		if n == 0 {
+			return nil, nil
+		}
+		// With full document sync, the last change has the whole text.
+		return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publish(publishDiagnosticsParams{params.TextDocument.URI, []diagnostic{}})
+	case "textDocument/definition":
+		doc, sym, rpcErr := s.symbolAt(msg.Params)
+		if rpcErr != nil || doc == nil {
+			return nil, rpcErr
+		}
+		return doc.definitions(sym), nil
+	case "textDocument/references":
+		var params referenceParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &responseError{invalidParams, err.Error()}
+		}
+		doc, sym, rpcErr := s.symbolAt(msg.Params)
+		if rpcErr != nil || doc == nil {
+			return nil, rpcErr
+		}
+		return doc.references(sym, params.Context.IncludeDeclaration), nil
+	case "textDocument/hover":
+		doc, sym, rpcErr := s.symbolAt(msg.Params)
+		if rpcErr != nil || doc == nil {
+			return nil, rpcErr
+		}
+		text, ok := doc.hover(sym)
+		if !ok {
+			return nil, nil
+		}
+		return hover{markupContent{"markdown", text}, doc.range_(sym.Name.Span)}, nil
+	}
+	if msg.ID == nil {
+		// Unknown notifications, like 'initialized', are ignored.
+		return nil, nil
+	}
+	return nil, &responseError{methodNotFound, fmt.Sprintf("unsupported method %q", msg.Method)}
+}
+
+// update analyzes a document and publishes its diagnostics.
+func (s *server) update(uri, text string) *responseError {
+	doc := analyze(uri, text, s.typecheck)
+	s.docs[uri] = doc
+	return s.publish(publishDiagnosticsParams{uri, doc.lspDiagnostics()})
+}
+
+func (s *server) publish(params publishDiagnosticsParams) *responseError {
+	msg := notification{JSONRPC: "2.0", Method: "textDocument/publishDiagnostics", Params: params}
+	if err := writeMessage(s.out, msg); err != nil {
+		return &responseError{Message: err.Error()}
+	}
+	return nil
+}
+
+// symbolAt returns the document and the symbol at the position in params. Returns a nil
+// document if there's no symbol there.
+func (s *server) symbolAt(params json.RawMessage) (*document, lox.Symbol, *responseError) {
+	var p textDocumentPositionParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, lox.Symbol{}, &responseError{invalidParams, err.Error()}
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, lox.Symbol{}, &responseError{invalidParams, fmt.Sprintf("document %q is not open", p.TextDocument.URI)}
+	}
+	sym, ok := doc.symbolAt(doc.offset(p.Position))
+	if !ok {
+		return nil, lox.Symbol{}, nil
+	}
+	return doc, sym, nil
+}
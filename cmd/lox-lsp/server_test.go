@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const uri = "file:///test.lox"
+
+// client sends messages to a server running in another goroutine, and reads its responses
+// and notifications.
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	id       int
+	messages chan json.RawMessage
+	// Last diagnostics published for the test document.
+	diagnostics []diagnostic
+}
+
+func newClient(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := newServer(inR, outW, true)
+	go func() {
+		if err := s.serve(); err != nil {
+			t.Errorf("serve: %v", err)
+		}
+		outW.Close()
+	}()
+	t.Cleanup(func() { inW.Close() })
+	c := &client{t: t, w: inW, messages: make(chan json.RawMessage)}
+	go func() {
+		defer close(c.messages)
+		r := bufio.NewReader(outR)
+		for {
+			bs, err := readMessage(r)
+			if err != nil {
+				return
+			}
+			c.messages <- bs
+		}
+	}()
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if err := writeMessage(c.w, msg); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+// request sends a request and decodes its result into result, handling notifications
+// received in the meantime.
+func (c *client) request(method string, params any, result any) {
+	c.id++
+	msg := map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params}
+	if err := writeMessage(c.w, msg); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		var bs json.RawMessage
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("server closed the connection")
+			}
+			bs = msg
+		case <-timeout:
+			c.t.Fatalf("%s: timeout waiting for response", method)
+		}
+		var resp struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+			Result json.RawMessage `json:"result"`
+			Error  *responseError  `json:"error"`
+		}
+		if err := json.Unmarshal(bs, &resp); err != nil {
+			c.t.Fatalf("invalid message %s: %v", bs, err)
+		}
+		if resp.Method == "textDocument/publishDiagnostics" {
+			var params publishDiagnosticsParams
+			json.Unmarshal(resp.Params, &params)
+			c.diagnostics = params.Diagnostics
+			continue
+		}
+		if resp.ID != c.id {
+			continue
+		}
+		if resp.Error != nil {
+			c.t.Fatalf("%s: %s", method, resp.Error.Message)
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			c.t.Fatalf("%s: invalid result %s: %v", method, resp.Result, err)
+		}
+		return
+	}
+}
+
+func (c *client) open(text string) {
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "lox", "version": 1, "text": text}})
+	// A server request ensures that the notification was processed.
+	var result any
+	c.request("shutdown", nil, &result)
+}
+
+func at(line, character int) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": position{line, character}}
+}
+
+// locs formats locations as "line:character" of their start, both starting at 0.
+func locs(ls []location) []string {
+	var strs []string
+	for _, l := range ls {
+		strs = append(strs, fmt.Sprintf("%d:%d", l.Range.Start.Line, l.Range.Start.Character))
+	}
+	return strs
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", map[string]any{}, new(any))
+	c.open("{\n    var unused = 1;\n}\nprint 1 +;")
+	want := []diagnostic{{
+		Range:    range_{position{3, 9}, position{3, 10}},
+		Severity: severityError,
+		Code:     "expecting-expression",
+		Source:   "lox",
+		Message:  "expecting expression",
+	}}
+	if diff := cmp.Diff(want, c.diagnostics); diff != "" {
+		t.Errorf("parse errors: (-want, +got)%s", diff)
+	}
+
+	// Diagnostics are updated on changes.
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{"text": "{\n    var unused = 1;\n}"}},
+	})
+	c.request("shutdown", nil, new(any))
+	want = []diagnostic{{
+		Range:    range_{position{1, 8}, position{1, 14}},
+		Severity: severityWarning,
+		Code:     "unused-variable",
+		Source:   "lox",
+		Message:  "local variable is never read",
+	}}
+	if diff := cmp.Diff(want, c.diagnostics); diff != "" {
+		t.Errorf("resolve errors: (-want, +got)%s", diff)
+	}
+
+	// Types are not checked if there are resolve errors.
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 3},
+		"contentChanges": []map[string]any{{"text": "print this;"}},
+	})
+	c.request("shutdown", nil, new(any))
+	want = []diagnostic{{
+		Range:    range_{position{0, 6}, position{0, 10}},
+		Severity: severityError,
+		Code:     "this-outside-class",
+		Source:   "lox",
+		Message:  "'this' can only be used within classes",
+	}}
+	if diff := cmp.Diff(want, c.diagnostics); diff != "" {
+		t.Errorf("resolve errors without types: (-want, +got)%s", diff)
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", map[string]any{}, new(any))
+	c.open(`class Point {
+    init(x) {
+        this.x = x;
+    }
+}
+fun twice(n) {
+    return 2 * n;
+}
+var p = Point(twice(1));
+print twice(p.x);
+`)
+	if len(c.diagnostics) != 0 {
+		t.Errorf("want no diagnostics, got %v", c.diagnostics)
+	}
+	tests := []struct {
+		desc   string
+		method string
+		pos    map[string]any
+		want   []string
+	}{
+		{"definition of function", "textDocument/definition", at(8, 14), []string{"5:4"}},
+		{"definition of param", "textDocument/definition", at(6, 15), []string{"5:10"}},
+		{"definition of class", "textDocument/definition", at(8, 8), []string{"0:6"}},
+		{"definition of field", "textDocument/definition", at(9, 15), []string{"2:13"}},
+		{"references of function", "textDocument/references", at(5, 5), []string{"8:14", "9:6"}},
+		{"references of field", "textDocument/references", at(2, 13), []string{"9:14"}},
+	}
+	for _, test := range tests {
+		var got []location
+		params := test.pos
+		if test.method == "textDocument/references" {
+			params["context"] = map[string]any{"includeDeclaration": false}
+		}
+		c.request(test.method, params, &got)
+		if diff := cmp.Diff(test.want, locs(got)); diff != "" {
+			t.Errorf("%s: (-want, +got)%s", test.desc, diff)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", map[string]any{}, new(any))
+	c.open(`fun twice(n) {
+    return 2 * n;
+}
+var s = "a";
+print twice(1) + len(s);
+`)
+	tests := []struct {
+		pos  map[string]any
+		want string
+	}{
+		{at(4, 8), "```\ntwice: (Fun (Number) Number)\n```"},
+		{at(0, 5), "```\ntwice: (Fun (Number) Number)\n```"},
+		{at(1, 15), "```\nn: Number\n```"},
+		{at(4, 22), "```\ns: String\n```"},
+	}
+	for _, test := range tests {
+		var got hover
+		c.request("textDocument/hover", test.pos, &got)
+		if diff := cmp.Diff(test.want, got.Contents.Value); diff != "" {
+			t.Errorf("hover at %v: (-want, +got)%s", test.pos["position"], diff)
+		}
+	}
+}
+
+// Globals may be referenced before their declaration, or not be declared at all.
+func TestHoverGlobals(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", map[string]any{}, new(any))
+	c.open(`fun f() { return g(); }
+fun g() { return 1; }
+print f();
+print foo;
+`)
+	tests := []struct {
+		pos  map[string]any
+		want string
+	}{
+		{at(0, 17), "```\ng: (Fun () Number)\n```"},
+		{at(2, 6), "```\nf: (Fun () Number)\n```"},
+		{at(3, 6), "```\nfoo: _1\n```"},
+	}
+	for _, test := range tests {
+		var got hover
+		c.request("textDocument/hover", test.pos, &got)
+		if diff := cmp.Diff(test.want, got.Contents.Value); diff != "" {
+			t.Errorf("hover at %v: (-want, +got)%s", test.pos["position"], diff)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brunokim/kilox"
+)
+
+const debugHelp = `Commands:
+  s, step           run until the next statement, entering calls
+  n, next           run until the next statement in the same function
+  o, out            run until the current function returns
+  c, continue       run until the next breakpoint
+  b, break LINE     set a breakpoint at LINE of the script
+  clear LINE        remove the breakpoint at LINE
+  p, print NAME...  print the value of variables
+  locals            print the local variables
+  globals           print the global variables
+  bt, backtrace     print the call stack
+  l, list           print the source around the current line
+  q, quit           stop the script and exit
+An empty line repeats the last command.`
+
+// debugger is a command-line interface for lox.Debugger, reading commands from stdin.
+type debugger struct {
+	in *bufio.Scanner
+	// Source lines of each module, keyed by path. The main script has an empty path.
+	sources map[string][]string
+	lastCmd string
+}
+
+func newDebugger(i *lox.Interpreter) *debugger {
+	dbg := &debugger{
+		in:      bufio.NewScanner(os.Stdin),
+		sources: make(map[string][]string),
+	}
+	d := lox.NewDebugger(i, dbg.onStop)
+	// Stop before the first statement, so that breakpoints may be set.
+	d.Pause()
+	return dbg
+}
+
+func (dbg *debugger) setSource(text string) {
+	dbg.sources[""] = splitLines(text)
+}
+
+func (dbg *debugger) onStop(d *lox.Debugger, stop lox.Stop) lox.StepAction {
+	where := fmt.Sprintf("line %d", stop.Line)
+	if stop.Module != "" {
+		where += " of " + stop.Module
+	}
+	fmt.Printf("stopped at %s (%v)\n", where, stop.Reason)
+	dbg.printLines(stop, stop.Line, stop.Line)
+	for {
+		fmt.Print("(debug) ")
+		if !dbg.in.Scan() {
+			// Run until completion if there are no more commands.
+			d.Detach()
+			return lox.Resume
+		}
+		line := strings.TrimSpace(dbg.in.Text())
+		if line == "" {
+			line = dbg.lastCmd
+		}
+		dbg.lastCmd = line
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		cmd, args := fields[0], fields[1:]
+		switch cmd {
+		case "s", "step":
+			return lox.StepIn
+		case "n", "next":
+			return lox.StepOver
+		case "o", "out":
+			return lox.StepOut
+		case "c", "continue":
+			return lox.Resume
+		case "b", "break", "clear":
+			for _, arg := range args {
+				n, err := strconv.Atoi(arg)
+				if err != nil || n < 1 {
+					fmt.Printf("invalid line %q\n", arg)
+					continue
+				}
+				if cmd == "clear" {
+					d.ClearBreakpoint(n)
+				} else {
+					d.SetBreakpoint(n)
+				}
+			}
+			fmt.Printf("breakpoints: %v\n", d.Breakpoints())
+		case "p", "print":
+			for _, name := range args {
+				value, ok := d.Lookup(0, name)
+				if !ok {
+					fmt.Printf("undefined variable %q\n", name)
+					continue
+				}
+				fmt.Printf("%s = %s\n", name, formatValue(value))
+			}
+		case "locals":
+			printVariables(d.Locals(0))
+		case "globals":
+			printVariables(d.Globals(0))
+		case "bt", "backtrace":
+			for _, frame := range d.StackTrace() {
+				fmt.Printf("    %v\n", frame)
+			}
+		case "l", "list":
+			dbg.printLines(stop, stop.Line-5, stop.Line+5)
+		case "h", "help":
+			fmt.Println(debugHelp)
+		case "q", "quit":
+			os.Exit(0)
+		default:
+			fmt.Printf("unknown command %q, type 'help' for a list of commands\n", cmd)
+		}
+	}
+}
+
+// printLines prints the source lines from first to last, inclusive, marking the current one.
+func (dbg *debugger) printLines(stop lox.Stop, first, last int) {
+	lines, ok := dbg.sources[stop.Module]
+	if !ok {
+		bs, err := ioutil.ReadFile(stop.Module)
+		if err == nil {
+			lines = splitLines(string(bs))
+		}
+		dbg.sources[stop.Module] = lines
+	}
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	for n := first; n <= last; n++ {
+		marker := " "
+		if n == stop.Line {
+			marker = ">"
+		}
+		fmt.Printf("%s %4d | %s\n", marker, n, lines[n-1])
+	}
+}
+
+func splitLines(text string) []string {
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func printVariables(vars []lox.Variable) {
+	for _, v := range vars {
+		fmt.Printf("%s = %s\n", v.Name, formatValue(v.Value))
+	}
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
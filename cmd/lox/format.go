@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/brunokim/kilox"
+)
+
+// runFmt implements the 'fmt' subcommand, that writes the canonical source of each file to
+// stdout or, with -w, back to the file. Without files, it formats the standard input.
+// Returns the exit status.
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result to the source file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lox fmt [-w] [files...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		if *write {
+			fs.Usage()
+			return 64
+		}
+		bs, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 74
+		}
+		return formatSource("<stdin>", bs, false)
+	}
+	status := 0
+	for _, path := range fs.Args() {
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 74
+			continue
+		}
+		if code := formatSource(path, bs, *write); code != 0 {
+			status = code
+		}
+	}
+	return status
+}
+
+// formatSource formats the source read from path, writing it back only if it has changed.
+func formatSource(path string, bs []byte, write bool) int {
+	text, err := lox.Format(string(bs))
+	if err != nil {
+		reportFile(path, string(bs), err)
+		return 65
+	}
+	if !write {
+		fmt.Print(text)
+		return 0
+	}
+	if bytes.Equal(bs, []byte(text)) {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 74
+	}
+	if err := ioutil.WriteFile(path, []byte(text), info.Mode().Perm()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 74
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/lint"
+)
+
+// runLint implements the 'lint' subcommand, that reports problems found by the resolver and
+// the linter in each file. Returns a failure status only if there are errors, and not if
+// there are only warnings.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	rules := fs.String("rules", "", "comma-separated list of 'code=severity' settings, where severity is 'error', 'warning' or 'off'")
+	list := fs.Bool("list", false, "list the rules and their default severities")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: lox lint [-rules code=severity,...] [-list] files...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *list {
+		for _, rule := range lint.Rules {
+			fmt.Printf("%-22s %-8s %s\n", rule.Code, rule.Severity, rule.Description)
+		}
+		return 0
+	}
+	config, err := lint.ParseConfig(*rules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		return 64
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 64
+	}
+	status := 0
+	for _, path := range fs.Args() {
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 74
+			continue
+		}
+		if code := lintSource(path, string(bs), config); code != 0 {
+			status = code
+		}
+	}
+	return status
+}
+
+func lintSource(path, text string, config lint.Config) int {
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		reportFile(path, text, err)
+		return 65
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		reportFile(path, text, err)
+		return 65
+	}
+	status := 0
+	if err := lox.NewResolver(lox.NewInterpreter()).Resolve(stmts); err != nil {
+		reportFile(path, text, err)
+		status = 65
+	}
+	err = lint.NewLinter(config).Lint(stmts)
+	if err == nil {
+		return status
+	}
+	reportFile(path, text, err)
+	for _, d := range lox.Diagnostics(err) {
+		if d.Severity == lox.SeverityError {
+			status = 65
+		}
+	}
+	return status
+}
@@ -1,35 +1,95 @@
 package main
 
 import (
-	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
 	"os"
+	"path/filepath"
 
 	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/vm"
+)
+
+var (
+	backend       = flag.String("backend", "tree", "execution backend: 'tree' for the tree-walking interpreter, 'vm' for the bytecode VM")
+	format        = flag.String("format", "text", "error format: 'text' for human-readable errors, 'json' for JSON lines in stderr")
+	deterministic = flag.Bool("deterministic", false, "use a fake clock and a seeded random source, so that runs are reproducible")
+	seed          = flag.Int64("seed", 0, "random seed used with -deterministic")
+	debug         = flag.Bool("debug", false, "run the script in an interactive debugger, that reads commands from stdin")
+	history       = flag.String("history", defaultHistoryPath(), "file where the REPL keeps the history of inputs, or empty to not keep it")
+	loxpath       = flag.String("loxpath", os.Getenv("LOXPATH"), "list of directories where imported modules are looked up, separated by the OS path list separator")
 )
 
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Println("Usage: lox [script]")
-		return
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: lox [flags] [script]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lox fmt [-w] [files...]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       lox lint [-rules code=severity,...] [-list] files...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	switch flag.Arg(0) {
+	case "fmt":
+		os.Exit(runFmt(flag.Args()[1:]))
+	case "lint":
+		os.Exit(runLint(flag.Args()[1:]))
+	}
+	if flag.NArg() > 1 || (*backend != "tree" && *backend != "vm") || (*format != "text" && *format != "json") ||
+		(*debug && (flag.NArg() == 0 || *backend != "tree")) {
+		flag.Usage()
+		os.Exit(64)
 	}
 	r := newRunner()
-	if len(os.Args) == 2 {
-		r.runFile(os.Args[1])
+	if flag.NArg() == 1 {
+		// Modules are looked up relative to the script directory, and then in LOXPATH.
+		dirs := append([]string{filepath.Dir(flag.Arg(0))}, filepath.SplitList(*loxpath)...)
+		r.setSearchPath(dirs...)
+		r.runFile(flag.Arg(0))
 	} else {
-		r.runPrompt()
+		r.setSearchPath(filepath.SplitList(*loxpath)...)
+		newREPL(r, os.Stdin, os.Stdout, *history).run()
 	}
 }
 
 type runner struct {
-	i *lox.Interpreter
+	i   *lox.Interpreter
+	vm  *vm.VM
+	dbg *debugger
+	// Where errors are reported, in text format.
+	out io.Writer
 }
 
 func newRunner() *runner {
-	return &runner{
-		i: lox.NewInterpreter(),
+	var opts lox.Options
+	if *deterministic {
+		opts = lox.DeterministicOptions(*seed)
+	}
+	r := &runner{
+		i:   lox.NewInterpreter(opts),
+		out: os.Stdout,
+	}
+	if *backend == "vm" {
+		r.vm = vm.New()
+		if *deterministic {
+			r.vm.SetClock(lox.FakeClock())
+			r.vm.SetRandSource(rand.NewSource(*seed))
+		}
+	}
+	if *debug {
+		r.dbg = newDebugger(r.i)
+	}
+	return r
+}
+
+func (r *runner) setSearchPath(dirs ...string) {
+	r.i.SetSearchPath(dirs...)
+	if r.vm != nil {
+		r.vm.SetSearchPath(dirs...)
 	}
 }
 
@@ -38,50 +98,84 @@ func (r *runner) runFile(path string) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if r.dbg != nil {
+		r.dbg.setSource(string(bs))
+	}
 	if !r.run(string(bs)) {
 		os.Exit(65)
 	}
 }
 
-func (r *runner) runPrompt() {
-	scanner := bufio.NewScanner(os.Stdin)
-	fmt.Print("> ")
-	for scanner.Scan() {
-		r.run(scanner.Text())
-		fmt.Print("> ")
-	}
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
+func (r *runner) run(text string) bool {
+	stmts, ok := r.parse(text)
+	if !ok {
+		return false
 	}
+	return r.execute(text, stmts)
 }
 
-func (r *runner) run(text string) bool {
-	s := lox.NewScanner(text)
-	tokens, err := s.ScanTokens()
+// parse scans and parses text, reporting errors.
+func (r *runner) parse(text string) ([]lox.Stmt, bool) {
+	tokens, err := lox.NewScanner(text).ScanTokens()
 	if err != nil {
-		fmt.Println(err)
-		return false
+		r.report(text, err)
+		return nil, false
 	}
-	stmts, err1 := lox.NewParser(tokens).Parse()
-	if err1 != nil {
-		expr, err2 := lox.NewParser(tokens).ParseExpression()
-		if err2 != nil {
-			fmt.Println(err1)
-			return false
-		}
-		stmts = []lox.Stmt{lox.PrintStmt{expr}}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		r.report(text, err)
+		return nil, false
 	}
-	fmt.Println(lox.PrintStmts(stmts...))
-	resolver := lox.NewResolver(r.i)
-	err = resolver.Resolve(stmts)
+	return stmts, true
+}
+
+// execute resolves and runs stmts parsed from text, reporting errors.
+func (r *runner) execute(text string, stmts []lox.Stmt) bool {
+	err := lox.NewResolver(r.i).Resolve(stmts)
 	if err != nil {
-		fmt.Println(err)
+		r.report(text, err)
 		return false
 	}
-	err = r.i.Interpret(stmts)
+	if r.vm != nil {
+		// The VM uses the variable positions computed by the resolver.
+		err = r.vm.Interpret(stmts, r.i)
+	} else {
+		err = r.i.Interpret(stmts)
+	}
 	if err != nil {
-		fmt.Println(err)
+		r.report(text, err)
 		return false
 	}
 	return true
 }
+
+func (r *runner) report(text string, err error) {
+	if *format == "json" {
+		reportJSON(err)
+		return
+	}
+	fmt.Fprintln(r.out, lox.FormatError(text, err))
+	for _, frame := range lox.StackTrace(err) {
+		fmt.Fprintf(r.out, "    %v\n", frame)
+	}
+}
+
+// reportFile reports errors in a file processed by a subcommand, in the format given by
+// the -format flag.
+func reportFile(path, text string, err error) {
+	if *format == "json" {
+		reportJSON(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s:\n%s\n", path, lox.FormatError(text, err))
+}
+
+// reportJSON writes the diagnostics of err to stderr as JSON lines.
+func reportJSON(err error) {
+	enc := json.NewEncoder(os.Stderr)
+	for _, d := range lox.Diagnostics(err) {
+		if err := enc.Encode(d); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
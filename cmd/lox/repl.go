@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/typing"
+)
+
+const replHelp = `Commands:
+  :help          print this help
+  :type EXPR     print the inferred type of an expression, without running it
+  :ast INPUT     print the syntax tree of the input, without running it
+  :env           print the global variables
+  :load FILE     run a script, keeping its declarations
+  :history       print the previous inputs
+  :redo N        run the input number N from the history again
+  :cancel        discard an incomplete input
+  :quit          exit
+An input that is incomplete, like a block without its closing brace, continues in the next
+line. Expressions without a ';' are printed.`
+
+// Maximum number of inputs kept in the history file.
+const maxHistory = 1000
+
+var errIncomplete = errors.New("incomplete input")
+
+// repl reads inputs and runs them with a runner, keeping the declarations between inputs.
+type repl struct {
+	r   *runner
+	in  *bufio.Scanner
+	out io.Writer
+	// Previous inputs, and the file where they are kept, if any.
+	history     []string
+	historyPath string
+	// Type checker with the declarations of previous inputs, that is rebuilt from their
+	// statements if it fails.
+	checker *typing.Checker
+	checked [][]lox.Stmt
+}
+
+func newREPL(r *runner, in io.Reader, out io.Writer, historyPath string) *repl {
+	r.out = out
+	r.i.SetStdout(out)
+	if r.vm != nil {
+		r.vm.SetStdout(out)
+	}
+	return &repl{
+		r:           r,
+		in:          bufio.NewScanner(in),
+		out:         out,
+		historyPath: historyPath,
+	}
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lox_history")
+}
+
+// run reads inputs until the end of input or a :quit command.
+func (repl *repl) run() {
+	repl.loadHistory()
+	var lines []string
+	for {
+		if len(lines) == 0 {
+			fmt.Fprint(repl.out, "> ")
+		} else {
+			fmt.Fprint(repl.out, "... ")
+		}
+		if !repl.in.Scan() {
+			break
+		}
+		line := repl.in.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), ":") {
+			// Redos are not recorded, so that they don't refer to themselves.
+			if !isRedo(line) {
+				repl.addHistory(line)
+			}
+			if line == ":cancel" {
+				lines = nil
+				continue
+			}
+			if !repl.command(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+		if len(lines) == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+		text := strings.Join(lines, "\n")
+		if !repl.eval(text) {
+			continue
+		}
+		repl.addHistory(text)
+		lines = nil
+	}
+	fmt.Fprintln(repl.out)
+}
+
+// eval runs an input, returning false if it's incomplete.
+func (repl *repl) eval(text string) bool {
+	stmts, err := parseInput(text)
+	if err == errIncomplete {
+		return false
+	}
+	if err != nil {
+		repl.r.report(text, err)
+		return true
+	}
+	if repl.r.execute(text, stmts) {
+		repl.check(stmts)
+	}
+	return true
+}
+
+// command runs a meta-command, returning false if the REPL should exit.
+func (repl *repl) command(line string) bool {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case ":help":
+		fmt.Fprintln(repl.out, replHelp)
+	case ":quit":
+		return false
+	case ":type":
+		repl.printType(arg)
+	case ":ast":
+		stmts, err := parseInput(arg)
+		if err == errIncomplete {
+			fmt.Fprintln(repl.out, ":ast requires a complete input")
+			break
+		}
+		if err != nil {
+			repl.r.report(arg, err)
+			break
+		}
+		fmt.Fprint(repl.out, lox.PrintStmts(stmts...))
+	case ":env":
+		if repl.r.vm != nil {
+			fmt.Fprintln(repl.out, ":env is only supported by the tree backend")
+			break
+		}
+		for _, v := range repl.r.i.Globals() {
+			fmt.Fprintf(repl.out, "%s = %s\n", v.Name, formatValue(v.Value))
+		}
+	case ":load":
+		bs, err := ioutil.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintln(repl.out, err)
+			break
+		}
+		text := string(bs)
+		stmts, ok := repl.r.parse(text)
+		if ok && repl.r.execute(text, stmts) {
+			repl.check(stmts)
+		}
+	case ":history":
+		for k, input := range repl.history {
+			fmt.Fprintf(repl.out, "%4d  %s\n", k+1, strings.ReplaceAll(input, "\n", "\n      "))
+		}
+	case ":redo":
+		k, err := strconv.Atoi(arg)
+		if err != nil || k < 1 || k > len(repl.history) {
+			fmt.Fprintf(repl.out, "invalid history number %q\n", arg)
+			break
+		}
+		input := repl.history[k-1]
+		if isRedo(input) {
+			fmt.Fprintf(repl.out, "can't redo %q\n", input)
+			break
+		}
+		fmt.Fprintln(repl.out, input)
+		if strings.HasPrefix(input, ":") {
+			return repl.command(input)
+		}
+		if !repl.eval(input) {
+			fmt.Fprintln(repl.out, "incomplete input")
+		}
+	default:
+		fmt.Fprintf(repl.out, "unknown command %q, type :help for the list of commands\n", cmd)
+	}
+	return true
+}
+
+func isRedo(line string) bool {
+	cmd, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+	return cmd == ":redo"
+}
+
+// parseInput parses text as a list of statements or, failing that, as an expression whose
+// value is printed. Returns errIncomplete if the text ends before a statement is complete.
+func parseInput(text string) ([]lox.Stmt, error) {
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		if isIncomplete(text, err) {
+			return nil, errIncomplete
+		}
+		return nil, err
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err == nil {
+		return stmts, nil
+	}
+	if expr, err := lox.NewParser(tokens).ParseExpression(); err == nil {
+		return []lox.Stmt{lox.PrintStmt{Expression: expr, Span: lox.Span{Start: expr.Pos(), End: expr.End()}}}, nil
+	}
+	if isIncomplete(text, err) {
+		return nil, errIncomplete
+	}
+	return nil, err
+}
+
+// isIncomplete returns whether all errors happened because the text ended too early.
+func isIncomplete(text string, err error) bool {
+	for _, d := range lox.Diagnostics(err) {
+		if d.Code != "unterminated-string" && d.Span.Start.Offset < len(text) {
+			return false
+		}
+	}
+	return true
+}
+
+// ----
+
+// check infers the types of statements that were run, so that they are available to :type.
+// Type errors are ignored, since the REPL doesn't require typed programs.
+func (repl *repl) check(stmts []lox.Stmt) {
+	repl.checkTypes(func(c *typing.Checker) error {
+		_, err := c.Check(stmts)
+		return err
+	})
+	if repl.checker != nil {
+		repl.checked = append(repl.checked, stmts)
+	}
+}
+
+// checkTypes runs f with a checker that knows the types of previous inputs. The checker
+// doesn't support all statements, so it's discarded if it fails, and rebuilt from the
+// inputs that it could check before.
+func (repl *repl) checkTypes(f func(c *typing.Checker) error) (err error) {
+	if repl.checker == nil {
+		repl.checker = typing.NewChecker()
+		repl.checker.DefineNatives(repl.r.i.NativeTypes())
+		for _, prev := range repl.checked {
+			repl.checker.Check(prev)
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			repl.checker = nil
+			err = fmt.Errorf("can't check types: %v", r)
+		}
+	}()
+	return f(repl.checker)
+}
+
+func (repl *repl) printType(text string) {
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		repl.r.report(text, err)
+		return
+	}
+	expr, err := lox.NewParser(tokens).ParseExpression()
+	if err != nil {
+		repl.r.report(text, err)
+		return
+	}
+	var t lox.Type
+	err = repl.checkTypes(func(c *typing.Checker) (err error) {
+		t, err = c.CheckExpr(expr)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintln(repl.out, err)
+		return
+	}
+	fmt.Fprintln(repl.out, typing.FormatType(t))
+}
+
+// ----
+
+func (repl *repl) loadHistory() {
+	if repl.historyPath == "" {
+		return
+	}
+	bs, err := ioutil.ReadFile(repl.historyPath)
+	if err != nil {
+		return
+	}
+	// Each input is quoted in its own line, since it may have several lines.
+	for _, line := range strings.Split(string(bs), "\n") {
+		if input, err := strconv.Unquote(line); err == nil {
+			repl.history = append(repl.history, input)
+		}
+	}
+	if n := len(repl.history); n > maxHistory {
+		repl.history = repl.history[n-maxHistory:]
+		repl.writeHistory()
+	}
+}
+
+// writeHistory replaces the history file with the current history.
+func (repl *repl) writeHistory() {
+	var sb strings.Builder
+	for _, input := range repl.history {
+		fmt.Fprintln(&sb, strconv.Quote(input))
+	}
+	ioutil.WriteFile(repl.historyPath, []byte(sb.String()), 0600)
+}
+
+func (repl *repl) addHistory(input string) {
+	repl.history = append(repl.history, input)
+	if repl.historyPath == "" {
+		return
+	}
+	f, err := os.OpenFile(repl.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, strconv.Quote(input))
+}
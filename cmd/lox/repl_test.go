@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// runREPL runs a REPL with the given input lines, and returns its output without prompts.
+func runREPL(t *testing.T, historyPath string, lines ...string) string {
+	var out strings.Builder
+	repl := newREPL(newRunner(), strings.NewReader(strings.Join(lines, "\n")+"\n"), &out, historyPath)
+	repl.run()
+	got := strings.ReplaceAll(out.String(), "... ", "")
+	got = strings.ReplaceAll(got, "> ", "")
+	return strings.TrimSpace(got)
+}
+
+func TestREPL(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.lox")
+	if err := os.WriteFile(script, []byte("var loaded = 42;\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		desc  string
+		lines []string
+		want  string
+	}{
+		{"expression", []string{"1 + 2"}, "3"},
+		{"statements", []string{"var a = 1; print a + 1;"}, "2"},
+		{"multi-line", []string{"fun f(x) {", "  return x * 2;", "}", "f(21)"}, "42"},
+		{"multi-line string", []string{`print "a`, `b";`}, "a\nb"},
+		{"cancel", []string{"if (true) {", ":cancel", "print 1;"}, "1"},
+		{"error", []string{"print );", "print 1;"}, "line 1 at ')': expecting expression\n1 | print );\n  |       ^\n1"},
+		{"type", []string{"fun f(x) { return x * 2; }", ":type f", `:type "a"`}, "(Fun (Number) Number)\nString"},
+		{"ast", []string{":ast print -1;"}, "(print (- 1))"},
+		{"env", []string{"var b = 1;", "var a = \"x\";", ":env"}, "a = \"x\"\nb = 1"},
+		{"load", []string{":load " + script, "loaded"}, "42"},
+		{"redo", []string{"var n = 1;", "n = n + 1;", ":redo 2", "n"}, "n = n + 1;\n3"},
+		{"redo itself", []string{":redo 1", "print 1;", ":redo 1", ":redo 2"}, "invalid history number \"1\"\n1\nprint 1;\n1\ninvalid history number \"2\""},
+		{"quit", []string{":quit", "print 1;"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got := runREPL(t, "", test.lines...)
+			if d := cmp.Diff(test.want, got); d != "" {
+				t.Errorf("(-want, +got)%s", d)
+			}
+		})
+	}
+}
+
+func TestREPLHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	runREPL(t, path, "var a = 1;", "{", "print a;", "}")
+	got := runREPL(t, path, ":history")
+	want := "1  var a = 1;\n   2  {\n      print a;\n      }\n   3  :history"
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("(-want, +got)%s", d)
+	}
+}
+
+func TestREPLHistoryTrim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	var sb strings.Builder
+	for k := 0; k < maxHistory+10; k++ {
+		fmt.Fprintf(&sb, "%q\n", fmt.Sprintf("print %d;", k))
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runREPL(t, path)
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(bs)), "\n")
+	if len(lines) != maxHistory {
+		t.Fatalf("want %d inputs, got %d", maxHistory, len(lines))
+	}
+	if want := `"print 10;"`; lines[0] != want {
+		t.Errorf("want first input %s, got %s", want, lines[0])
+	}
+}
@@ -0,0 +1,336 @@
+package lox
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StepAction is how the execution resumes after the debugger stops.
+type StepAction int
+
+const (
+	// Resume runs until the next breakpoint.
+	Resume StepAction = iota
+	// StepIn stops at the next statement, entering function calls.
+	StepIn
+	// StepOver stops at the next statement in the same function, or in its caller if the
+	// function returns.
+	StepOver
+	// StepOut stops at the next statement in the caller of the current function.
+	StepOut
+)
+
+// StopReason is why the debugger stopped the execution.
+type StopReason int
+
+const (
+	BreakpointStop StopReason = iota
+	StepStop
+	PauseStop
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case BreakpointStop:
+		return "breakpoint"
+	case StepStop:
+		return "step"
+	case PauseStop:
+		return "pause"
+	}
+	return "unknown"
+}
+
+// Stop describes where the execution is paused.
+type Stop struct {
+	Reason StopReason
+	// Statement about to be executed.
+	Stmt Stmt
+	Line int
+	// Path of the module where the statement is defined, or empty for the main script.
+	Module string
+	// Number of active function calls.
+	Depth int
+}
+
+// Variable is a binding visible from a paused execution.
+type Variable struct {
+	Name  string
+	Value any
+}
+
+// Debugger pauses the execution of an Interpreter before statements, calling a function
+// that may inspect the program state and decides how to resume.
+//
+// The stop function is called in the goroutine running the interpreter, and the methods
+// that inspect the state are only valid within it. Breakpoints may be changed, and the
+// execution paused, from any goroutine.
+type Debugger struct {
+	i      *Interpreter
+	onStop func(d *Debugger, stop Stop) StepAction
+	// Global environment of the main script.
+	globals *Environment
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	pause       bool
+
+	hasStopped bool
+	last       Stop
+	action     StepAction
+	// Whether the statement of the last stop finished executing.
+	isLastDone bool
+}
+
+// NewDebugger attaches a new debugger to i, which calls onStop whenever the execution is
+// paused. The execution only stops at breakpoints or after calling Pause.
+func NewDebugger(i *Interpreter, onStop func(d *Debugger, stop Stop) StepAction) *Debugger {
+	d := &Debugger{
+		i:           i,
+		onStop:      onStop,
+		globals:     i.globals,
+		breakpoints: make(map[int]bool),
+		action:      Resume,
+	}
+	i.debugger = d
+	return d
+}
+
+// Detach removes the debugger from the interpreter, so that the execution isn't paused anymore.
+func (d *Debugger) Detach() {
+	d.i.debugger = nil
+}
+
+// SetBreakpoint stops the execution before the statements starting at line in the main script.
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+// ClearBreakpoint removes the breakpoint at line, if any.
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+// Breakpoints returns the lines with breakpoints, in ascending order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Pause stops the execution before the next statement. If called before Interpret, it stops
+// before the first statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pause = true
+}
+
+// ----
+
+// Locals returns the local variables visible from a frame of the call stack, where 0 is the
+// innermost one. Variables are listed from the innermost scope outwards, and in declaration
+// order within each scope. Shadowed variables are omitted.
+func (d *Debugger) Locals(frame int) []Variable {
+	var vars []Variable
+	seen := make(map[string]bool)
+	for env := d.frameEnv(frame); env != nil && !env.isDynamic(); env = env.enclosing {
+		names := d.i.localNames(env)
+		for k, value := range env.locals {
+			name := names[k]
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			vars = append(vars, Variable{name, value})
+		}
+	}
+	return vars
+}
+
+// Globals returns the global variables of the module running in a frame of the call stack,
+// where 0 is the innermost one, ordered by name.
+func (d *Debugger) Globals(frame int) []Variable {
+	env := d.frameEnv(frame)
+	if env == nil {
+		return nil
+	}
+	return sortedVariables(globalEnvironment(env).dynamics)
+}
+
+// Lookup returns the value of the variable called name, as seen from a frame of the call
+// stack, where 0 is the innermost one. Returns false if there's no such variable.
+func (d *Debugger) Lookup(frame int, name string) (any, bool) {
+	for env := d.frameEnv(frame); env != nil; env = env.enclosing {
+		if env.isDynamic() {
+			if value, ok := env.dynamics[name]; ok {
+				return value, true
+			}
+			continue
+		}
+		// Search backwards, so that the latest declaration wins.
+		names := d.i.localNames(env)
+		for k := len(env.locals) - 1; k >= 0; k-- {
+			if names[k] == name {
+				return env.locals[k], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// StackTrace returns the call stack from the innermost frame to the top-level script.
+func (d *Debugger) StackTrace() []StackFrame {
+	stmt := d.last.Stmt
+	// Statements don't have a single token, so create one at the statement location.
+	token := Token{Line: d.last.Line, Span: Span{stmt.Pos(), stmt.End()}}
+	return d.i.callStack(token)
+}
+
+func (d *Debugger) frameEnv(frame int) *Environment {
+	if frame == 0 {
+		return d.i.env
+	}
+	n := len(d.i.frames)
+	if frame < 0 || frame > n {
+		return nil
+	}
+	return d.i.frames[n-frame].env
+}
+
+// globalEnvironment returns the first dynamic environment enclosing env, that contains
+// the globals of the module where the running code was defined.
+func globalEnvironment(env *Environment) *Environment {
+	for !env.isDynamic() {
+		env = env.enclosing
+	}
+	return env
+}
+
+// Module returns the path of the module where the code running in a frame of the call
+// stack was defined, where 0 is the innermost one, or empty for the main script.
+func (d *Debugger) Module(frame int) string {
+	env := d.frameEnv(frame)
+	if env == nil {
+		return ""
+	}
+	env = globalEnvironment(env)
+	if env == d.globals {
+		return ""
+	}
+	for path, m := range d.i.modules {
+		if m.env == env {
+			return path
+		}
+	}
+	// The module is still being loaded.
+	if n := len(d.i.importing); n > 0 {
+		return d.i.importing[n-1].path
+	}
+	return ""
+}
+
+// Members returns the values contained in a list, map, instance, class or module, named by
+// their index, key or field. Returns nil for other values.
+func Members(value any) []Variable {
+	switch v := value.(type) {
+	case *list:
+		vars := make([]Variable, len(v.elements))
+		for k, elem := range v.elements {
+			vars[k] = Variable{fmt.Sprint(k), elem}
+		}
+		return vars
+	case *dict:
+		entries := v.entries.Entries()
+		vars := make([]Variable, len(entries))
+		for k, entry := range entries {
+			vars[k] = Variable{repr(entry.Key.value()), entry.Value}
+		}
+		return vars
+	case *instance:
+		return sortedVariables(v.state.fields)
+	case class:
+		return sortedVariables(v.static.fields)
+	case *module:
+		return sortedVariables(v.env.dynamics)
+	}
+	return nil
+}
+
+func sortedVariables(bindings map[string]any) []Variable {
+	vars := make([]Variable, 0, len(bindings))
+	for name, value := range bindings {
+		vars = append(vars, Variable{name, value})
+	}
+	sort.Slice(vars, func(a, b int) bool { return vars[a].Name < vars[b].Name })
+	return vars
+}
+
+// ----
+
+func (d *Debugger) beforeStmt(stmt Stmt) {
+	if _, ok := stmt.(BlockStmt); ok {
+		// Stop at the statements within the block instead.
+		return
+	}
+	line, depth := stmt.Pos().Line, len(d.i.frames)
+	reason, ok := d.shouldStop(line, depth)
+	if !ok {
+		return
+	}
+	d.hasStopped = true
+	d.last = Stop{reason, stmt, line, d.Module(0), depth}
+	d.isLastDone = false
+	d.action = d.onStop(d, d.last)
+	// Honor cancellations that happened while paused.
+	d.i.checkContext(Span{stmt.Pos(), stmt.End()})
+}
+
+func (d *Debugger) afterStmt(stmt Stmt) {
+	if !d.hasStopped || d.isLastDone || len(d.i.frames) != d.last.Depth {
+		return
+	}
+	if stmt.Pos() == d.last.Stmt.Pos() && stmt.End() == d.last.Stmt.End() {
+		d.isLastDone = true
+	}
+}
+
+// shouldStop returns whether to stop before a statement starting at line, and why.
+//
+// Statements nested within the last stopped statement in the same line, like the body of
+// a single-line loop, are not stopped at, so that each step advances at least one line.
+func (d *Debugger) shouldStop(line, depth int) (StopReason, bool) {
+	d.mu.Lock()
+	pause, isBreakpoint := d.pause, d.breakpoints[line]
+	d.pause = false
+	d.mu.Unlock()
+	if pause {
+		return PauseStop, true
+	}
+	isNewLine := !d.hasStopped || d.isLastDone || line != d.last.Line || depth != d.last.Depth
+	if !isNewLine {
+		return 0, false
+	}
+	if isBreakpoint && globalEnvironment(d.i.env) == d.globals {
+		return BreakpointStop, true
+	}
+	switch d.action {
+	case StepIn:
+		return StepStop, true
+	case StepOver:
+		return StepStop, depth <= d.last.Depth
+	case StepOut:
+		return StepStop, depth < d.last.Depth
+	}
+	return 0, false
+}
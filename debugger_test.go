@@ -0,0 +1,164 @@
+package lox_test
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+)
+
+const debuggedScript = `fun add(a, b) {
+    var sum = a + b;
+    return sum;
+}
+var x = 1;
+var y = add(x, 2);
+var i = 0;
+while (i < 2) {
+    i = i + 1;
+}
+print y;`
+
+func TestDebuggerSteps(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		actions     []lox.StepAction
+		want        []string
+	}{
+		{
+			"step in",
+			nil,
+			[]lox.StepAction{lox.StepIn, lox.StepIn, lox.StepIn, lox.StepIn, lox.StepIn},
+			[]string{"pause 1", "step 5", "step 6", "step 2", "step 3", "step 7"},
+		},
+		{
+			"step over",
+			nil,
+			[]lox.StepAction{lox.StepOver, lox.StepOver, lox.StepOver, lox.StepOver, lox.StepOver, lox.StepOver},
+			[]string{"pause 1", "step 5", "step 6", "step 7", "step 8", "step 9", "step 9"},
+		},
+		{
+			"step out",
+			[]int{2},
+			[]lox.StepAction{lox.Resume, lox.StepOut},
+			[]string{"pause 1", "breakpoint 2", "step 7"},
+		},
+		{
+			"breakpoints",
+			[]int{3, 9},
+			nil,
+			[]string{"pause 1", "breakpoint 3", "breakpoint 9", "breakpoint 9"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			i := lox.NewInterpreter(lox.Options{Stdout: io.Discard})
+			stmts := resolveStmts(t, i, debuggedScript)
+			var got []string
+			actions := test.actions
+			d := lox.NewDebugger(i, func(d *lox.Debugger, stop lox.Stop) lox.StepAction {
+				got = append(got, fmt.Sprintf("%v %d", stop.Reason, stop.Line))
+				if len(actions) == 0 {
+					return lox.Resume
+				}
+				action := actions[0]
+				actions = actions[1:]
+				return action
+			})
+			for _, line := range test.breakpoints {
+				d.SetBreakpoint(line)
+			}
+			d.Pause()
+			if err := i.Interpret(stmts); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
+
+func TestDebuggerInspection(t *testing.T) {
+	i := lox.NewInterpreter(lox.Options{Stdout: io.Discard})
+	stmts := resolveStmts(t, i, debuggedScript)
+	var locals, callerLocals []lox.Variable
+	var x, a any
+	var hasA bool
+	var trace []string
+	d := lox.NewDebugger(i, func(d *lox.Debugger, stop lox.Stop) lox.StepAction {
+		locals = d.Locals(0)
+		callerLocals = d.Locals(1)
+		x, _ = d.Lookup(0, "x")
+		a, hasA = d.Lookup(1, "a")
+		for _, frame := range d.StackTrace() {
+			trace = append(trace, frame.String())
+		}
+		return lox.Resume
+	})
+	d.SetBreakpoint(3)
+	if err := i.Interpret(stmts); err != nil {
+		t.Fatal(err)
+	}
+	wantLocals := []lox.Variable{{"a", 1.0}, {"b", 2.0}, {"sum", 3.0}}
+	if diff := cmp.Diff(wantLocals, locals); diff != "" {
+		t.Errorf("locals: (-want, +got)%s", diff)
+	}
+	if len(callerLocals) != 0 {
+		t.Errorf("want no locals in the top-level script, got %v", callerLocals)
+	}
+	if x != 1.0 {
+		t.Errorf("want x = 1, got %v", x)
+	}
+	if hasA {
+		t.Errorf("want 'a' to be undefined in the caller, got %v", a)
+	}
+	wantTrace := []string{"at add (line 3)", "at <script> (line 6)"}
+	if diff := cmp.Diff(wantTrace, trace); diff != "" {
+		t.Errorf("stack trace: (-want, +got)%s", diff)
+	}
+}
+
+func TestDebuggerScopes(t *testing.T) {
+	i := lox.NewInterpreter(lox.Options{Stdout: io.Discard})
+	stmts := resolveStmts(t, i, `class A {
+    f() { return 1; }
+}
+class B < A {
+    f(n) {
+        try {
+            throw n;
+        } catch (e) {
+            var m = e;
+            print m;
+            var later = 2;
+        }
+        return super.f();
+    }
+}
+B().f(3);`)
+	var names []string
+	var m any
+	d := lox.NewDebugger(i, func(d *lox.Debugger, stop lox.Stop) lox.StepAction {
+		for _, v := range d.Locals(0) {
+			names = append(names, v.Name)
+		}
+		m, _ = d.Lookup(0, "m")
+		return lox.Resume
+	})
+	d.SetBreakpoint(10)
+	if err := i.Interpret(stmts); err != nil {
+		t.Fatal(err)
+	}
+	// Variables declared after the stop are not listed.
+	wantNames := []string{"e", "m", "n", "this", "super"}
+	if diff := cmp.Diff(wantNames, names); diff != "" {
+		t.Errorf("locals: (-want, +got)%s", diff)
+	}
+	if m != 3.0 {
+		t.Errorf("want m = 3, got %v", m)
+	}
+}
@@ -0,0 +1,119 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Severity of a diagnostic.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Phase is the processing step where a diagnostic was reported.
+type Phase string
+
+const (
+	ScanPhase    Phase = "scan"
+	ParsePhase   Phase = "parse"
+	ResolvePhase Phase = "resolve"
+	LintPhase    Phase = "lint"
+	TypePhase    Phase = "type"
+	RuntimePhase Phase = "runtime"
+)
+
+// Diagnostic is a structured representation of an error, independent of the phase that
+// produced it.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Phase    Phase    `json:"phase,omitempty"`
+	// Code is a short, stable identifier for the kind of problem, like "unused-variable".
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	Span    Span   `json:"span"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %s: %s [%s]", d.Span.Start, d.Severity, d.Message, d.Code)
+}
+
+// Diagnostics converts err into a list of diagnostics, flattening lists of errors.
+// Errors not produced by this module are converted into diagnostics without phase or location.
+func Diagnostics(err error) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+	if errs, ok := err.(interface{ Unwrap() []error }); ok {
+		var ds []Diagnostic
+		for _, err := range errs.Unwrap() {
+			ds = append(ds, Diagnostics(err)...)
+		}
+		return ds
+	}
+	var d interface{ Diagnostic() Diagnostic }
+	if errors.As(err, &d) {
+		return []Diagnostic{d.Diagnostic()}
+	}
+	return []Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+}
+
+// FormatError renders err followed by the excerpt of source where it happened, with the
+// offending range underlined by carets. Lists of errors are rendered one after the other.
+// Errors without a location are rendered with their message only.
+func FormatError(source string, err error) string {
+	var b strings.Builder
+	formatError(&b, strings.Split(source, "\n"), err)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func formatError(b *strings.Builder, lines []string, err error) {
+	if errs, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range errs.Unwrap() {
+			formatError(b, lines, err)
+		}
+		return
+	}
+	fmt.Fprintln(b, err)
+	span, ok := errorSpan(err)
+	if !ok || span.Start.Line > len(lines) {
+		return
+	}
+	line := []rune(lines[span.Start.Line-1])
+	start := span.Start.Column - 1
+	if start > len(line) {
+		start = len(line)
+	}
+	end := len(line)
+	if span.End.Line == span.Start.Line && span.End.Column-1 < end {
+		end = span.End.Column - 1
+	}
+	width := end - start
+	if width < 1 {
+		width = 1
+	}
+	// Keep tabs in the prefix, so that carets are aligned irrespective of tab width.
+	prefix := []rune(strings.Repeat(" ", start))
+	for i, ch := range line[:start] {
+		if ch == '\t' {
+			prefix[i] = '\t'
+		}
+	}
+	lineNum := fmt.Sprint(span.Start.Line)
+	margin := strings.Repeat(" ", len(lineNum))
+	fmt.Fprintf(b, "%s | %s\n", lineNum, string(line))
+	fmt.Fprintf(b, "%s | %s%s\n", margin, string(prefix), strings.Repeat("^", width))
+}
+
+// errorSpan returns the location of an error, if it has one.
+func errorSpan(err error) (Span, bool) {
+	var located interface{ Span() Span }
+	if !errors.As(err, &located) {
+		return Span{}, false
+	}
+	span := located.Span()
+	return span, span.Start.Line > 0
+}
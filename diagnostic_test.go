@@ -0,0 +1,112 @@
+package lox_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+	"github.com/lithammer/dedent"
+)
+
+func TestFormatError(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{
+			"var a = 1 % 2;",
+			dedent.Dedent(`
+                line 1: unexpected character: %
+                1 | var a = 1 % 2;
+                  |           ^`)[1:],
+		},
+		{
+			"print (1 + 2;",
+			dedent.Dedent(`
+                line 1 at ';': expecting ')' after expression
+                1 | print (1 + 2;
+                  |             ^`)[1:],
+		},
+		{
+			"print 1\n",
+			dedent.Dedent(`
+                line 2 at end: expecting ';' after expression
+                2 | 
+                  | ^`)[1:],
+		},
+		{
+			"{\n\tvar x = 1;\n\tvar x = 2;\n\tprint x;\n}",
+			"line 3 at 'x': already a variable with this name in scope\n" +
+				"3 | \tvar x = 2;\n" +
+				"  | \t    ^",
+		},
+		{
+			"var x = 1;\nprint -\"abc\";",
+			dedent.Dedent(`
+                token '-' in line 2: operand must be number
+                2 | print -"abc";
+                  |       ^`)[1:],
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			_, err := runLox(test.text, nil)
+			if err == nil {
+				t.Fatalf("want err, got nil")
+			}
+			if d := cmp.Diff(test.want, lox.FormatError(test.text, err)); d != "" {
+				t.Errorf("(-want, +got)%s", d)
+			}
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"var a = 1 % 2;", []string{"1:11: error: unexpected character: % [unexpected-character]"}},
+		{"print (1;", []string{"1:9: error: expecting ')' after expression [unexpected-token]"}},
+		{
+			"break;\n{\n  continue;\n}",
+			[]string{
+				"1:1: error: 'break' can only be used within loops [break-outside-loop]",
+				"3:3: error: 'continue' can only be used within loops [continue-outside-loop]",
+			},
+		},
+		{"print 1 + nil;", []string{"1:9: error: operands must be two numbers or two strings [runtime-error]"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			_, err := runLox(test.text, nil)
+			var got []string
+			for _, d := range lox.Diagnostics(err) {
+				got = append(got, d.String())
+			}
+			if d := cmp.Diff(test.want, got); d != "" {
+				t.Errorf("(-want, +got)%s", d)
+			}
+		})
+	}
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	_, err := runLox("print -nil;", nil)
+	ds := lox.Diagnostics(err)
+	if len(ds) != 1 {
+		t.Fatalf("want 1 diagnostic, got %v", ds)
+	}
+	bs, err := json.Marshal(ds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"severity":"error","phase":"runtime","code":"runtime-error","message":"operand must be number",` +
+		`"span":{"start":{"offset":6,"line":1,"column":7},"end":{"offset":7,"line":1,"column":8}}}`
+	if d := cmp.Diff(want, string(bs)); d != "" {
+		t.Errorf("(-want, +got)%s", d)
+	}
+}
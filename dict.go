@@ -0,0 +1,123 @@
+package lox
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brunokim/kilox/ordered"
+)
+
+// dict is a map value, whose entries are kept in insertion order.
+type dict struct {
+	entries *ordered.Map[mapKey, any]
+}
+
+func newDict() *dict {
+	return &dict{entries: ordered.MakeMap[mapKey, any]()}
+}
+
+func (d *dict) String() string {
+	var b strings.Builder
+	b.WriteRune('{')
+	for i, entry := range d.entries.Entries() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s: %s", repr(entry.Key.value()), repr(entry.Value))
+	}
+	b.WriteRune('}')
+	return b.String()
+}
+
+func (d *dict) getIndex(bracket Token, key any) any {
+	value, ok := d.entries.Get(toMapKey(bracket, key))
+	if !ok {
+		panic(runtimeError{bracket, fmt.Sprintf("key %s not found in map", repr(key))})
+	}
+	return value
+}
+
+func (d *dict) setIndex(bracket Token, key any, value any) {
+	d.entries.Put(toMapKey(bracket, key), value)
+}
+
+// put adds an entry to the map, returning an error if the key is not hashable.
+func (d *dict) put(key any, value any) error {
+	k, ok := hashKey(key)
+	if !ok {
+		return fmt.Errorf("unhashable map key %[1]v (%[1]T)", key)
+	}
+	d.entries.Put(k, value)
+	return nil
+}
+
+func (d *dict) keys() *list {
+	keys := d.entries.Keys()
+	elems := make([]any, len(keys))
+	for i, key := range keys {
+		elems[i] = key.value()
+	}
+	return newList(elems)
+}
+
+// ----
+
+type keyKind int
+
+const (
+	nilKey keyKind = iota
+	boolKey
+	numberKey
+	stringKey
+	instanceKey
+)
+
+// mapKey is a comparable representation of a hashable Lox value.
+// Instances are hashed by identity.
+type mapKey struct {
+	kind     keyKind
+	boolean  bool
+	number   float64
+	str      string
+	instance *instance
+}
+
+func toMapKey(token Token, key any) mapKey {
+	k, ok := hashKey(key)
+	if !ok {
+		panic(runtimeError{token, fmt.Sprintf("unhashable map key %[1]v (%[1]T)", key)})
+	}
+	return k
+}
+
+func hashKey(key any) (mapKey, bool) {
+	switch k := key.(type) {
+	case nil:
+		return mapKey{kind: nilKey}, true
+	case bool:
+		return mapKey{kind: boolKey, boolean: k}, true
+	case float64:
+		return mapKey{kind: numberKey, number: k}, true
+	case string:
+		return mapKey{kind: stringKey, str: k}, true
+	case *instance:
+		return mapKey{kind: instanceKey, instance: k}, true
+	default:
+		return mapKey{}, false
+	}
+}
+
+func (k mapKey) value() any {
+	switch k.kind {
+	case boolKey:
+		return k.boolean
+	case numberKey:
+		return k.number
+	case stringKey:
+		return k.str
+	case instanceKey:
+		return k.instance
+	default:
+		return nil
+	}
+}
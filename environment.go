@@ -15,7 +15,12 @@ const (
 type Environment struct {
 	enclosing *Environment
 	locals    []any
-	dynamics  map[string]any
+	// Statements run within a static environment, whose scope records the names of locals.
+	stmts []Stmt
+	// Names of locals in static environments that don't run statements, like the ones
+	// binding 'this' and 'super'.
+	names    []string
+	dynamics map[string]any
 }
 
 func NewEnvironment(t dynType) *Environment {
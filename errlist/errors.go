@@ -16,3 +16,12 @@ func (errs Of[T]) Error() string {
 	}
 	return strings.Join(msgs, "\n")
 }
+
+// Unwrap returns the list of errors, so that they may be inspected individually.
+func (errs Of[T]) Unwrap() []error {
+	result := make([]error, len(errs))
+	for i, err := range errs {
+		result[i] = err
+	}
+	return result
+}
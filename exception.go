@@ -0,0 +1,51 @@
+package lox
+
+import "fmt"
+
+// throwSignal unwinds the stack until the innermost 'try' statement with a 'catch' clause.
+type throwSignal struct {
+	keyword Token
+	value   any
+}
+
+// errorClass is the class of values representing runtime errors caught by a 'catch' clause.
+var errorClass = newClass(newMetaClass("Error"), nil)
+
+func newErrorValue(err runtimeError) *instance {
+	is := newInstance(errorClass)
+	is.set(Token{Lexeme: "message"}, err.msg)
+	is.set(Token{Lexeme: "line"}, float64(err.token.Line))
+	return is
+}
+
+// uncaughtError converts a thrown value that reached the top-level into a runtime error.
+func uncaughtError(signal throwSignal) runtimeError {
+	value := signal.value
+	if value == nil {
+		value = "nil"
+	}
+	return runtimeError{signal.keyword, fmt.Sprintf("uncaught exception: %v", value)}
+}
+
+// runTryBody executes the body of a 'try' statement, returning the value thrown within it,
+// if any. Runtime errors are caught as Error instances; other panics are propagated.
+func (i *Interpreter) runTryBody(stmt TryStmt) (value any, caught bool) {
+	depth := len(i.frames)
+	defer func() {
+		if r := recover(); r != nil {
+			switch signal := r.(type) {
+			case throwSignal:
+				value = signal.value
+			case runtimeError:
+				value = newErrorValue(signal)
+			default:
+				panic(r)
+			}
+			// Discard frames from calls that were unwound.
+			i.frames = i.frames[:depth]
+			caught = true
+		}
+	}()
+	i.executeBlock(stmt.Body, i.env.Child(staticEnvironment))
+	return nil, false
+}
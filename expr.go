@@ -1,10 +1,12 @@
 // Generated file, do not modify
-// Invocation: gen_ast -spec ./cmd/gen_ast/expr.spec -dest expr.go -extensions typename
+// Invocation: gen_ast -spec ./cmd/gen_ast/expr.spec -dest expr.go -extensions typename,span
 package lox
 
 type Expr interface {
 	Accept(v exprVisitor)
 	TypeName() string
+	Pos() Position
+	End() Position
 }
 
 type exprVisitor interface {
@@ -20,68 +22,119 @@ type exprVisitor interface {
 	VisitGetExpr(e *GetExpr)
 	VisitSetExpr(e *SetExpr)
 	VisitThisExpr(e *ThisExpr)
+	VisitSuperExpr(e *SuperExpr)
+	VisitListExpr(e *ListExpr)
+	VisitIndexExpr(e *IndexExpr)
+	VisitSetIndexExpr(e *SetIndexExpr)
+	VisitMapExpr(e *MapExpr)
 }
 
 type BinaryExpr struct {
 	Left     Expr
 	Operator Token
 	Right    Expr
+	Span     Span
 }
 
 type GroupingExpr struct {
 	Expression Expr
+	Span       Span
 }
 
 type LiteralExpr struct {
 	Token Token
 	Value any
+	Span  Span
 }
 
 type UnaryExpr struct {
 	Operator Token
 	Right    Expr
+	Span     Span
 }
 
 type VariableExpr struct {
 	Name Token
+	Span Span
 }
 
 type AssignmentExpr struct {
 	Name  Token
 	Value Expr
+	Span  Span
 }
 
 type LogicExpr struct {
 	Left     Expr
 	Operator Token
 	Right    Expr
+	Span     Span
 }
 
 type CallExpr struct {
 	Callee Expr
 	Paren  Token
 	Args   []Expr
+	Span   Span
 }
 
 type FunctionExpr struct {
 	Keyword Token
 	Params  []Token
 	Body    []Stmt
+	Span    Span
 }
 
 type GetExpr struct {
 	Object Expr
 	Name   Token
+	Span   Span
 }
 
 type SetExpr struct {
 	Object Expr
 	Name   Token
 	Value  Expr
+	Span   Span
 }
 
 type ThisExpr struct {
 	Keyword Token
+	Span    Span
+}
+
+type SuperExpr struct {
+	Keyword Token
+	Method  Token
+	Span    Span
+}
+
+type ListExpr struct {
+	Bracket  Token
+	Elements []Expr
+	Span     Span
+}
+
+type IndexExpr struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+	Span    Span
+}
+
+type SetIndexExpr struct {
+	Object  Expr
+	Bracket Token
+	Index   Expr
+	Value   Expr
+	Span    Span
+}
+
+type MapExpr struct {
+	Brace  Token
+	Keys   []Expr
+	Values []Expr
+	Span   Span
 }
 
 func (e *BinaryExpr) Accept(v exprVisitor) {
@@ -132,6 +185,26 @@ func (e *ThisExpr) Accept(v exprVisitor) {
 	v.VisitThisExpr(e)
 }
 
+func (e *SuperExpr) Accept(v exprVisitor) {
+	v.VisitSuperExpr(e)
+}
+
+func (e *ListExpr) Accept(v exprVisitor) {
+	v.VisitListExpr(e)
+}
+
+func (e *IndexExpr) Accept(v exprVisitor) {
+	v.VisitIndexExpr(e)
+}
+
+func (e *SetIndexExpr) Accept(v exprVisitor) {
+	v.VisitSetIndexExpr(e)
+}
+
+func (e *MapExpr) Accept(v exprVisitor) {
+	v.VisitMapExpr(e)
+}
+
 func (*BinaryExpr) TypeName() string     { return "binary" }
 func (*GroupingExpr) TypeName() string   { return "grouping" }
 func (*LiteralExpr) TypeName() string    { return "literal" }
@@ -144,3 +217,43 @@ func (*FunctionExpr) TypeName() string   { return "function" }
 func (*GetExpr) TypeName() string        { return "get" }
 func (*SetExpr) TypeName() string        { return "set" }
 func (*ThisExpr) TypeName() string       { return "this" }
+func (*SuperExpr) TypeName() string      { return "super" }
+func (*ListExpr) TypeName() string       { return "list" }
+func (*IndexExpr) TypeName() string      { return "index" }
+func (*SetIndexExpr) TypeName() string   { return "setindex" }
+func (*MapExpr) TypeName() string        { return "map" }
+
+func (e *BinaryExpr) Pos() Position     { return e.Span.Start }
+func (e *BinaryExpr) End() Position     { return e.Span.End }
+func (e *GroupingExpr) Pos() Position   { return e.Span.Start }
+func (e *GroupingExpr) End() Position   { return e.Span.End }
+func (e *LiteralExpr) Pos() Position    { return e.Span.Start }
+func (e *LiteralExpr) End() Position    { return e.Span.End }
+func (e *UnaryExpr) Pos() Position      { return e.Span.Start }
+func (e *UnaryExpr) End() Position      { return e.Span.End }
+func (e *VariableExpr) Pos() Position   { return e.Span.Start }
+func (e *VariableExpr) End() Position   { return e.Span.End }
+func (e *AssignmentExpr) Pos() Position { return e.Span.Start }
+func (e *AssignmentExpr) End() Position { return e.Span.End }
+func (e *LogicExpr) Pos() Position      { return e.Span.Start }
+func (e *LogicExpr) End() Position      { return e.Span.End }
+func (e *CallExpr) Pos() Position       { return e.Span.Start }
+func (e *CallExpr) End() Position       { return e.Span.End }
+func (e *FunctionExpr) Pos() Position   { return e.Span.Start }
+func (e *FunctionExpr) End() Position   { return e.Span.End }
+func (e *GetExpr) Pos() Position        { return e.Span.Start }
+func (e *GetExpr) End() Position        { return e.Span.End }
+func (e *SetExpr) Pos() Position        { return e.Span.Start }
+func (e *SetExpr) End() Position        { return e.Span.End }
+func (e *ThisExpr) Pos() Position       { return e.Span.Start }
+func (e *ThisExpr) End() Position       { return e.Span.End }
+func (e *SuperExpr) Pos() Position      { return e.Span.Start }
+func (e *SuperExpr) End() Position      { return e.Span.End }
+func (e *ListExpr) Pos() Position       { return e.Span.Start }
+func (e *ListExpr) End() Position       { return e.Span.End }
+func (e *IndexExpr) Pos() Position      { return e.Span.Start }
+func (e *IndexExpr) End() Position      { return e.Span.End }
+func (e *SetIndexExpr) Pos() Position   { return e.Span.Start }
+func (e *SetIndexExpr) End() Position   { return e.Span.End }
+func (e *MapExpr) Pos() Position        { return e.Span.Start }
+func (e *MapExpr) End() Position        { return e.Span.End }
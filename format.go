@@ -0,0 +1,522 @@
+package lox
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format returns the canonical source of a Lox program, preserving its comments.
+//
+// Statements are written one per line, with blocks indented by 4 spaces, and at most one
+// blank line between statements. Comments are kept either on their own line before the
+// following statement, or at the end of the line where a statement ends; in the latter case,
+// comments in consecutive lines are aligned. Comments within an expression that spans
+// several lines are moved after its statement.
+func Format(source string) (string, error) {
+	s := NewScanner(source)
+	tokens, err := s.ScanTokens()
+	if err != nil {
+		return "", err
+	}
+	stmts, err := NewParser(tokens).Parse()
+	if err != nil {
+		return "", err
+	}
+	f := &formatter{source: source, tokens: tokens, comments: s.Comments()}
+	for _, stmt := range stmts {
+		f.stmt(stmt)
+	}
+	f.leadingComments(len(source) + 1)
+	return f.String(), nil
+}
+
+type formatter struct {
+	source string
+	tokens []Token
+	// Comments not yet written.
+	comments []Token
+	lines    []formattedLine
+	// Current line.
+	code        strings.Builder
+	comment     string
+	indentation int
+	// Source line of the last written statement or comment, or 0 at the start of a block.
+	lastLine int
+}
+
+type formattedLine struct {
+	code, comment string
+}
+
+func (f *formatter) String() string {
+	var sb strings.Builder
+	for i := 0; i < len(f.lines); {
+		// Align the comments of consecutive lines.
+		j, width := i, 0
+		for ; j < len(f.lines) && f.lines[j].comment != ""; j++ {
+			if w := lineWidth(f.lines[j].code); w > width {
+				width = w
+			}
+		}
+		if j == i {
+			sb.WriteString(f.lines[i].code)
+			sb.WriteRune('\n')
+			i++
+			continue
+		}
+		for ; i < j; i++ {
+			line := f.lines[i]
+			padding := strings.Repeat(" ", width-lineWidth(line.code)+1)
+			sb.WriteString(line.code + padding + line.comment + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// lineWidth returns the width of the last line of code, that may have several lines
+// within a string literal.
+func lineWidth(code string) int {
+	if i := strings.LastIndexByte(code, '\n'); i >= 0 {
+		code = code[i+1:]
+	}
+	return utf8.RuneCountInString(code)
+}
+
+// ----
+
+func (f *formatter) write(parts ...string) {
+	for _, part := range parts {
+		f.code.WriteString(part)
+	}
+}
+
+func (f *formatter) startLine() {
+	f.write(strings.Repeat("    ", f.indentation))
+}
+
+func (f *formatter) newline() {
+	f.lines = append(f.lines, formattedLine{f.code.String(), f.comment})
+	f.code.Reset()
+	f.comment = ""
+}
+
+// blankLine writes a blank line if there was at least one between the last written
+// statement and the given source line.
+func (f *formatter) blankLine(line int) {
+	if f.lastLine > 0 && line > f.lastLine+1 {
+		f.newline()
+	}
+}
+
+// leadingComments writes all pending comments before offset in their own lines.
+func (f *formatter) leadingComments(offset int) {
+	for len(f.comments) > 0 && f.comments[0].Span.Start.Offset < offset {
+		c := f.comments[0]
+		f.comments = f.comments[1:]
+		f.blankLine(c.Line)
+		f.startLine()
+		f.write(c.Lexeme)
+		f.newline()
+		if c.Line > f.lastLine {
+			f.lastLine = c.Line
+		}
+	}
+}
+
+// trailingComment sets the comment of the current line to the first pending comment
+// in the same line as end, and after it, if there's no code between them.
+func (f *formatter) trailingComment(end Position) {
+	next := f.nextTokenOffset(end.Offset)
+	for i, c := range f.comments {
+		if c.Line > end.Line || c.Span.Start.Offset > next {
+			return
+		}
+		if c.Line == end.Line && c.Span.Start.Offset >= end.Offset {
+			f.comment = c.Lexeme
+			f.comments = append(f.comments[:i], f.comments[i+1:]...)
+			return
+		}
+	}
+}
+
+// nextTokenOffset returns the start of the first token at or after offset.
+func (f *formatter) nextTokenOffset(offset int) int {
+	i := sort.Search(len(f.tokens), func(i int) bool {
+		return f.tokens[i].Span.Start.Offset >= offset
+	})
+	if i == len(f.tokens) {
+		return len(f.source)
+	}
+	return f.tokens[i].Span.Start.Offset
+}
+
+func (f *formatter) hasCommentBefore(offset int) bool {
+	return len(f.comments) > 0 && f.comments[0].Span.Start.Offset < offset
+}
+
+// ----
+
+// stmt writes a statement in its own lines, preceded by its leading comments.
+func (f *formatter) stmt(stmt Stmt) {
+	f.line(stmt.Pos(), stmt.End(), func() { stmt.Accept(f) })
+}
+
+func (f *formatter) line(start, end Position, write func()) {
+	f.leadingComments(start.Offset)
+	f.blankLine(start.Line)
+	f.startLine()
+	write()
+	f.trailingComment(end)
+	f.newline()
+	f.lastLine = end.Line
+}
+
+// block writes a brace-delimited list of statements, ending at the given position.
+func (f *formatter) block(stmts []Stmt, end Position) {
+	if len(stmts) == 0 && !f.hasCommentBefore(end.Offset) {
+		f.write("{}")
+		return
+	}
+	f.write("{")
+	f.newline()
+	f.indentation++
+	f.lastLine = 0
+	for _, stmt := range stmts {
+		f.stmt(stmt)
+	}
+	f.leadingComments(end.Offset)
+	f.indentation--
+	f.startLine()
+	f.write("}")
+}
+
+func (f *formatter) expr(expr Expr) {
+	expr.Accept(f)
+}
+
+func (f *formatter) exprList(exprs []Expr) {
+	for i, expr := range exprs {
+		if i > 0 {
+			f.write(", ")
+		}
+		f.expr(expr)
+	}
+}
+
+func (f *formatter) function(name string, params []Token, body []Stmt, end Position) {
+	f.write(name, "(")
+	for i, param := range params {
+		if i > 0 {
+			f.write(", ")
+		}
+		f.write(param.Lexeme)
+	}
+	f.write(") ")
+	f.block(body, end)
+}
+
+// isFor reports whether the loop starting at pos was written as a 'for' statement.
+func (f *formatter) isFor(pos Position) bool {
+	return strings.HasPrefix(f.source[pos.Offset:], "for")
+}
+
+// forLoop writes a 'for' statement that was desugared into an optional initializer and a loop.
+func (f *formatter) forLoop(init Stmt, loop LoopStmt) {
+	f.write("for (")
+	switch init := init.(type) {
+	case nil:
+		f.write(";")
+	case ExpressionStmt:
+		f.expr(init.Expression)
+		f.write(";")
+	default:
+		init.Accept(f)
+	}
+	if lit, ok := loop.Condition.(*LiteralExpr); !ok || lit.Token.TokenType != Semicolon {
+		// An empty condition is parsed as a 'true' literal at the preceding semicolon.
+		f.write(" ")
+		f.expr(loop.Condition)
+	}
+	f.write(";")
+	if loop.OnLoop != nil {
+		f.write(" ")
+		f.expr(loop.OnLoop)
+	}
+	f.write(") ")
+	loop.Body.Accept(f)
+}
+
+// ---- Expr
+
+func (f *formatter) VisitBinaryExpr(expr *BinaryExpr) {
+	f.expr(expr.Left)
+	f.write(" ", expr.Operator.Lexeme, " ")
+	f.expr(expr.Right)
+}
+
+func (f *formatter) VisitGroupingExpr(expr *GroupingExpr) {
+	f.write("(")
+	f.expr(expr.Expression)
+	f.write(")")
+}
+
+func (f *formatter) VisitLiteralExpr(expr *LiteralExpr) {
+	f.write(expr.Token.Lexeme)
+}
+
+func (f *formatter) VisitUnaryExpr(expr *UnaryExpr) {
+	f.write(expr.Operator.Lexeme)
+	f.expr(expr.Right)
+}
+
+func (f *formatter) VisitVariableExpr(expr *VariableExpr) {
+	f.write(expr.Name.Lexeme)
+}
+
+func (f *formatter) VisitAssignmentExpr(expr *AssignmentExpr) {
+	f.write(expr.Name.Lexeme, " = ")
+	f.expr(expr.Value)
+}
+
+func (f *formatter) VisitLogicExpr(expr *LogicExpr) {
+	f.expr(expr.Left)
+	f.write(" ", expr.Operator.Lexeme, " ")
+	f.expr(expr.Right)
+}
+
+func (f *formatter) VisitCallExpr(expr *CallExpr) {
+	f.expr(expr.Callee)
+	f.write("(")
+	f.exprList(expr.Args)
+	f.write(")")
+}
+
+func (f *formatter) VisitFunctionExpr(expr *FunctionExpr) {
+	f.function("fun ", expr.Params, expr.Body, expr.Span.End)
+}
+
+func (f *formatter) VisitGetExpr(expr *GetExpr) {
+	f.expr(expr.Object)
+	f.write(".", expr.Name.Lexeme)
+}
+
+func (f *formatter) VisitSetExpr(expr *SetExpr) {
+	f.expr(expr.Object)
+	f.write(".", expr.Name.Lexeme, " = ")
+	f.expr(expr.Value)
+}
+
+func (f *formatter) VisitThisExpr(expr *ThisExpr) {
+	f.write("this")
+}
+
+func (f *formatter) VisitSuperExpr(expr *SuperExpr) {
+	f.write("super.", expr.Method.Lexeme)
+}
+
+func (f *formatter) VisitListExpr(expr *ListExpr) {
+	f.write("[")
+	f.exprList(expr.Elements)
+	f.write("]")
+}
+
+func (f *formatter) VisitIndexExpr(expr *IndexExpr) {
+	f.expr(expr.Object)
+	f.write("[")
+	f.expr(expr.Index)
+	f.write("]")
+}
+
+func (f *formatter) VisitSetIndexExpr(expr *SetIndexExpr) {
+	f.expr(expr.Object)
+	f.write("[")
+	f.expr(expr.Index)
+	f.write("] = ")
+	f.expr(expr.Value)
+}
+
+func (f *formatter) VisitMapExpr(expr *MapExpr) {
+	f.write("{")
+	for i, key := range expr.Keys {
+		if i > 0 {
+			f.write(", ")
+		}
+		f.expr(key)
+		f.write(": ")
+		f.expr(expr.Values[i])
+	}
+	f.write("}")
+}
+
+// ---- Stmt
+
+func (f *formatter) VisitExpressionStmt(stmt ExpressionStmt) {
+	f.expr(stmt.Expression)
+	f.write(";")
+}
+
+func (f *formatter) VisitPrintStmt(stmt PrintStmt) {
+	f.write("print ")
+	f.expr(stmt.Expression)
+	f.write(";")
+}
+
+func (f *formatter) VisitVarStmt(stmt VarStmt) {
+	f.write("var ", stmt.Name.Lexeme)
+	if stmt.Init != nil {
+		f.write(" = ")
+		f.expr(stmt.Init)
+	}
+	f.write(";")
+}
+
+func (f *formatter) VisitIfStmt(stmt IfStmt) {
+	f.write("if (")
+	f.expr(stmt.Condition)
+	f.write(") ")
+	stmt.Then.Accept(f)
+	if stmt.Else == nil {
+		return
+	}
+	if _, ok := stmt.Then.(BlockStmt); ok && !f.hasCommentBefore(stmt.Else.Pos().Offset) {
+		f.write(" else ")
+	} else {
+		// Comments before 'else' are kept after the 'then' branch.
+		f.trailingComment(stmt.Then.End())
+		f.newline()
+		f.lastLine = stmt.Then.End().Line
+		f.leadingComments(stmt.Else.Pos().Offset)
+		f.startLine()
+		f.write("else ")
+	}
+	stmt.Else.Accept(f)
+}
+
+func (f *formatter) VisitBlockStmt(stmt BlockStmt) {
+	if len(stmt.Statements) == 2 {
+		if loop, ok := stmt.Statements[1].(LoopStmt); ok && loop.Pos() == stmt.Pos() {
+			f.forLoop(stmt.Statements[0], loop)
+			return
+		}
+	}
+	f.block(stmt.Statements, stmt.End())
+}
+
+func (f *formatter) VisitLoopStmt(stmt LoopStmt) {
+	if stmt.OnLoop != nil || f.isFor(stmt.Pos()) {
+		f.forLoop(nil, stmt)
+		return
+	}
+	f.write("while (")
+	f.expr(stmt.Condition)
+	f.write(") ")
+	stmt.Body.Accept(f)
+}
+
+func (f *formatter) VisitBreakStmt(stmt BreakStmt) {
+	f.write("break;")
+}
+
+func (f *formatter) VisitContinueStmt(stmt ContinueStmt) {
+	f.write("continue;")
+}
+
+func (f *formatter) VisitFunctionStmt(stmt FunctionStmt) {
+	f.function("fun "+stmt.Name.Lexeme, stmt.Params, stmt.Body, stmt.End())
+}
+
+func (f *formatter) VisitReturnStmt(stmt ReturnStmt) {
+	if stmt.Result == nil {
+		f.write("return;")
+		return
+	}
+	f.write("return ")
+	f.expr(stmt.Result)
+	f.write(";")
+}
+
+func (f *formatter) VisitClassStmt(stmt ClassStmt) {
+	f.write("class ", stmt.Name.Lexeme)
+	if stmt.Superclass != nil {
+		f.write(" < ", stmt.Superclass.Name.Lexeme)
+	}
+	f.write(" ")
+	// Members are grouped by kind in the AST, and are written in source order.
+	type member struct {
+		stmt     Stmt
+		isStatic bool
+	}
+	var members []member
+	for _, method := range stmt.Methods {
+		members = append(members, member{method, false})
+	}
+	for _, v := range stmt.Vars {
+		members = append(members, member{v, false})
+	}
+	for _, method := range stmt.StaticMethods {
+		members = append(members, member{method, true})
+	}
+	for _, v := range stmt.StaticVars {
+		members = append(members, member{v, true})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].stmt.Pos().Offset < members[j].stmt.Pos().Offset
+	})
+	if len(members) == 0 && !f.hasCommentBefore(stmt.End().Offset) {
+		f.write("{}")
+		return
+	}
+	f.write("{")
+	f.newline()
+	f.indentation++
+	f.lastLine = 0
+	for _, m := range members {
+		f.line(m.stmt.Pos(), m.stmt.End(), func() {
+			if m.isStatic {
+				f.write("class ")
+			}
+			if method, ok := m.stmt.(FunctionStmt); ok {
+				f.function(method.Name.Lexeme, method.Params, method.Body, method.End())
+			} else {
+				m.stmt.Accept(f)
+			}
+		})
+	}
+	f.leadingComments(stmt.End().Offset)
+	f.indentation--
+	f.startLine()
+	f.write("}")
+}
+
+func (f *formatter) VisitImportStmt(stmt ImportStmt) {
+	f.write("import ", stmt.Path.Lexeme, " as ", stmt.Name.Lexeme, ";")
+}
+
+func (f *formatter) VisitThrowStmt(stmt ThrowStmt) {
+	f.write("throw ")
+	f.expr(stmt.Value)
+	f.write(";")
+}
+
+func (f *formatter) VisitTryStmt(stmt TryStmt) {
+	// Only the end of the whole statement is known, so comments between blocks are kept
+	// at the end of the preceding one.
+	catchStart, finallyStart := stmt.End(), stmt.End()
+	if len(stmt.Finally) > 0 {
+		catchStart, finallyStart = stmt.Finally[0].Pos(), stmt.Finally[0].Pos()
+	}
+	if len(stmt.Catch) > 0 {
+		catchStart = stmt.Catch[0].Pos()
+	}
+	f.write("try ")
+	f.block(stmt.Body, catchStart)
+	if stmt.CatchName != nil {
+		f.write(" catch (", stmt.CatchName.Lexeme, ") ")
+		f.block(stmt.Catch, finallyStart)
+	}
+	if stmt.Finally != nil {
+		f.write(" finally ")
+		f.block(stmt.Finally, stmt.End())
+	}
+}
@@ -0,0 +1,191 @@
+package lox_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		desc string
+		text string
+		want string
+	}{
+		{
+			"expressions",
+			"print -a+b*(c-1)  ;x.y=[1,2][0];m={\"k\":!true,1:nil};",
+			"print -a + b * (c - 1);\nx.y = [1, 2][0];\nm = {\"k\": !true, 1: nil};\n",
+		},
+		{
+			"blocks and functions",
+			"fun f(a,b){return a;}{}var g=fun(){ print 1; };fun h(){return;}",
+			"fun f(a, b) {\n    return a;\n}\n{}\nvar g = fun () {\n    print 1;\n};\nfun h() {\n    return;\n}\n",
+		},
+		{
+			"if and loops",
+			"if(a)print 1;else if(b){print 2;}else print 3;while(x)x=x-1;for(;;)break;for(i=0;i<3;)continue;",
+			`if (a) print 1;
+else if (b) {
+    print 2;
+} else print 3;
+while (x) x = x - 1;
+for (;;) break;
+for (i = 0; i < 3;) continue;
+`,
+		},
+		{
+			"classes",
+			"class A<B{class var n=0;init(x){this.x=x;}var y;class make(){return A(super.make());}}class C{}",
+			`class A < B {
+    class var n = 0;
+    init(x) {
+        this.x = x;
+    }
+    var y;
+    class make() {
+        return A(super.make());
+    }
+}
+class C {}
+`,
+		},
+		{
+			"try and import",
+			`import "a/b" as b;try{throw 1;}catch(e){}finally{print 2;}`,
+			"import \"a/b\" as b;\ntry {\n    throw 1;\n} catch (e) {} finally {\n    print 2;\n}\n",
+		},
+		{
+			"empty finally",
+			"try { print 1; } finally {}",
+			"try {\n    print 1;\n} finally {}\n",
+		},
+		{
+			"comments and blank lines",
+			`// header
+
+
+var a = 1; // one
+var bc = 2;      // two
+{ // open
+    print a;
+
+
+    // before
+    print bc; // trailing
+
+    // last
+}
+// footer
+`,
+			`// header
+
+var a = 1;  // one
+var bc = 2; // two
+{
+    // open
+    print a;
+
+    // before
+    print bc; // trailing
+
+    // last
+}
+// footer
+`,
+		},
+		{
+			"comments after one-line blocks",
+			"if (true) { print 1; } // why\nwhile (a) { a = nil; } // loop\ntry { f(); } finally { g(); } // cleanup\n",
+			`if (true) {
+    print 1;
+} // why
+while (a) {
+    a = nil;
+} // loop
+try {
+    f();
+} finally {
+    g();
+} // cleanup
+`,
+		},
+		{
+			"comments before else",
+			"if (a) {\n} // c1\n// c2\nelse {\n}\nif (b) print 1; // c3\n// c4\nelse print 2;",
+			"if (a) {} // c1\n// c2\nelse {}\nif (b) print 1; // c3\n// c4\nelse print 2;\n",
+		},
+		{
+			"comments within expressions",
+			"var f = fun () { // c1\n    return [1, // c2\n        2]; // c3\n};",
+			"var f = fun () {\n    // c1\n    return [1, 2]; // c3\n    // c2\n};\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			got, err := lox.Format(test.text)
+			if err != nil {
+				t.Fatalf("want nil, got err: %v", err)
+			}
+			if d := cmp.Diff(test.want, got); d != "" {
+				t.Errorf("(-want, +got)%s", d)
+			}
+		})
+	}
+}
+
+// TestFormatTestdata checks that formatting is idempotent and preserves the AST of all
+// valid test scripts.
+func TestFormatTestdata(t *testing.T) {
+	filenames, err := filepath.Glob("testdata/*/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := cmp.Options{
+		cmpopts.IgnoreTypes(lox.Span{}),
+		cmpopts.IgnoreFields(lox.Token{}, "Line"),
+	}
+	for _, filename := range filenames {
+		t.Run(filename, func(t *testing.T) {
+			bs, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := string(bs)
+			want, err := parse(text)
+			if err != nil {
+				t.Skipf("invalid script: %v", err)
+			}
+			formatted, err := lox.Format(text)
+			if err != nil {
+				t.Fatalf("want nil, got err: %v", err)
+			}
+			got, err := parse(formatted)
+			if err != nil {
+				t.Fatalf("formatted script is invalid: %v\n%s", err, formatted)
+			}
+			if d := cmp.Diff(want, got, opts); d != "" {
+				t.Errorf("formatted AST: (-want, +got)%s", d)
+			}
+			again, err := lox.Format(formatted)
+			if err != nil {
+				t.Fatalf("want nil, got err: %v", err)
+			}
+			if d := cmp.Diff(formatted, again); d != "" {
+				t.Errorf("formatting is not idempotent: (-first, +second)%s", d)
+			}
+		})
+	}
+}
+
+func parse(text string) ([]lox.Stmt, error) {
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	return lox.NewParser(tokens).Parse()
+}
@@ -0,0 +1,44 @@
+// Package loxtest has helpers for the tests that run the scripts in testdata, shared by the
+// interpreter and VM suites.
+package loxtest
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ExtractComment returns the contents of '// pattern:' comments in text, one per line.
+func ExtractComment(text, pattern string) string {
+	commentRE := regexp.MustCompile("(?im)// " + pattern + ":(.*)$")
+
+	var b strings.Builder
+	matches := commentRE.FindAllStringSubmatch(text, -1)
+	for _, match := range matches {
+		b.WriteString(strings.TrimPrefix(match[1], " "))
+		b.WriteRune('\n')
+	}
+	return b.String()
+}
+
+// ExtractExperiments returns the settings in '// experiments:' comments, a comma-separated
+// list of names that are enabled, or disabled if prefixed with '-'.
+func ExtractExperiments(text string) map[string]bool {
+	expStr := ExtractComment(text, "experiments")
+	exps := make(map[string]bool)
+	for _, exp := range strings.Split(expStr, ",") {
+		exp = strings.TrimSpace(exp)
+		if exp == "" {
+			continue
+		}
+		// Last setting wins.
+		switch exp[0] {
+		case '+':
+			exps[exp[1:]] = true
+		case '-':
+			exps[exp[1:]] = false
+		default:
+			exps[exp] = true
+		}
+	}
+	return exps
+}
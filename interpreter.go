@@ -1,10 +1,13 @@
 package lox
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"strings"
+	"time"
 )
 
 type Callable interface {
@@ -28,13 +31,14 @@ type function struct {
 
 func (f function) bind(obj object) function {
 	env := f.closure.Child(staticEnvironment)
+	env.names = thisNames
 	env.Define("this", obj)
 	return function{f.name, f.params, f.body, env, f.isInit}
 }
 
-func (f function) getThis() instance {
+func (f function) getThis() *instance {
 	// In a method, the only variable stored in the environment is 'this'.
-	return f.closure.GetStatic(0, 0).(instance)
+	return f.closure.GetStatic(0, 0).(*instance)
 }
 
 func (f function) Arity() int {
@@ -88,21 +92,93 @@ type localPosition struct {
 }
 
 type Interpreter struct {
+	// Values defined by the host program, visible to the script and all modules.
+	host    *Environment
 	globals *Environment
 	env     *Environment
 	value   any
 	stdout  io.Writer
+	rand    *rand.Rand
+	clock   func() float64
+	natives []*native
+	frames  []callFrame
 
 	locals map[Expr]localPosition
+	// Names declared in each scope, identified by its first statement, for the debugger.
+	scopeNames map[*Stmt][]string
+
+	searchPath    []string
+	disableImport bool
+	modules       map[string]*module
+	importing     []moduleImport
+
+	ctx    context.Context
+	limits Limits
+	usage  usage
+
+	debugger *Debugger
+}
+
+// Options configures a new Interpreter. The zero value is valid, and yields the defaults.
+type Options struct {
+	// Names of the builtins available to scripts, among BuiltinNames. If nil, all builtins
+	// are available. Unknown names are ignored.
+	Builtins []string
+	// Whether 'import' statements fail, instead of reading modules from the filesystem.
+	DisableImport bool
+	// Destination of all output from scripts. If nil, os.Stdout is used.
+	Stdout io.Writer
+	// Source of random numbers for the 'random' builtin. If nil, a source seeded with the
+	// current time is used.
+	RandSource rand.Source
+	// Time returned by the 'clock' builtin, in seconds. If nil, SystemClock is used.
+	Clock func() float64
+	// Limits enforced in all executions, as in SetLimits.
+	Limits Limits
+}
+
+// DeterministicOptions returns options for reproducible runs, where 'clock' returns the
+// time from a FakeClock and random numbers are generated from seed.
+func DeterministicOptions(seed int64) Options {
+	return Options{
+		RandSource: rand.NewSource(seed),
+		Clock:      FakeClock(),
+	}
 }
 
-func NewInterpreter() *Interpreter {
-	env := builtin.Child(dynamicEnvironment)
+// NewInterpreter returns an interpreter configured with the first of opts, if any.
+//
+// Each interpreter has its own builtins and random source, so that scripts running in
+// different interpreters don't affect each other.
+func NewInterpreter(opts ...Options) *Interpreter {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Stdout == nil {
+		o.Stdout = os.Stdout
+	}
+	if o.RandSource == nil {
+		o.RandSource = rand.NewSource(time.Now().UnixNano())
+	}
+	if o.Clock == nil {
+		o.Clock = SystemClock
+	}
+	host := newBuiltinEnvironment(o.Builtins).Child(dynamicEnvironment)
+	env := host.Child(dynamicEnvironment)
 	return &Interpreter{
-		globals: env,
-		env:     env,
-		stdout:  os.Stdout,
-		locals:  make(map[Expr]localPosition),
+		host:          host,
+		globals:       env,
+		env:           env,
+		stdout:        o.Stdout,
+		rand:          rand.New(o.RandSource),
+		clock:         o.Clock,
+		locals:        make(map[Expr]localPosition),
+		scopeNames:    make(map[*Stmt][]string),
+		modules:       make(map[string]*module),
+		ctx:           context.Background(),
+		limits:        o.Limits,
+		disableImport: o.DisableImport,
 	}
 }
 
@@ -110,12 +186,34 @@ func (i *Interpreter) SetStdout(w io.Writer) {
 	i.stdout = w
 }
 
-func (i *Interpreter) Interpret(stmts []Stmt) (err error) {
+// Globals returns the global variables of the main script, ordered by name.
+func (i *Interpreter) Globals() []Variable {
+	return sortedVariables(i.globals.dynamics)
+}
+
+func (i *Interpreter) Interpret(stmts []Stmt) error {
+	return i.InterpretContext(context.Background(), stmts)
+}
+
+// InterpretContext executes stmts until completion or until ctx is done, in which case it
+// returns an error wrapping ctx.Err(). Cancellation is checked at every loop iteration
+// and function call.
+func (i *Interpreter) InterpretContext(ctx context.Context, stmts []Stmt) (err error) {
+	i.ctx = ctx
+	i.usage = usage{}
 	defer func() {
+		i.ctx = context.Background()
 		if err_ := recover(); err_ != nil {
-			if runtimeErr, ok := err_.(runtimeError); ok {
-				err = runtimeErr
-			} else {
+			switch signal := err_.(type) {
+			case interruptError:
+				i.frames = i.frames[:0]
+				err = signal
+			case runtimeError:
+				err = tracedError{signal, i.stackTrace(signal.token)}
+			case throwSignal:
+				runtimeErr := uncaughtError(signal)
+				err = tracedError{runtimeErr, i.stackTrace(runtimeErr.token)}
+			default:
 				panic(err_)
 			}
 		}
@@ -144,21 +242,56 @@ func (i *Interpreter) resolve(expr Expr, depth int, index int) {
 	i.locals[expr] = localPosition{depth, index}
 }
 
+// LocalPosition returns the static position of the local variable referred by expr, as computed
+// by the Resolver: distance is the number of scopes between the expression and the variable
+// declaration, and index is the order in which the variable was declared within its scope.
+// Returns false if expr refers to a global variable.
+func (i *Interpreter) LocalPosition(expr Expr) (distance int, index int, ok bool) {
+	pos, ok := i.locals[expr]
+	return pos.distance, pos.index, ok
+}
+
+// recordScope records the names declared in the scope running stmts. Scopes without
+// statements are not recorded, since the execution never stops within them.
+func (i *Interpreter) recordScope(stmts []Stmt, names []string) {
+	if len(stmts) == 0 {
+		return
+	}
+	// Statements are identified by their address, that is shared by all copies of the AST.
+	i.scopeNames[&stmts[0]] = names
+}
+
+// localNames returns the names of the locals in a static environment.
+func (i *Interpreter) localNames(env *Environment) []string {
+	if len(env.stmts) == 0 {
+		return env.names
+	}
+	return i.scopeNames[&env.stmts[0]]
+}
+
 func (i *Interpreter) lookupVariable(name Token, expr Expr) any {
 	pos, ok := i.locals[expr]
 	if ok {
 		return i.env.GetStatic(pos.distance, pos.index)
 	}
-	return i.globals.Get(name)
+	// Globals are looked up from the current environment, to find the ones from the
+	// module where the running code was defined.
+	return i.env.Get(name)
 }
 
 func (i *Interpreter) execute(stmt Stmt) {
+	i.step(Span{stmt.Pos(), stmt.End()})
+	if i.debugger != nil {
+		i.debugger.beforeStmt(stmt)
+		defer i.debugger.afterStmt(stmt)
+	}
 	stmt.Accept(i)
 }
 
 func (i *Interpreter) executeBlock(stmts []Stmt, env *Environment) {
 	defer func(prev *Environment) { i.env = prev }(i.env)
 	i.env = env
+	env.stmts = stmts
 	for _, stmt := range stmts {
 		i.execute(stmt)
 	}
@@ -201,6 +334,7 @@ func (i *Interpreter) VisitBlockStmt(stmt BlockStmt) {
 
 func (i *Interpreter) VisitLoopStmt(stmt LoopStmt) {
 	for isTruthy(i.evaluate(stmt.Condition)) {
+		i.checkContext(stmt.Span)
 		state := i.runLoopBody(stmt.Body)
 		if state == breakLoop {
 			break
@@ -237,6 +371,7 @@ func (i *Interpreter) VisitFunctionStmt(stmt FunctionStmt) {
 	name := stmt.Name.Lexeme
 	isInit := false
 	f := function{name, stmt.Params, stmt.Body, i.env, isInit}
+	i.allocate(stmt.Span)
 	i.env.Define(name, f)
 }
 
@@ -249,12 +384,24 @@ func (i *Interpreter) VisitReturnStmt(stmt ReturnStmt) {
 }
 
 func (i *Interpreter) VisitClassStmt(stmt ClassStmt) {
+	i.allocate(stmt.Span)
 	className := stmt.Name.Lexeme
-	cl := newClass(newMetaClass(className))
+	var superclass *class
+	if stmt.Superclass != nil {
+		value := i.evaluate(stmt.Superclass)
+		sc, ok := value.(class)
+		if !ok {
+			panic(runtimeError{stmt.Superclass.Name, fmt.Sprintf("superclass must be a class, got %[1]T (%[1]v)", value)})
+		}
+		superclass = &sc
+	}
+	cl := newClass(newMetaClass(className), superclass)
+	// Environments must mirror the scopes created by the resolver.
+	staticEnv := i.superEnvironment(i.env, superclass)
 	for _, method := range stmt.StaticMethods {
 		methodName := method.Name.Lexeme
 		isInit := false
-		cl.meta.methods[methodName] = function{methodName, method.Params, method.Body, i.env, isInit}
+		cl.meta.methods[methodName] = function{methodName, method.Params, method.Body, staticEnv, isInit}
 	}
 	for _, decl := range stmt.StaticVars {
 		var value any = nil
@@ -270,7 +417,7 @@ func (i *Interpreter) VisitClassStmt(stmt ClassStmt) {
 		}
 		cl.fieldInits = append(cl.fieldInits, fieldInit)
 	}
-	classEnv := i.env.Child(staticEnvironment)
+	classEnv := i.superEnvironment(staticEnv.Child(staticEnvironment), superclass)
 	for _, method := range stmt.Methods {
 		methodName := method.Name.Lexeme
 		isInit := (methodName == "init")
@@ -279,9 +426,53 @@ func (i *Interpreter) VisitClassStmt(stmt ClassStmt) {
 	i.env.Define(className, cl)
 }
 
+func (i *Interpreter) VisitImportStmt(stmt ImportStmt) {
+	m := i.importModule(stmt.Path)
+	i.env.Define(stmt.Name.Lexeme, m)
+}
+
+func (i *Interpreter) VisitThrowStmt(stmt ThrowStmt) {
+	panic(throwSignal{stmt.Keyword, i.evaluate(stmt.Value)})
+}
+
+// VisitTryStmt runs the 'finally' block in a deferred call, so that it's executed also when
+// the statement is interrupted by a return, break, continue or an uncaught exception.
+func (i *Interpreter) VisitTryStmt(stmt TryStmt) {
+	if stmt.Finally != nil {
+		defer i.executeBlock(stmt.Finally, i.env.Child(staticEnvironment))
+	}
+	if stmt.CatchName == nil {
+		i.executeBlock(stmt.Body, i.env.Child(staticEnvironment))
+		return
+	}
+	value, caught := i.runTryBody(stmt)
+	if !caught {
+		return
+	}
+	env := i.env.Child(staticEnvironment)
+	env.Define(stmt.CatchName.Lexeme, value)
+	i.executeBlock(stmt.Catch, env)
+}
+
+var (
+	thisNames  = []string{"this"}
+	superNames = []string{"super"}
+)
+
+func (i *Interpreter) superEnvironment(env *Environment, superclass *class) *Environment {
+	if superclass == nil {
+		return env
+	}
+	env = env.Child(staticEnvironment)
+	env.names = superNames
+	env.Define("super", *superclass)
+	return env
+}
+
 // ----
 
 func (i *Interpreter) evaluate(expr Expr) any {
+	i.step(Span{expr.Pos(), expr.End()})
 	expr.Accept(i)
 	return i.value
 }
@@ -315,7 +506,7 @@ func (i *Interpreter) VisitAssignmentExpr(expr *AssignmentExpr) {
 	if ok {
 		i.env.SetStatic(pos.distance, pos.index, value)
 	} else {
-		i.globals.Set(expr.Name, value)
+		i.env.Set(expr.Name, value)
 	}
 }
 
@@ -336,6 +527,10 @@ func (i *Interpreter) VisitCallExpr(expr *CallExpr) {
 	for index, arg := range expr.Args {
 		args[index] = i.evaluate(arg)
 	}
+	if n, ok := callee.(*native); ok {
+		i.value = n.callAt(expr.Paren, args)
+		return
+	}
 	f, ok := callee.(Callable)
 	if !ok {
 		panic(runtimeError{expr.Paren, fmt.Sprintf("value %v (%T) is not callable", callee, callee)})
@@ -343,11 +538,22 @@ func (i *Interpreter) VisitCallExpr(expr *CallExpr) {
 	if f.Arity() != len(args) {
 		panic(runtimeError{expr.Paren, fmt.Sprintf("expecting %d arguments but got %d", f.Arity(), len(args))})
 	}
+	i.checkContext(expr.Paren.Span)
+	i.checkCallDepth(expr.Paren.Span)
+	if _, ok := f.(class); ok {
+		// Calling a class allocates an instance.
+		i.allocate(expr.Paren.Span)
+	}
+	// Frames are not popped when a runtime error unwinds the stack, so that it may be
+	// inspected when the error is returned.
+	i.pushFrame(f, expr.Paren)
 	i.value = f.Call(i, args)
+	i.popFrame()
 }
 
 func (i *Interpreter) VisitFunctionExpr(expr *FunctionExpr) {
 	isInit := false
+	i.allocate(expr.Span)
 	i.value = function{"anonymous", expr.Params, expr.Body, i.env, isInit}
 }
 
@@ -375,6 +581,65 @@ func (i *Interpreter) VisitThisExpr(expr *ThisExpr) {
 	i.value = i.lookupVariable(expr.Keyword, expr)
 }
 
+func (i *Interpreter) VisitListExpr(expr *ListExpr) {
+	i.allocate(expr.Span)
+	elems := make([]any, len(expr.Elements))
+	for index, elem := range expr.Elements {
+		elems[index] = i.evaluate(elem)
+	}
+	i.value = newList(elems)
+}
+
+func (i *Interpreter) VisitIndexExpr(expr *IndexExpr) {
+	obj := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	container, ok := obj.(indexable)
+	if !ok {
+		panic(runtimeError{expr.Bracket, fmt.Sprintf("want a list or map for index access, got %[1]T (%[1]v)", obj)})
+	}
+	i.value = container.getIndex(expr.Bracket, index)
+}
+
+func (i *Interpreter) VisitSetIndexExpr(expr *SetIndexExpr) {
+	obj := i.evaluate(expr.Object)
+	index := i.evaluate(expr.Index)
+	container, ok := obj.(indexable)
+	if !ok {
+		panic(runtimeError{expr.Bracket, fmt.Sprintf("want a list or map for index assignment, got %[1]T (%[1]v)", obj)})
+	}
+	value := i.evaluate(expr.Value)
+	container.setIndex(expr.Bracket, index, value)
+	i.value = value
+}
+
+func (i *Interpreter) VisitMapExpr(expr *MapExpr) {
+	i.allocate(expr.Span)
+	d := newDict()
+	for index, key := range expr.Keys {
+		k := i.evaluate(key)
+		v := i.evaluate(expr.Values[index])
+		d.setIndex(expr.Brace, k, v)
+	}
+	i.value = d
+}
+
+func (i *Interpreter) VisitSuperExpr(expr *SuperExpr) {
+	pos := i.locals[expr]
+	superclass := i.env.GetStatic(pos.distance, pos.index).(class)
+	// 'this' is always declared in the scope immediately enclosed by 'super'.
+	obj := i.env.GetStatic(pos.distance-1, 0).(object)
+	behavior := superclass.instanceBehavior
+	if _, ok := obj.(class); ok {
+		// Within a static method, 'super' refers to the superclass' static methods.
+		behavior = superclass.meta.objectBehavior
+	}
+	method, ok := behavior.findMethod(expr.Method.Lexeme)
+	if !ok {
+		panic(runtimeError{expr.Method, fmt.Sprintf("undefined method in superclass %s", superclass)})
+	}
+	i.value = method.bind(obj)
+}
+
 // ----
 
 func operate2(token Token, left, right any) any {
@@ -452,6 +717,14 @@ func (err runtimeError) Error() string {
 	return fmt.Sprintf("token '%s' in line %d: %s", err.token.Lexeme, err.token.Line, err.msg)
 }
 
+func (err runtimeError) Span() Span {
+	return err.token.Span
+}
+
+func (err runtimeError) Diagnostic() Diagnostic {
+	return Diagnostic{SeverityError, RuntimePhase, "runtime-error", err.msg, err.token.Span}
+}
+
 func checkNumberOperand(token Token, right any) float64 {
 	b, ok := right.(float64)
 	if ok {
@@ -7,6 +7,8 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+
+	"github.com/brunokim/kilox/internal/loxtest"
 )
 
 func TestInterpreter(t *testing.T) {
@@ -23,7 +25,7 @@ func TestInterpreter(t *testing.T) {
 			}
 			text := string(bs)
 			wantOutput, wantErr := extractExpected(text)
-			experiments := extractExperiments(text)
+			experiments := loxtest.ExtractExperiments(text)
 			if _, ok := experiments["typing"]; !ok {
 				experiments["typing"] = true // Enable typing, if not specified.
 			}
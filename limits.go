@@ -0,0 +1,105 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors reported when an execution limit is exceeded. They may be checked with errors.Is
+// on the error returned by Interpret.
+var (
+	ErrStepLimit       = errors.New("step limit exceeded")
+	ErrCallDepthLimit  = errors.New("call depth limit exceeded")
+	ErrAllocationLimit = errors.New("allocation limit exceeded")
+)
+
+// Default maximum call depth, that avoids overflowing the Go stack.
+const defaultMaxCallDepth = 1 << 14
+
+// Limits bounds the resources used by a single call to Interpret.
+type Limits struct {
+	// Maximum number of statements and expressions evaluated. Zero means unlimited.
+	MaxSteps int
+	// Maximum number of nested calls. Zero means a default depth of 16384.
+	MaxCallDepth int
+	// Maximum number of objects allocated, like lists, maps, instances and closures.
+	// Zero means unlimited.
+	MaxAllocations int
+}
+
+// SetLimits configures the limits enforced in subsequent executions.
+func (i *Interpreter) SetLimits(limits Limits) {
+	i.limits = limits
+}
+
+// ----
+
+// interruptError aborts the execution when it's cancelled or exceeds a limit. Unlike
+// runtimeError, it can't be caught by a 'try' statement.
+type interruptError struct {
+	span  Span
+	cause error
+}
+
+func (err interruptError) Error() string {
+	return fmt.Sprintf("line %d: %v", err.span.Start.Line, err.cause)
+}
+
+func (err interruptError) Unwrap() error {
+	return err.cause
+}
+
+func (err interruptError) Span() Span {
+	return err.span
+}
+
+func (err interruptError) Diagnostic() Diagnostic {
+	code := "interrupted"
+	switch err.cause {
+	case ErrStepLimit:
+		code = "step-limit"
+	case ErrCallDepthLimit:
+		code = "call-depth-limit"
+	case ErrAllocationLimit:
+		code = "allocation-limit"
+	}
+	return Diagnostic{SeverityError, RuntimePhase, code, err.cause.Error(), err.span}
+}
+
+// usage counts the resources used by the current execution.
+type usage struct {
+	steps       int
+	allocations int
+}
+
+func (i *Interpreter) checkContext(span Span) {
+	select {
+	case <-i.ctx.Done():
+		panic(interruptError{span, i.ctx.Err()})
+	default:
+	}
+}
+
+func (i *Interpreter) step(span Span) {
+	i.usage.steps++
+	if i.limits.MaxSteps > 0 && i.usage.steps > i.limits.MaxSteps {
+		panic(interruptError{span, ErrStepLimit})
+	}
+}
+
+func (i *Interpreter) checkCallDepth(span Span) {
+	maxDepth := i.limits.MaxCallDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxCallDepth
+	}
+	if len(i.frames) >= maxDepth {
+		panic(interruptError{span, ErrCallDepthLimit})
+	}
+}
+
+func (i *Interpreter) allocate(span Span) {
+	i.usage.allocations++
+	if i.limits.MaxAllocations > 0 && i.usage.allocations > i.limits.MaxAllocations {
+		panic(interruptError{span, ErrAllocationLimit})
+	}
+}
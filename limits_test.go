@@ -0,0 +1,100 @@
+package lox_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+)
+
+func resolveStmts(t *testing.T, i *lox.Interpreter, text string) []lox.Stmt {
+	stmts := parseStmts(t, text)
+	if err := lox.NewResolver(i).Resolve(stmts); err != nil {
+		t.Fatalf("resolve error: %v", err)
+	}
+	return stmts
+}
+
+func TestInterpretContext(t *testing.T) {
+	i := lox.NewInterpreter()
+	stmts := resolveStmts(t, i, "while (true) {}")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := i.InterpretContext(ctx, stmts)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want deadline exceeded, got %v", err)
+	}
+
+	// Cancellation is also checked at calls.
+	stmts = resolveStmts(t, i, "fun f() { return f(); } f();")
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = i.InterpretContext(ctx, stmts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want canceled, got %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		text    string
+		limits  lox.Limits
+		want    error
+		wantMsg string
+	}{
+		{
+			"var x = 0;\nwhile (true) { x = x + 1; }",
+			lox.Limits{MaxSteps: 100},
+			lox.ErrStepLimit,
+			"line 2: step limit exceeded",
+		},
+		{
+			"fun f(n) {\n  return f(n + 1);\n}\nf(0);",
+			lox.Limits{MaxCallDepth: 50},
+			lox.ErrCallDepthLimit,
+			"line 2: call depth limit exceeded",
+		},
+		{
+			// Default call depth, that avoids a Go stack overflow.
+			"fun f(n) {\n  return f(n + 1);\n}\nf(0);",
+			lox.Limits{},
+			lox.ErrCallDepthLimit,
+			"line 2: call depth limit exceeded",
+		},
+		{
+			"var xs = [];\nwhile (true) {\n  xs = [xs];\n}",
+			lox.Limits{MaxAllocations: 10},
+			lox.ErrAllocationLimit,
+			"line 3: allocation limit exceeded",
+		},
+		{
+			// Limits can't be caught.
+			"while (true) {\n  try { print nil + 1; } catch (e_) {}\n}",
+			lox.Limits{MaxSteps: 1000},
+			lox.ErrStepLimit,
+			"line 2: step limit exceeded",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			i := lox.NewInterpreter()
+			i.SetStdout(io.Discard)
+			i.SetLimits(test.limits)
+			err := i.Interpret(resolveStmts(t, i, test.text))
+			if !errors.Is(err, test.want) {
+				t.Fatalf("want %v, got %v", test.want, err)
+			}
+			if diff := cmp.Diff(test.wantMsg, err.Error()); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+			// Usage is reset for each execution.
+			if err := i.Interpret(resolveStmts(t, i, "print 1;")); err != nil {
+				t.Errorf("want nil, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package lint reports code that is valid, but likely a mistake, like unused variables and
+// unreachable statements.
+//
+// Each kind of problem is a rule, identified by a code, whose severity may be configured.
+// Problems reported as warnings don't prevent a script from running.
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/brunokim/kilox"
+)
+
+// Off is the severity of disabled rules.
+const Off lox.Severity = "off"
+
+// Rule is a kind of problem reported by the linter.
+type Rule struct {
+	Code        string
+	Description string
+	// Severity used if not configured otherwise.
+	Severity lox.Severity
+}
+
+// Rules lists all checks performed by the linter.
+var Rules = []Rule{
+	{"unused-variable", "local variable is never read", lox.SeverityWarning},
+	{"unused-function", "local function is never read or called", lox.SeverityWarning},
+	{"unused-param", "function param is never read", lox.SeverityWarning},
+	{"unused-catch-variable", "catch variable is never read", lox.SeverityWarning},
+	{"unused-import", "module imported in a local scope is never read", lox.SeverityWarning},
+	{"unused-field", "field assigned to 'this' is never read", lox.SeverityWarning},
+	{"unreachable-code", "statement after 'return', 'break', 'continue' or 'throw'", lox.SeverityWarning},
+	{"constant-condition", "'if' condition is a literal", lox.SeverityWarning},
+	{"empty-block", "block without statements", lox.SeverityWarning},
+	{"shadowed-variable", "local variable has the same name as one in an enclosing scope", Off},
+}
+
+// Config overrides the default severity of rules, by code.
+type Config map[string]lox.Severity
+
+// ParseConfig parses a comma-separated list of settings like "code=severity", where
+// severity is "error", "warning" or "off".
+func ParseConfig(s string) (Config, error) {
+	config := make(Config)
+	for _, setting := range strings.Split(s, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+		code, severity, ok := strings.Cut(setting, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid setting %q: want 'code=severity'", setting)
+		}
+		if _, ok := findRule(code); !ok {
+			return nil, fmt.Errorf("unknown rule %q", code)
+		}
+		switch sev := lox.Severity(severity); sev {
+		case lox.SeverityError, lox.SeverityWarning, Off:
+			config[code] = sev
+		default:
+			return nil, fmt.Errorf("invalid severity %q for rule %q: want 'error', 'warning' or 'off'", severity, code)
+		}
+	}
+	return config, nil
+}
+
+func findRule(code string) (Rule, bool) {
+	for _, rule := range Rules {
+		if rule.Code == code {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// severity returns the configured severity of a rule.
+func (c Config) severity(code string) lox.Severity {
+	if sev, ok := c[code]; ok {
+		return sev
+	}
+	rule, _ := findRule(code)
+	return rule.Severity
+}
+
+// ----
+
+type lintError struct {
+	severity lox.Severity
+	code     string
+	msg      string
+	span     lox.Span
+}
+
+func (err lintError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s [%s]", err.span.Start.Line, err.severity, err.msg, err.code)
+}
+
+func (err lintError) Span() lox.Span {
+	return err.span
+}
+
+func (err lintError) Diagnostic() lox.Diagnostic {
+	return lox.Diagnostic{
+		Severity: err.severity,
+		Phase:    lox.LintPhase,
+		Code:     err.code,
+		Message:  err.msg,
+		Span:     err.span,
+	}
+}
@@ -0,0 +1,128 @@
+package lint_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/lint"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// TestLint runs the linter on each file in testdata, and compares the problems with the
+// ones in '// lint:' comments. Rules are configured by a '// rules:' comment.
+func TestLint(t *testing.T) {
+	filenames, err := filepath.Glob("testdata/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		t.Run(filename, func(t *testing.T) {
+			bs, err := os.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := string(bs)
+			config, err := lint.ParseConfig(strings.Join(extractComment(text, "rules"), ","))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := runLint(text, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := cmp.Diff(extractComment(text, "lint"), got); d != "" {
+				t.Errorf("(-want, +got)%s", d)
+			}
+		})
+	}
+}
+
+func runLint(text string, config lint.Config) ([]string, error) {
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := lox.NewResolver(lox.NewInterpreter()).Resolve(stmts); err != nil {
+		return nil, err
+	}
+	err = lint.NewLinter(config).Lint(stmts)
+	if err == nil {
+		return nil, nil
+	}
+	return strings.Split(err.Error(), "\n"), nil
+}
+
+func extractComment(text, pattern string) []string {
+	commentRE := regexp.MustCompile("(?m)// " + pattern + ": (.*)$")
+	var lines []string
+	for _, match := range commentRE.FindAllStringSubmatch(text, -1) {
+		lines = append(lines, match[1])
+	}
+	return lines
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := lint.ParseConfig("unused-param=off, empty-block=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lint.Config{"unused-param": lint.Off, "empty-block": lox.SeverityError}
+	if d := cmp.Diff(want, config); d != "" {
+		t.Errorf("(-want, +got)%s", d)
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"unused-param", `invalid setting "unused-param": want 'code=severity'`},
+		{"unknown=off", `unknown rule "unknown"`},
+		{"empty-block=fatal", `invalid severity "fatal" for rule "empty-block": want 'error', 'warning' or 'off'`},
+	}
+	for _, test := range tests {
+		_, err := lint.ParseConfig(test.text)
+		if err == nil {
+			t.Errorf("ParseConfig(%q): want err, got nil", test.text)
+			continue
+		}
+		if d := cmp.Diff(test.want, err.Error()); d != "" {
+			t.Errorf("ParseConfig(%q): (-want, +got)%s", test.text, d)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	stmts := parse(t, "{\n    var x;\n}")
+	err := lint.NewLinter(lint.Config{}).Lint(stmts)
+	want := []lox.Diagnostic{{
+		Severity: lox.SeverityWarning,
+		Phase:    lox.LintPhase,
+		Code:     "unused-variable",
+		Message:  "local variable is never read",
+		Span:     lox.Span{Start: lox.Position{Offset: 10, Line: 2, Column: 9}, End: lox.Position{Offset: 11, Line: 2, Column: 10}},
+	}}
+	if d := cmp.Diff(want, lox.Diagnostics(err)); d != "" {
+		t.Errorf("(-want, +got)%s", d)
+	}
+}
+
+func parse(t *testing.T, text string) []lox.Stmt {
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stmts
+}
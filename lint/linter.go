@@ -0,0 +1,372 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/errlist"
+)
+
+type declType int
+
+const (
+	localDecl declType = iota
+	funcDecl
+	paramDecl
+	catchDecl
+	importDecl
+	classDecl
+)
+
+type variable struct {
+	name   lox.Token
+	decl   declType
+	isRead bool
+}
+
+type scope map[string]*variable
+
+// Linter checks a program for the rules in a Config. The program must have been resolved
+// without errors, since the linter doesn't check for them.
+type Linter struct {
+	config Config
+	errors []lintError
+	// Scopes of variables, where the first one has the globals.
+	scopes []scope
+	// First assignment to each field of 'this', and names of properties that are read.
+	fields         map[string]lox.Token
+	readProperties map[string]bool
+}
+
+func NewLinter(config Config) *Linter {
+	return &Linter{config: config}
+}
+
+// Lint returns the problems found in stmts, sorted by their location, or nil if there are
+// none. Use lox.Diagnostics to inspect their severities.
+func (l *Linter) Lint(stmts []lox.Stmt) error {
+	l.errors = nil
+	l.scopes = []scope{make(scope)}
+	l.fields = make(map[string]lox.Token)
+	l.readProperties = make(map[string]bool)
+	l.stmts(stmts)
+	for name, token := range l.fields {
+		if !l.readProperties[name] {
+			l.addError("unused-field", token.Span, "field is assigned but never read")
+		}
+	}
+	if len(l.errors) == 0 {
+		return nil
+	}
+	sort.SliceStable(l.errors, func(i, j int) bool {
+		return l.errors[i].span.Start.Offset < l.errors[j].span.Start.Offset
+	})
+	return errlist.Of[lintError](l.errors)
+}
+
+func (l *Linter) addError(code string, span lox.Span, msg string) {
+	severity := l.config.severity(code)
+	if severity == Off {
+		return
+	}
+	l.errors = append(l.errors, lintError{severity, code, msg, span})
+}
+
+// ----
+
+func (l *Linter) beginScope() {
+	l.scopes = append(l.scopes, make(scope))
+}
+
+// endScope reports the unused variables of the innermost scope. Variables with a '_' suffix
+// are expected to be unused.
+func (l *Linter) endScope() {
+	n := len(l.scopes)
+	for _, v := range l.scopes[n-1] {
+		if v.isRead || strings.HasSuffix(v.name.Lexeme, "_") {
+			continue
+		}
+		switch v.decl {
+		case localDecl:
+			l.addError("unused-variable", v.name.Span, "local variable is never read")
+		case funcDecl:
+			l.addError("unused-function", v.name.Span, "function is never read or called")
+		case paramDecl:
+			l.addError("unused-param", v.name.Span, "function param is never read")
+		case catchDecl:
+			l.addError("unused-catch-variable", v.name.Span, "catch variable is never read")
+		case importDecl:
+			l.addError("unused-import", v.name.Span, "imported module is never read")
+		}
+	}
+	l.scopes = l.scopes[:n-1]
+}
+
+func (l *Linter) declare(name lox.Token, decl declType) {
+	n := len(l.scopes)
+	if n > 1 {
+		for i := n - 2; i >= 0; i-- {
+			if outer, ok := l.scopes[i][name.Lexeme]; ok {
+				msg := fmt.Sprintf("'%s' shadows the declaration in line %d", name.Lexeme, outer.name.Line)
+				l.addError("shadowed-variable", name.Span, msg)
+				break
+			}
+		}
+	}
+	l.scopes[n-1][name.Lexeme] = &variable{name: name, decl: decl}
+}
+
+// read marks a variable as read. Assignments also count as reads.
+func (l *Linter) read(name lox.Token) {
+	for i := len(l.scopes) - 1; i >= 0; i-- {
+		if v, ok := l.scopes[i][name.Lexeme]; ok {
+			v.isRead = true
+			return
+		}
+	}
+}
+
+// stmts checks a list of statements, reporting the first one that follows a statement that
+// never completes normally.
+func (l *Linter) stmts(stmts []lox.Stmt) {
+	reported := false
+	for i, stmt := range stmts {
+		l.stmt(stmt)
+		if !reported && i+1 < len(stmts) && isTerminal(stmt) {
+			next := stmts[i+1]
+			l.addError("unreachable-code", lox.Span{Start: next.Pos(), End: next.End()}, "unreachable code")
+			reported = true
+		}
+	}
+}
+
+// isTerminal returns whether stmt always transfers control elsewhere.
+func isTerminal(stmt lox.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case lox.ReturnStmt, lox.BreakStmt, lox.ContinueStmt, lox.ThrowStmt:
+		return true
+	case lox.BlockStmt:
+		for _, s := range stmt.Statements {
+			if isTerminal(s) {
+				return true
+			}
+		}
+	case lox.IfStmt:
+		return stmt.Else != nil && isTerminal(stmt.Then) && isTerminal(stmt.Else)
+	}
+	return false
+}
+
+func (l *Linter) stmt(stmt lox.Stmt) {
+	stmt.Accept(l)
+}
+
+func (l *Linter) expr(expr lox.Expr) {
+	if expr != nil {
+		expr.Accept(l)
+	}
+}
+
+func (l *Linter) function(params []lox.Token, body []lox.Stmt) {
+	l.beginScope()
+	for _, param := range params {
+		l.declare(param, paramDecl)
+	}
+	l.stmts(body)
+	l.endScope()
+}
+
+// ---- Stmt
+
+func (l *Linter) VisitExpressionStmt(stmt lox.ExpressionStmt) {
+	l.expr(stmt.Expression)
+}
+
+func (l *Linter) VisitPrintStmt(stmt lox.PrintStmt) {
+	l.expr(stmt.Expression)
+}
+
+func (l *Linter) VisitVarStmt(stmt lox.VarStmt) {
+	l.expr(stmt.Init)
+	l.declare(stmt.Name, localDecl)
+}
+
+func (l *Linter) VisitIfStmt(stmt lox.IfStmt) {
+	cond := stmt.Condition
+	for {
+		group, ok := cond.(*lox.GroupingExpr)
+		if !ok {
+			break
+		}
+		cond = group.Expression
+	}
+	if lit, ok := cond.(*lox.LiteralExpr); ok {
+		isTruthy := lit.Value != nil && lit.Value != false
+		msg := fmt.Sprintf("condition is always %t", isTruthy)
+		l.addError("constant-condition", lox.Span{Start: stmt.Condition.Pos(), End: stmt.Condition.End()}, msg)
+	}
+	l.expr(stmt.Condition)
+	l.stmt(stmt.Then)
+	if stmt.Else != nil {
+		l.stmt(stmt.Else)
+	}
+}
+
+func (l *Linter) VisitBlockStmt(stmt lox.BlockStmt) {
+	if len(stmt.Statements) == 0 {
+		l.addError("empty-block", stmt.Span, "empty block")
+	}
+	l.beginScope()
+	l.stmts(stmt.Statements)
+	l.endScope()
+}
+
+func (l *Linter) VisitLoopStmt(stmt lox.LoopStmt) {
+	l.expr(stmt.Condition)
+	l.stmt(stmt.Body)
+	l.expr(stmt.OnLoop)
+}
+
+func (l *Linter) VisitBreakStmt(stmt lox.BreakStmt) {}
+
+func (l *Linter) VisitContinueStmt(stmt lox.ContinueStmt) {}
+
+func (l *Linter) VisitFunctionStmt(stmt lox.FunctionStmt) {
+	l.declare(stmt.Name, funcDecl)
+	l.function(stmt.Params, stmt.Body)
+}
+
+func (l *Linter) VisitReturnStmt(stmt lox.ReturnStmt) {
+	l.expr(stmt.Result)
+}
+
+func (l *Linter) VisitClassStmt(stmt lox.ClassStmt) {
+	l.declare(stmt.Name, classDecl)
+	if stmt.Superclass != nil {
+		l.expr(stmt.Superclass)
+	}
+	for _, decl := range stmt.StaticVars {
+		l.expr(decl.Init)
+	}
+	for _, decl := range stmt.Vars {
+		l.expr(decl.Init)
+	}
+	for _, method := range stmt.StaticMethods {
+		l.function(method.Params, method.Body)
+	}
+	for _, method := range stmt.Methods {
+		l.function(method.Params, method.Body)
+	}
+}
+
+func (l *Linter) VisitImportStmt(stmt lox.ImportStmt) {
+	l.declare(stmt.Name, importDecl)
+}
+
+func (l *Linter) VisitThrowStmt(stmt lox.ThrowStmt) {
+	l.expr(stmt.Value)
+}
+
+func (l *Linter) VisitTryStmt(stmt lox.TryStmt) {
+	l.beginScope()
+	l.stmts(stmt.Body)
+	l.endScope()
+	if stmt.CatchName != nil {
+		l.beginScope()
+		l.declare(*stmt.CatchName, catchDecl)
+		l.stmts(stmt.Catch)
+		l.endScope()
+	}
+	if stmt.Finally != nil {
+		l.beginScope()
+		l.stmts(stmt.Finally)
+		l.endScope()
+	}
+}
+
+// ---- Expr
+
+func (l *Linter) VisitBinaryExpr(expr *lox.BinaryExpr) {
+	l.expr(expr.Left)
+	l.expr(expr.Right)
+}
+
+func (l *Linter) VisitGroupingExpr(expr *lox.GroupingExpr) {
+	l.expr(expr.Expression)
+}
+
+func (l *Linter) VisitLiteralExpr(expr *lox.LiteralExpr) {}
+
+func (l *Linter) VisitUnaryExpr(expr *lox.UnaryExpr) {
+	l.expr(expr.Right)
+}
+
+func (l *Linter) VisitVariableExpr(expr *lox.VariableExpr) {
+	l.read(expr.Name)
+}
+
+func (l *Linter) VisitAssignmentExpr(expr *lox.AssignmentExpr) {
+	l.expr(expr.Value)
+	l.read(expr.Name)
+}
+
+func (l *Linter) VisitLogicExpr(expr *lox.LogicExpr) {
+	l.expr(expr.Left)
+	l.expr(expr.Right)
+}
+
+func (l *Linter) VisitCallExpr(expr *lox.CallExpr) {
+	l.expr(expr.Callee)
+	for _, arg := range expr.Args {
+		l.expr(arg)
+	}
+}
+
+func (l *Linter) VisitFunctionExpr(expr *lox.FunctionExpr) {
+	l.function(expr.Params, expr.Body)
+}
+
+func (l *Linter) VisitGetExpr(expr *lox.GetExpr) {
+	l.expr(expr.Object)
+	l.readProperties[expr.Name.Lexeme] = true
+}
+
+func (l *Linter) VisitSetExpr(expr *lox.SetExpr) {
+	l.expr(expr.Value)
+	l.expr(expr.Object)
+	if _, ok := expr.Object.(*lox.ThisExpr); ok {
+		if _, ok := l.fields[expr.Name.Lexeme]; !ok {
+			l.fields[expr.Name.Lexeme] = expr.Name
+		}
+	}
+}
+
+func (l *Linter) VisitThisExpr(expr *lox.ThisExpr) {}
+
+func (l *Linter) VisitSuperExpr(expr *lox.SuperExpr) {}
+
+func (l *Linter) VisitListExpr(expr *lox.ListExpr) {
+	for _, elem := range expr.Elements {
+		l.expr(elem)
+	}
+}
+
+func (l *Linter) VisitIndexExpr(expr *lox.IndexExpr) {
+	l.expr(expr.Object)
+	l.expr(expr.Index)
+}
+
+func (l *Linter) VisitSetIndexExpr(expr *lox.SetIndexExpr) {
+	l.expr(expr.Value)
+	l.expr(expr.Object)
+	l.expr(expr.Index)
+}
+
+func (l *Linter) VisitMapExpr(expr *lox.MapExpr) {
+	for i, key := range expr.Keys {
+		l.expr(key)
+		l.expr(expr.Values[i])
+	}
+}
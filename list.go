@@ -0,0 +1,73 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type indexable interface {
+	getIndex(bracket Token, index any) any
+	setIndex(bracket Token, index any, value any)
+}
+
+// ----
+
+type list struct {
+	elements []any
+}
+
+func newList(elements []any) *list {
+	return &list{elements: elements}
+}
+
+func (l *list) String() string {
+	var b strings.Builder
+	b.WriteRune('[')
+	for i, elem := range l.elements {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(repr(elem))
+	}
+	b.WriteRune(']')
+	return b.String()
+}
+
+func (l *list) getIndex(bracket Token, index any) any {
+	idx := l.checkIndex(bracket, index)
+	return l.elements[idx]
+}
+
+func (l *list) setIndex(bracket Token, index any, value any) {
+	idx := l.checkIndex(bracket, index)
+	l.elements[idx] = value
+}
+
+func (l *list) checkIndex(bracket Token, index any) int {
+	num, ok := index.(float64)
+	if !ok {
+		panic(runtimeError{bracket, fmt.Sprintf("list index must be a number, got %[1]T (%[1]v)", index)})
+	}
+	if num != math.Trunc(num) {
+		panic(runtimeError{bracket, fmt.Sprintf("list index must be an integer, got %v", num)})
+	}
+	if num < 0 || num >= float64(len(l.elements)) {
+		panic(runtimeError{bracket, fmt.Sprintf("index %v is out of range [0,%d)", num, len(l.elements))})
+	}
+	return int(num)
+}
+
+// ----
+
+// repr returns the representation of a value within a container.
+func repr(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
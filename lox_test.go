@@ -1,15 +1,31 @@
 package lox_test
 
 import (
-	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/internal/loxtest"
 	"github.com/brunokim/kilox/typing"
 )
 
+// Seed used by tests with the 'deterministic' experiment.
+const testSeed = 42
+
 func runLox(text string, experiments map[string]bool) (string, error) {
+	return runLoxWith(newInterpreter(experiments), text, experiments)
+}
+
+// newInterpreter returns an interpreter configured by experiments. With 'deterministic',
+// the interpreter uses a fake clock and a seeded random source.
+func newInterpreter(experiments map[string]bool) *lox.Interpreter {
+	if experiments["deterministic"] {
+		return lox.NewInterpreter(lox.DeterministicOptions(testSeed))
+	}
+	return lox.NewInterpreter()
+}
+
+func runLoxWith(i *lox.Interpreter, text string, experiments map[string]bool) (string, error) {
 	s := lox.NewScanner(text)
 	tokens, err := s.ScanTokens()
 	if err != nil {
@@ -20,7 +36,6 @@ func runLox(text string, experiments map[string]bool) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	i := lox.NewInterpreter()
 	r := lox.NewResolver(i)
 	err = r.Resolve(stmts)
 	if err != nil {
@@ -28,6 +43,7 @@ func runLox(text string, experiments map[string]bool) (string, error) {
 	}
 	if experiments["typing"] {
 		c := typing.NewChecker()
+		c.DefineNatives(i.NativeTypes())
 		_, err := c.Check(stmts)
 		if err != nil {
 			return "", err
@@ -104,40 +120,7 @@ func variableExpr(name string) *lox.VariableExpr {
 // ---- interpreter test
 
 func extractExpected(text string) (string, string) {
-	wantOutput := extractComment(text, "output")
-	wantError := extractComment(text, "error")
+	wantOutput := loxtest.ExtractComment(text, "output")
+	wantError := loxtest.ExtractComment(text, "error")
 	return wantOutput, wantError
 }
-
-func extractComment(text, pattern string) string {
-	commentRE := regexp.MustCompile("(?im)// " + pattern + ":(.*)$")
-
-	var b strings.Builder
-	matches := commentRE.FindAllStringSubmatch(text, -1)
-	for _, match := range matches {
-		b.WriteString(strings.TrimPrefix(match[1], " "))
-		b.WriteRune('\n')
-	}
-	return b.String()
-}
-
-func extractExperiments(text string) map[string]bool {
-	expStr := extractComment(text, "experiments")
-	exps := make(map[string]bool)
-	for _, exp := range strings.Split(expStr, ",") {
-		exp = strings.TrimSpace(exp)
-		if exp == "" {
-			continue
-		}
-		// Last setting wins.
-		switch exp[0] {
-		case '+':
-			exps[exp[1:]] = true
-		case '-':
-			exps[exp[1:]] = false
-		default:
-			exps[exp] = true
-		}
-	}
-	return exps
-}
@@ -0,0 +1,449 @@
+package lox
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// ToValue converts a Go value into a Lox value.
+//
+//   - numeric types are converted to float64, and strings, bools and nil are kept as-is;
+//   - slices and arrays are copied into lists, and maps into maps;
+//   - struct pointers are exposed as objects whose fields may be read and written;
+//   - struct values are copied and exposed as read-only objects;
+//   - functions are converted to natives, whose args and results are converted
+//     with FromValue and ToValue, respectively. If the last result is an error,
+//     it's reported as a runtime error.
+//
+// Lox values are returned unchanged.
+func ToValue(v any) (any, error) {
+	if isLoxValue(v) {
+		return v, nil
+	}
+	return toValue(reflect.ValueOf(v))
+}
+
+// FromValue converts a Lox value into a Go value, storing the result in the value
+// pointed to by target.
+//
+// Numbers may be stored in any numeric type, as long as they fit in it. Lists may be stored
+// in slices and arrays, maps in Go maps, and objects and instances in structs with matching
+// field names. If target points to an interface, values are stored with their natural
+// Go types: float64, string, bool, nil, []any and map[any]any.
+func FromValue(v any, target any) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return fromValue(v, ptr.Elem())
+}
+
+// Define converts a Go value with ToValue and binds it to a global name.
+func (i *Interpreter) Define(name string, value any) error {
+	v, err := ToValue(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if n, ok := v.(*native); ok {
+		n.name = name
+		i.defineNative(n)
+		return nil
+	}
+	i.host.Define(name, v)
+	return nil
+}
+
+// ----
+
+func isLoxValue(v any) bool {
+	switch v.(type) {
+	case *list, *dict, *instance, class, metaClass, metaType, function, *native, *goObject, *module:
+		return true
+	}
+	return false
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func toValue(rv reflect.Value) (any, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if rv.CanInterface() && isLoxValue(rv.Interface()) {
+		return rv.Interface(), nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return toValue(rv.Elem())
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Elem().Kind() == reflect.Struct {
+			return &goObject{ptr: rv}, nil
+		}
+		return toValue(rv.Elem())
+	case reflect.Struct:
+		// Copy struct, so that the original value is never modified.
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		return &goObject{ptr: ptr, readOnly: true}, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		elems := make([]any, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := toValue(rv.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elems[i] = elem
+		}
+		return newList(elems), nil
+	case reflect.Map:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		d := newDict()
+		iter := rv.MapRange()
+		for iter.Next() {
+			key, err := toValue(iter.Key())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+			value, err := toValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", iter.Key(), err)
+			}
+			if err := d.put(key, value); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	case reflect.Func:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return funcToNative(goFuncName(rv), rv)
+	}
+	return nil, fmt.Errorf("unsupported Go type %v", rv.Type())
+}
+
+func goFuncName(fn reflect.Value) string {
+	name := runtime.FuncForPC(fn.Pointer()).Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+func funcToNative(name string, fn reflect.Value) (*native, error) {
+	t := fn.Type()
+	numOut := t.NumOut()
+	hasErr := numOut > 0 && t.Out(numOut-1) == errorType
+	if numOut > 2 || (numOut == 2 && !hasErr) {
+		return nil, fmt.Errorf("unsupported function signature %v: want at most one result and an error", t)
+	}
+	arity := t.NumIn()
+	if t.IsVariadic() {
+		arity--
+	}
+	call := func(args []any) (any, error) {
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= arity {
+				paramType = t.In(arity).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+			in[i] = reflect.New(paramType).Elem()
+			if err := fromValue(arg, in[i]); err != nil {
+				return nil, fmt.Errorf("arg #%d: %w", i+1, err)
+			}
+		}
+		out := fn.Call(in)
+		if hasErr {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return nil, err
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return nil, nil
+		}
+		return toValue(out[0])
+	}
+	return &native{name: name, arity: arity, variadic: t.IsVariadic(), fn: call}, nil
+}
+
+// ----
+
+func fromValue(v any, target reflect.Value) error {
+	if obj, ok := v.(*goObject); ok {
+		if obj.ptr.Type().AssignableTo(target.Type()) {
+			target.Set(obj.ptr)
+			return nil
+		}
+		if obj.ptr.Elem().Type().AssignableTo(target.Type()) {
+			target.Set(obj.ptr.Elem())
+			return nil
+		}
+	}
+	if v == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.Interface:
+		natural, err := naturalValue(v)
+		if err != nil {
+			return err
+		}
+		rv := reflect.ValueOf(natural)
+		if !rv.Type().AssignableTo(target.Type()) {
+			return fmt.Errorf("can't assign %T to %v", natural, target.Type())
+		}
+		target.Set(rv)
+		return nil
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("want a bool, got %T (%v)", v, v)
+		}
+		target.SetBool(b)
+		return nil
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("want a string, got %T (%v)", v, v)
+		}
+		target.SetString(s)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		num, err := integer(v)
+		if err != nil {
+			return err
+		}
+		// Max values are not representable as float64, so compare with the next power of 2.
+		if num < math.MinInt64 || num >= 1<<63 || target.OverflowInt(int64(num)) {
+			return fmt.Errorf("%v overflows %v", num, target.Type())
+		}
+		target.SetInt(int64(num))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		num, err := integer(v)
+		if err != nil {
+			return err
+		}
+		if num < 0 || num >= 1<<64 || target.OverflowUint(uint64(num)) {
+			return fmt.Errorf("%v overflows %v", num, target.Type())
+		}
+		target.SetUint(uint64(num))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		num, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("want a number, got %T (%v)", v, v)
+		}
+		target.SetFloat(num)
+		return nil
+	case reflect.Slice:
+		l, ok := v.(*list)
+		if !ok {
+			return fmt.Errorf("want a list, got %T (%v)", v, v)
+		}
+		slice := reflect.MakeSlice(target.Type(), len(l.elements), len(l.elements))
+		for i, elem := range l.elements {
+			if err := fromValue(elem, slice.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		target.Set(slice)
+		return nil
+	case reflect.Array:
+		l, ok := v.(*list)
+		if !ok {
+			return fmt.Errorf("want a list, got %T (%v)", v, v)
+		}
+		if len(l.elements) != target.Len() {
+			return fmt.Errorf("want a list with %d elements, got %d", target.Len(), len(l.elements))
+		}
+		for i, elem := range l.elements {
+			if err := fromValue(elem, target.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		d, ok := v.(*dict)
+		if !ok {
+			return fmt.Errorf("want a map, got %T (%v)", v, v)
+		}
+		m := reflect.MakeMapWithSize(target.Type(), d.entries.Len())
+		for _, entry := range d.entries.Entries() {
+			key := reflect.New(target.Type().Key()).Elem()
+			if err := fromValue(entry.Key.value(), key); err != nil {
+				return fmt.Errorf("key %s: %w", repr(entry.Key.value()), err)
+			}
+			value := reflect.New(target.Type().Elem()).Elem()
+			if err := fromValue(entry.Value, value); err != nil {
+				return fmt.Errorf("key %s: %w", repr(entry.Key.value()), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		target.Set(m)
+		return nil
+	case reflect.Struct:
+		is, ok := v.(*instance)
+		if !ok {
+			return fmt.Errorf("want an instance, got %T (%v)", v, v)
+		}
+		for i := 0; i < target.NumField(); i++ {
+			name, ok := loxFieldName(target.Type().Field(i))
+			if !ok {
+				continue
+			}
+			value, ok := is.state.fields[name]
+			if !ok {
+				continue
+			}
+			if err := fromValue(value, target.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+		return nil
+	case reflect.Pointer:
+		ptr := reflect.New(target.Type().Elem())
+		if err := fromValue(v, ptr.Elem()); err != nil {
+			return err
+		}
+		target.Set(ptr)
+		return nil
+	}
+	return fmt.Errorf("can't convert %T (%v) to %v", v, v, target.Type())
+}
+
+func integer(v any) (float64, error) {
+	num, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("want a number, got %T (%v)", v, v)
+	}
+	if num != math.Trunc(num) {
+		return 0, fmt.Errorf("want an integer, got %v", num)
+	}
+	return num, nil
+}
+
+// naturalValue converts a Lox value into its most natural Go representation.
+func naturalValue(v any) (any, error) {
+	switch v := v.(type) {
+	case *list:
+		elems := make([]any, len(v.elements))
+		for i, elem := range v.elements {
+			natural, err := naturalValue(elem)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elems[i] = natural
+		}
+		return elems, nil
+	case *dict:
+		m := make(map[any]any)
+		for _, entry := range v.entries.Entries() {
+			natural, err := naturalValue(entry.Value)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", repr(entry.Key.value()), err)
+			}
+			m[entry.Key.value()] = natural
+		}
+		return m, nil
+	case *goObject:
+		return v.ptr.Interface(), nil
+	default:
+		return v, nil
+	}
+}
+
+// ----
+
+// goObject exposes a Go struct as a Lox object.
+type goObject struct {
+	ptr      reflect.Value
+	readOnly bool
+}
+
+// loxFieldName returns the name of a struct field within Lox, which may be overridden
+// with the `lox:"name"` tag. Returns false if the field is not accessible.
+func loxFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := field.Tag.Get("lox")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return field.Name, true
+}
+
+func (o *goObject) field(name string) (reflect.Value, bool) {
+	elem := o.ptr.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		if fieldName, ok := loxFieldName(elem.Type().Field(i)); ok && fieldName == name {
+			return elem.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func (o *goObject) String() string {
+	return fmt.Sprintf("<go %v>", o.ptr.Elem().Type())
+}
+
+func (o *goObject) get(name Token) any {
+	if field, ok := o.field(name.Lexeme); ok {
+		value, err := toValue(field)
+		if err != nil {
+			panic(runtimeError{name, err.Error()})
+		}
+		return value
+	}
+	if method := o.ptr.MethodByName(name.Lexeme); method.IsValid() {
+		n, err := funcToNative(name.Lexeme, method)
+		if err != nil {
+			panic(runtimeError{name, err.Error()})
+		}
+		return n
+	}
+	panic(runtimeError{name, fmt.Sprintf("undefined property in %s", o)})
+}
+
+func (o *goObject) set(name Token, value any) {
+	if o.readOnly {
+		panic(runtimeError{name, fmt.Sprintf("can't set property in read-only %s", o)})
+	}
+	field, ok := o.field(name.Lexeme)
+	if !ok {
+		panic(runtimeError{name, fmt.Sprintf("undefined property in %s", o)})
+	}
+	if err := fromValue(value, field); err != nil {
+		panic(runtimeError{name, err.Error()})
+	}
+}
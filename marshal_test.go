@@ -0,0 +1,201 @@
+package lox_test
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+	"github.com/lithammer/dedent"
+)
+
+type point struct {
+	X, Y  int
+	Label string `lox:"label"`
+	notes string
+}
+
+func (p *point) Norm1() int {
+	return abs(p.X) + abs(p.Y)
+}
+
+func (p *point) Move(dx, dy int) {
+	p.X += dx
+	p.Y += dy
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	tests := []struct {
+		value  any
+		target func() any
+	}{
+		{nil, func() any { return new(any) }},
+		{true, func() any { return new(bool) }},
+		{"abc", func() any { return new(string) }},
+		{42, func() any { return new(int) }},
+		{uint8(255), func() any { return new(uint8) }},
+		{-1.5, func() any { return new(float64) }},
+		{float32(0.25), func() any { return new(float32) }},
+		{[]int{1, 2, 3}, func() any { return new([]int) }},
+		{[2]string{"a", "b"}, func() any { return new([2]string) }},
+		{map[string]int{"a": 1, "b": 2}, func() any { return new(map[string]int) }},
+		{[]any{1.0, "a", []any{true}}, func() any { return new(any) }},
+		{point{X: 1, Y: 2, Label: "p"}, func() any { return new(point) }},
+		{&point{X: 1, Y: 2}, func() any { return new(*point) }},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%T(%v)", test.value, test.value), func(t *testing.T) {
+			v, err := lox.ToValue(test.value)
+			if err != nil {
+				t.Fatalf("ToValue: %v", err)
+			}
+			target := test.target()
+			if err := lox.FromValue(v, target); err != nil {
+				t.Fatalf("FromValue: %v", err)
+			}
+			got := reflectElem(target)
+			if diff := cmp.Diff(test.value, got, cmp.AllowUnexported(point{})); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
+
+func reflectElem(ptr any) any {
+	switch p := ptr.(type) {
+	case *any:
+		return *p
+	case *bool:
+		return *p
+	case *string:
+		return *p
+	case *int:
+		return *p
+	case *uint8:
+		return *p
+	case *float64:
+		return *p
+	case *float32:
+		return *p
+	case *[]int:
+		return *p
+	case *[2]string:
+		return *p
+	case *map[string]int:
+		return *p
+	case *point:
+		return *p
+	case **point:
+		return *p
+	}
+	panic(fmt.Sprintf("unhandled type %T", ptr))
+}
+
+func TestFromValueErrors(t *testing.T) {
+	tests := []struct {
+		value  any
+		target any
+		want   string
+	}{
+		{"abc", new(int), "want a number, got string (abc)"},
+		{1.5, new(int), "want an integer, got 1.5"},
+		{300.0, new(uint8), "300 overflows uint8"},
+		{-1.0, new(uint), "-1 overflows uint"},
+		{math.Inf(1), new(int64), "+Inf overflows int64"},
+		{float64(1 << 63), new(int64), "9.223372036854776e+18 overflows int64"},
+		{float64(1 << 64), new(uint64), "1.8446744073709552e+19 overflows uint64"},
+		{1.0, new([]int), "want a list, got float64 (1)"},
+		{1.0, 2, "target must be a non-nil pointer, got int"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v->%T", test.value, test.target), func(t *testing.T) {
+			err := lox.FromValue(test.value, test.target)
+			if err == nil {
+				t.Fatalf("want err, got nil")
+			}
+			if diff := cmp.Diff(test.want, err.Error()); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
+
+func TestDefineGoValues(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    string
+		wantErr string
+	}{
+		{
+			dedent.Dedent(`
+                print p;
+                print p.X + p.Y;
+                print p.label;
+                p.X = 10;
+                print p.Norm1();
+                p.Move(1, 1);
+                print p.X;`),
+			"<go lox_test.point>\n3\norigin\n12\n11\n",
+			"",
+		},
+		{"print add(1, 2);", "3\n", ""},
+		{"print sum();", "0\n", ""},
+		{"print sum(1, 2, 3);", "6\n", ""},
+		{"print split(\"a,b\")[1];", "b\n", ""},
+		{"print fixed.X;", "1\n", ""},
+		{"print nums;", "[1, 2, 3]\n", ""},
+		{"print ages;", `{"alice": 30}` + "\n", ""},
+		{"fixed.X = 3;", "", "token 'X' in line 1: can't set property in read-only <go lox_test.point>"},
+		{"p.notes;", "", "token 'notes' in line 1: undefined property in <go lox_test.point>"},
+		{"p.X = 1.5;", "", "token 'X' in line 1: want an integer, got 1.5"},
+		{"add(1, \"a\");", "", "token ')' in line 1: add: arg #2: want a number, got string (a)"},
+		{"fail();", "", "token ')' in line 1: fail: oops"},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			p := &point{X: 1, Y: 2, Label: "origin"}
+			i := lox.NewInterpreter()
+			define := map[string]any{
+				"p":     p,
+				"fixed": point{X: 1},
+				"nums":  []int{1, 2, 3},
+				"ages":  map[string]int{"alice": 30},
+				"add":   func(a, b int) int { return a + b },
+				"split": func(s string) []string { return []string{s[:1], s[2:]} },
+				"fail":  func() error { return errors.New("oops") },
+				"sum": func(xs ...float64) float64 {
+					var total float64
+					for _, x := range xs {
+						total += x
+					}
+					return total
+				},
+			}
+			for name, value := range define {
+				if err := i.Define(name, value); err != nil {
+					t.Fatalf("Define(%q): %v", name, err)
+				}
+			}
+			output, err := runLoxWith(i, test.text, nil)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if diff := cmp.Diff(test.wantErr, errMsg); diff != "" {
+				t.Errorf("errors: (-want, +got)%s", diff)
+			}
+			if diff := cmp.Diff(test.want, output); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
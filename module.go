@@ -0,0 +1,161 @@
+package lox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// module is the value bound by an import statement, exposing the top-level bindings of
+// the imported file as its members.
+type module struct {
+	name string
+	path string
+	env  *Environment
+}
+
+func (m *module) String() string {
+	return fmt.Sprintf("<module %s>", m.name)
+}
+
+func (m *module) get(name Token) any {
+	if v, ok := m.env.dynamics[name.Lexeme]; ok {
+		return v
+	}
+	panic(runtimeError{name, fmt.Sprintf("undefined member %q in %v", name.Lexeme, m)})
+}
+
+func (m *module) set(name Token, value any) {
+	panic(runtimeError{name, fmt.Sprintf("can't assign to member %q of %v", name.Lexeme, m)})
+}
+
+// moduleImport is a module being loaded.
+type moduleImport struct {
+	name string
+	path string
+}
+
+// ----
+
+// SetSearchPath sets the directories where imported modules are looked up.
+//
+// A relative import path is first looked up in the directory of the importing module, or
+// in the working directory for the main script, and then in each of dirs, in order.
+func (i *Interpreter) SetSearchPath(dirs ...string) {
+	i.searchPath = dirs
+}
+
+// importModule returns the module at the path given by token, loading it if it wasn't
+// imported before.
+func (i *Interpreter) importModule(token Token) *module {
+	if i.disableImport {
+		panic(runtimeError{token, "import is disabled"})
+	}
+	name := token.Literal.(string)
+	path, err := i.findModule(name)
+	if err != nil {
+		panic(runtimeError{token, err.Error()})
+	}
+	if m, ok := i.modules[path]; ok {
+		return m
+	}
+	for k, imp := range i.importing {
+		if imp.path != path {
+			continue
+		}
+		var names []string
+		for _, imp := range i.importing[k:] {
+			names = append(names, fmt.Sprintf("%q", imp.name))
+		}
+		names = append(names, fmt.Sprintf("%q", name))
+		panic(runtimeError{token, fmt.Sprintf("import cycle: %s", strings.Join(names, " -> "))})
+	}
+	m, err := i.loadModule(name, path)
+	if err != nil {
+		panic(runtimeError{token, fmt.Sprintf("in module %q: %v", name, err)})
+	}
+	i.modules[path] = m
+	return m
+}
+
+func (i *Interpreter) findModule(name string) (string, error) {
+	var importer string
+	if n := len(i.importing); n > 0 {
+		importer = i.importing[n-1].path
+	}
+	return FindModule(name, importer, i.searchPath)
+}
+
+// FindModule returns the canonical path of the module imported as name by the module at
+// importer, or by the main script if importer is empty.
+//
+// A relative name is looked up in the directory of the importer, or in the working
+// directory, and then in each of searchPath, in order.
+func FindModule(name, importer string, searchPath []string) (string, error) {
+	var dirs []string
+	if filepath.IsAbs(name) {
+		dirs = []string{""}
+	} else {
+		if importer != "" {
+			dirs = append(dirs, filepath.Dir(importer))
+		} else {
+			dirs = append(dirs, ".")
+		}
+		dirs = append(dirs, searchPath...)
+	}
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		// Canonicalize path, so that the same file is loaded only once.
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		return filepath.EvalSymlinks(path)
+	}
+	return "", fmt.Errorf("module %q not found", name)
+}
+
+// loadModule scans, parses, resolves and executes the file at path, within its own
+// global environment.
+func (i *Interpreter) loadModule(name, path string) (m *module, err error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := NewScanner(string(bs)).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := NewParser(tokens).Parse()
+	if err != nil {
+		return nil, err
+	}
+	if err := NewResolver(i).Resolve(stmts); err != nil {
+		return nil, err
+	}
+	m = &module{name, path, i.host.Child(dynamicEnvironment)}
+
+	i.importing = append(i.importing, moduleImport{name, path})
+	defer func(globals, env *Environment, depth int) {
+		i.importing = i.importing[:len(i.importing)-1]
+		i.globals, i.env = globals, env
+		if r := recover(); r != nil {
+			runtimeErr, ok := r.(runtimeError)
+			if !ok {
+				panic(r)
+			}
+			// Discard frames from calls within the module.
+			i.frames = i.frames[:depth]
+			err = runtimeErr
+		}
+	}(i.globals, i.env, len(i.frames))
+	i.globals, i.env = m.env, m.env
+	for _, stmt := range stmts {
+		i.execute(stmt)
+	}
+	return m, nil
+}
@@ -0,0 +1,58 @@
+package lox_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSearchPath(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeFiles(t, dir1, map[string]string{
+		"greet.lox": `
+            import "util/names.lox" as names;
+            fun hello(name) { return "hello, " + names.title(name); }`,
+		"util/names.lox": `fun title(name) { return "Dr. " + name; }`,
+	})
+	writeFiles(t, dir2, map[string]string{
+		"greet.lox":      `fun hello(name_) { return "shadowed"; }`,
+		"util/names.lox": `fun title(name_) { return "shadowed"; }`,
+	})
+
+	i := lox.NewInterpreter()
+	i.SetSearchPath(dir1, dir2)
+	got, err := runLoxWith(i, `import "greet.lox" as greet; print greet.hello("Who");`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("hello, Dr. Who\n", got); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+
+	// The same file is returned when imported through different paths.
+	got, err = runLoxWith(i, `
+        import "greet.lox" as g1;
+        import "util/../greet.lox" as g2;
+        print g1 == g2;`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("true\n", got); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}
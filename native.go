@@ -0,0 +1,92 @@
+package lox
+
+import (
+	"fmt"
+)
+
+// NativeFunc is a Go function callable from Lox.
+//
+// Arguments are Lox values, and the result must also be a Lox value. A non-nil error
+// is reported as a runtime error at the call site.
+type NativeFunc func(args []any) (any, error)
+
+type native struct {
+	name     string
+	arity    int
+	variadic bool
+	fn       NativeFunc
+}
+
+func (n *native) Arity() int {
+	return n.arity
+}
+
+func (n *native) Call(i *Interpreter, args []any) any {
+	return n.callAt(Token{}, args)
+}
+
+func (n *native) callAt(paren Token, args []any) any {
+	if n.variadic && len(args) < n.arity {
+		panic(runtimeError{paren, fmt.Sprintf("expecting at least %d arguments but got %d", n.arity, len(args))})
+	}
+	if !n.variadic && len(args) != n.arity {
+		panic(runtimeError{paren, fmt.Sprintf("expecting %d arguments but got %d", n.arity, len(args))})
+	}
+	result, err := n.fn(args)
+	if err != nil {
+		panic(runtimeError{paren, fmt.Sprintf("%s: %v", n.name, err)})
+	}
+	return result
+}
+
+func (n *native) String() string {
+	return fmt.Sprintf("<native fn %s>", n.name)
+}
+
+// ----
+
+// DefineNative binds a Go function with a fixed number of params to a global name.
+func (i *Interpreter) DefineNative(name string, arity int, fn NativeFunc) {
+	i.defineNative(&native{name: name, arity: arity, fn: fn})
+}
+
+// DefineVariadicNative binds a Go function accepting at least minArity arguments to a global name.
+func (i *Interpreter) DefineVariadicNative(name string, minArity int, fn NativeFunc) {
+	i.defineNative(&native{name: name, arity: minArity, variadic: true, fn: fn})
+}
+
+func (i *Interpreter) defineNative(n *native) {
+	i.host.Define(n.name, n)
+	i.natives = append(i.natives, n)
+}
+
+// NativeTypes returns the type signatures of all natives defined in this interpreter,
+// to be declared in a type checker.
+//
+// Natives are typed as functions taking and returning any type. Variadic natives may have
+// any type, since function types have a fixed number of params, so their arguments are only
+// checked when called.
+func (i *Interpreter) NativeTypes() map[string]Type {
+	types := make(map[string]Type)
+	for _, n := range i.natives {
+		if n.variadic {
+			types[n.name] = &RefType{ID: 1}
+		} else {
+			types[n.name] = genericFunctionType(n.arity)
+		}
+	}
+	return types
+}
+
+// genericFunctionType returns the type of a function with arity params, whose
+// params and result may have any type.
+func genericFunctionType(arity int) FunctionType {
+	params := make([]Type, arity)
+	for i := 0; i < arity; i++ {
+		params[i] = &RefType{ID: i + 1}
+	}
+	return FunctionType{
+		Params: params,
+		Return: &RefType{ID: arity + 1},
+	}
+}
@@ -0,0 +1,80 @@
+package lox_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+	"github.com/lithammer/dedent"
+)
+
+func newNativeInterpreter() *lox.Interpreter {
+	i := lox.NewInterpreter()
+	i.DefineNative("double", 1, func(args []any) (any, error) {
+		x, ok := args[0].(float64)
+		if !ok {
+			return nil, fmt.Errorf("want a number, got %v", args[0])
+		}
+		return 2 * x, nil
+	})
+	i.DefineNative("fail", 0, func(args []any) (any, error) {
+		return nil, errors.New("something went wrong")
+	})
+	i.DefineVariadicNative("join", 1, func(args []any) (any, error) {
+		sep := args[0].(string)
+		parts := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			parts[i] = fmt.Sprint(arg)
+		}
+		return strings.Join(parts, sep), nil
+	})
+	return i
+}
+
+func TestDefineNative(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"print double(21);", "42\n", ""},
+		{"print double;", "<native fn double>\n", ""},
+		{"print double(double(1)) + 1;", "5\n", ""},
+		{`print join(", ");`, "\n", ""},
+		{`print join(", ", "a", "b", "c");`, "a, b, c\n", ""},
+		{
+			dedent.Dedent(`
+                var x = 1;
+                print double("x");`),
+			"",
+			`token ')' in line 3: double: want a number, got x`,
+		},
+		{
+			dedent.Dedent(`
+                print double(1);
+                fail();`),
+			"2\n",
+			`token ')' in line 3: fail: something went wrong`,
+		},
+		{"double(1, 2);", "", "line 1 at '1': (Fun (_1) _2) != (Fun (Number Number) _3)"},
+		{"join();", "", "token ')' in line 1: expecting at least 1 arguments but got 0"},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			output, err := runLoxWith(newNativeInterpreter(), test.text, map[string]bool{"typing": true})
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error()
+			}
+			if diff := cmp.Diff(test.wantErr, errMsg); diff != "" {
+				t.Errorf("errors: (-want, +got)%s", diff)
+			}
+			if diff := cmp.Diff(test.want, output); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package lox_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOptionsBuiltins(t *testing.T) {
+	i := lox.NewInterpreter(lox.Options{Builtins: []string{"len"}})
+	_, err := runLoxWith(i, "print len([1, 2]);", nil)
+	if err != nil {
+		t.Fatalf("want nil, got err: %v", err)
+	}
+	_, err = runLoxWith(i, "print clock();", nil)
+	if diff := cmp.Diff(`token 'clock' in line 1: undefined variable "clock"`, errString(err)); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}
+
+func TestOptionsUnknownBuiltins(t *testing.T) {
+	i := lox.NewInterpreter(lox.Options{Builtins: []string{"len", "system"}})
+	_, err := runLoxWith(i, "print len([1, 2]);", nil)
+	if err != nil {
+		t.Fatalf("want nil, got err: %v", err)
+	}
+	_, err = runLoxWith(i, "print system;", nil)
+	if diff := cmp.Diff(`token 'system' in line 1: undefined variable "system"`, errString(err)); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}
+
+func TestOptionsDisableImport(t *testing.T) {
+	i := lox.NewInterpreter(lox.Options{DisableImport: true})
+	_, err := runLoxWith(i, `import "testdata/import/lib/math.lox" as math;`, nil)
+	if diff := cmp.Diff(`token '"testdata/import/lib/math.lox"' in line 1: import is disabled`, errString(err)); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}
+
+func TestOptionsStdout(t *testing.T) {
+	var b strings.Builder
+	i := lox.NewInterpreter(lox.Options{Stdout: &b})
+	stmts := resolveStmts(t, i, `print "hello";`)
+	if err := i.Interpret(stmts); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("hello\n", b.String()); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}
+
+func TestInterpreterIsolation(t *testing.T) {
+	newInterpreter := func() *lox.Interpreter {
+		return lox.NewInterpreter(lox.Options{RandSource: rand.NewSource(42)})
+	}
+	i1, i2 := newInterpreter(), newInterpreter()
+	want, err := runLoxWith(i1, "print random();", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Seeding and redefining builtins in one interpreter doesn't affect the other.
+	if _, err := runLoxWith(i1, "randomSeed(1); len = nil;", nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := runLoxWith(i2, "print random(); print len([1]);", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want+"1\n", got); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -50,6 +50,10 @@ func (omap *Map[K, V]) Put(key K, value V) {
 	omap.m[key] = value
 }
 
+func (omap *Map[K, V]) Len() int {
+	return len(omap.order)
+}
+
 func (omap *Map[K, V]) Keys() []K {
 	keys := make([]K, len(omap.order))
 	copy(keys, omap.order)
@@ -60,43 +60,64 @@ func (p *Parser) declaration() Stmt {
 		}
 	}()
 	if p.match(Var) {
-		return p.varDeclaration()
+		return p.varDeclaration(p.previous())
 	}
 	if p.match(Class) {
 		return p.classDeclaration()
 	}
+	if p.match(Import) {
+		return p.importDeclaration()
+	}
 	if p.check(Fun) && !p.checkNext(LeftParen) {
 		p.match(Fun)
-		return p.function("function")
+		return p.function("function", p.previous())
 	}
 	return p.statement()
 }
 
-func (p *Parser) varDeclaration() VarStmt {
+// varDeclaration parses a variable declaration, starting at the given token.
+func (p *Parser) varDeclaration(start Token) VarStmt {
 	name := p.consume(Identifier, "expecting variable name")
 	var init Expr
 	if p.match(Equal) {
 		init = p.expression()
 	}
 	p.consume(Semicolon, "expecting ';' after variable declaration")
-	return VarStmt{name, init}
+	return VarStmt{name, init, p.spanFrom(start)}
+}
+
+func (p *Parser) importDeclaration() ImportStmt {
+	keyword := p.previous()
+	path := p.consume(String, "expecting module path after 'import'")
+	p.consume(As, "expecting 'as' after module path")
+	name := p.consume(Identifier, "expecting module name after 'as'")
+	p.consume(Semicolon, "expecting ';' after import declaration")
+	return ImportStmt{keyword, path, name, p.spanFrom(keyword)}
 }
 
 func (p *Parser) classDeclaration() Stmt {
+	start := p.previous()
 	name := p.consume(Identifier, "expecting class name")
+	var superclass *VariableExpr
+	if p.match(Less) {
+		token := p.consume(Identifier, "expecting superclass name")
+		superclass = &VariableExpr{token, token.Span}
+	}
 	p.consume(LeftBrace, "expecting '{' before class body")
-	stmt := ClassStmt{Name: name}
+	stmt := ClassStmt{Name: name, Superclass: superclass}
 	for !p.isAtEnd() && !p.check(RightBrace) {
+		attrStart := p.peek()
 		isStatic := p.match(Class)
-		p.attribute(&stmt, isStatic)
+		p.attribute(&stmt, isStatic, attrStart)
 	}
 	p.consume(RightBrace, "expecting '}' after class body")
+	stmt.Span = p.spanFrom(start)
 	return stmt
 }
 
-func (p *Parser) attribute(stmt *ClassStmt, isStatic bool) {
+func (p *Parser) attribute(stmt *ClassStmt, isStatic bool, start Token) {
 	if p.match(Var) {
-		decl := p.varDeclaration()
+		decl := p.varDeclaration(start)
 		if isStatic {
 			stmt.StaticVars = append(stmt.StaticVars, decl)
 		} else {
@@ -104,7 +125,7 @@ func (p *Parser) attribute(stmt *ClassStmt, isStatic bool) {
 		}
 		return
 	}
-	decl := p.function("method")
+	decl := p.function("method", start)
 	if isStatic {
 		stmt.StaticMethods = append(stmt.StaticMethods, decl)
 	} else {
@@ -112,11 +133,12 @@ func (p *Parser) attribute(stmt *ClassStmt, isStatic bool) {
 	}
 }
 
-func (p *Parser) function(kind string) FunctionStmt {
+// function parses a function or method declaration, starting at the given token.
+func (p *Parser) function(kind string, start Token) FunctionStmt {
 	name := p.consume(Identifier, fmt.Sprintf("expecting %s name", kind))
 	params := p.functionParams(kind)
 	body := p.functionBody(kind)
-	return FunctionStmt{name, params, body}
+	return FunctionStmt{name, params, body, p.spanFrom(start)}
 }
 
 func (p *Parser) functionParams(kind string) []Token {
@@ -126,7 +148,7 @@ func (p *Parser) functionParams(kind string) []Token {
 		params = append(params, p.consume(Identifier, "expecting parameter name"))
 		for p.match(Comma) {
 			if len(params) == maxCallArgs {
-				p.addError(parseError{p.peek(), fmt.Sprintf("can't have more than %d parameters", maxCallArgs)})
+				p.addError(parseError{p.peek(), "too-many-params", fmt.Sprintf("can't have more than %d parameters", maxCallArgs)})
 			}
 			params = append(params, p.consume(Identifier, "expecting parameter name"))
 		}
@@ -141,14 +163,17 @@ func (p *Parser) functionBody(kind string) []Stmt {
 }
 
 func (p *Parser) statement() Stmt {
+	start := p.peek()
 	if p.match(Print) {
 		return p.printStatement()
 	}
 	if p.match(If) {
 		return p.ifStatement()
 	}
-	if p.match(LeftBrace) {
-		return BlockStmt{p.block()}
+	if p.check(LeftBrace) && !p.startsMapLiteral() {
+		p.advance()
+		stmts := p.block()
+		return BlockStmt{stmts, p.spanFrom(start)}
 	}
 	if p.match(While) {
 		return p.whileStatement()
@@ -165,25 +190,33 @@ func (p *Parser) statement() Stmt {
 	if p.match(Return) {
 		return p.returnStatement()
 	}
+	if p.match(Throw) {
+		return p.throwStatement()
+	}
+	if p.match(Try) {
+		return p.tryStatement()
+	}
 	return p.expressionStatement()
 }
 
 func (p *Parser) printStatement() PrintStmt {
+	start := p.previous()
 	expr := p.expression()
 	p.consume(Semicolon, "expecting ';' after expression")
-	return PrintStmt{expr}
+	return PrintStmt{expr, p.spanFrom(start)}
 }
 
 func (p *Parser) ifStatement() IfStmt {
+	start := p.previous()
 	p.consume(LeftParen, "expecting '(' after 'if'")
 	cond := p.expression()
 	p.consume(RightParen, "expecting ')' after condition")
 	thenStmt := p.statement()
 	if p.match(Else) {
 		elseStmt := p.statement()
-		return IfStmt{Condition: cond, Then: thenStmt, Else: elseStmt}
+		return IfStmt{Condition: cond, Then: thenStmt, Else: elseStmt, Span: p.spanFrom(start)}
 	}
-	return IfStmt{Condition: cond, Then: thenStmt}
+	return IfStmt{Condition: cond, Then: thenStmt, Span: p.spanFrom(start)}
 }
 
 func (p *Parser) block() []Stmt {
@@ -196,21 +229,23 @@ func (p *Parser) block() []Stmt {
 }
 
 func (p *Parser) whileStatement() LoopStmt {
+	start := p.previous()
 	p.consume(LeftParen, "expecting '(' after 'while'")
 	cond := p.expression()
 	p.consume(RightParen, "expecting ')' after condition")
 	stmt := p.statement()
-	return LoopStmt{Condition: cond, Body: stmt}
+	return LoopStmt{Condition: cond, Body: stmt, Span: p.spanFrom(start)}
 }
 
 func (p *Parser) forStatement() Stmt {
+	start := p.previous()
 	p.consume(LeftParen, "expecting '(' after 'for'")
 	// Initializer
 	var init Stmt
 	if p.match(Semicolon) {
 		init = nil
 	} else if p.match(Var) {
-		init = p.varDeclaration()
+		init = p.varDeclaration(p.previous())
 	} else {
 		init = p.expressionStatement()
 	}
@@ -224,7 +259,7 @@ func (p *Parser) forStatement() Stmt {
 		// so we use the preceding semicolon.
 		// It shouldn't matter much because the token is used only to report type error messages, and I
 		// don't expect typing issues with a truthy/falsey condition.
-		cond = &LiteralExpr{p.previous(), true}
+		cond = &LiteralExpr{p.previous(), true, p.previous().Span}
 	}
 	p.consume(Semicolon, "Expect ';' after loop condition")
 	// Increment
@@ -239,9 +274,10 @@ func (p *Parser) forStatement() Stmt {
 		Condition: cond,
 		Body:      p.statement(),
 		OnLoop:    inc,
+		Span:      p.spanFrom(start),
 	}
 	if init != nil {
-		body = BlockStmt{[]Stmt{init, body}}
+		body = BlockStmt{[]Stmt{init, body}, p.spanFrom(start)}
 	}
 	return body
 }
@@ -249,29 +285,65 @@ func (p *Parser) forStatement() Stmt {
 func (p *Parser) breakStatement() BreakStmt {
 	token := p.previous()
 	p.consume(Semicolon, "expecting ';' after 'break'")
-	return BreakStmt{Keyword: token}
+	return BreakStmt{Keyword: token, Span: p.spanFrom(token)}
 }
 
 func (p *Parser) continueStatement() Stmt {
 	token := p.previous()
 	p.consume(Semicolon, "expecting ';' after 'continue'")
-	return ContinueStmt{Keyword: token}
+	return ContinueStmt{Keyword: token, Span: p.spanFrom(token)}
 }
 
 func (p *Parser) returnStatement() ReturnStmt {
 	token := p.previous()
 	if p.match(Semicolon) {
-		return ReturnStmt{Keyword: token}
+		return ReturnStmt{Keyword: token, Span: p.spanFrom(token)}
 	}
 	expr := p.expression()
 	p.consume(Semicolon, "expecting ';' after return expression")
-	return ReturnStmt{Keyword: token, Result: expr}
+	return ReturnStmt{Keyword: token, Result: expr, Span: p.spanFrom(token)}
+}
+
+func (p *Parser) throwStatement() ThrowStmt {
+	token := p.previous()
+	expr := p.expression()
+	p.consume(Semicolon, "expecting ';' after thrown expression")
+	return ThrowStmt{Keyword: token, Value: expr, Span: p.spanFrom(token)}
+}
+
+func (p *Parser) tryStatement() TryStmt {
+	stmt := TryStmt{Keyword: p.previous()}
+	p.consume(LeftBrace, "expecting '{' after 'try'")
+	stmt.Body = p.block()
+	if p.match(Catch) {
+		p.consume(LeftParen, "expecting '(' after 'catch'")
+		name := p.consume(Identifier, "expecting catch variable name")
+		stmt.CatchName = &name
+		p.consume(RightParen, "expecting ')' after catch variable")
+		p.consume(LeftBrace, "expecting '{' before catch body")
+		stmt.Catch = p.block()
+	}
+	hasFinally := p.match(Finally)
+	if hasFinally {
+		p.consume(LeftBrace, "expecting '{' after 'finally'")
+		stmt.Finally = p.block()
+		if stmt.Finally == nil {
+			// An empty block is distinguished from a missing one.
+			stmt.Finally = []Stmt{}
+		}
+	}
+	if stmt.CatchName == nil && !hasFinally {
+		panic(parseError{p.peek(), "unexpected-token", "expecting 'catch' or 'finally' after try block"})
+	}
+	stmt.Span = p.spanFrom(stmt.Keyword)
+	return stmt
 }
 
 func (p *Parser) expressionStatement() ExpressionStmt {
+	start := p.peek()
 	expr := p.expression()
 	p.consume(Semicolon, "expecting ';' after expression")
-	return ExpressionStmt{expr}
+	return ExpressionStmt{expr, p.spanFrom(start)}
 }
 
 // ----
@@ -289,15 +361,19 @@ func (p *Parser) assignment() Expr {
 	switch e := expr.(type) {
 	case *VariableExpr:
 		value := p.assignment()
-		return &AssignmentExpr{e.Name, value}
+		return &AssignmentExpr{e.Name, value, Span{e.Pos(), value.End()}}
 	case *GetExpr:
 		value := p.assignment()
-		return &SetExpr{e.Object, e.Name, value}
+		return &SetExpr{e.Object, e.Name, value, Span{e.Pos(), value.End()}}
+	case *IndexExpr:
+		value := p.assignment()
+		return &SetIndexExpr{e.Object, e.Bracket, e.Index, value, Span{e.Pos(), value.End()}}
 	default:
-		msg := fmt.Sprintf("invalid target for assignment: want variable or get expression, got %s expression", expr.TypeName())
-		p.addError(parseError{equals, msg})
+		msg := fmt.Sprintf("invalid target for assignment: want variable, get or index expression, got %s expression", expr.TypeName())
+		p.addError(parseError{equals, "invalid-assignment", msg})
 		p.assignment() // Keep consuming tokens after '=', but discard them.
-		return nil
+		// Return the target in place of the assignment, so that enclosing expressions are valid.
+		return expr
 	}
 }
 
@@ -306,7 +382,7 @@ func (p *Parser) or() Expr {
 	for p.match(Or) {
 		operator := p.previous()
 		right := p.and()
-		expr = &LogicExpr{expr, operator, right}
+		expr = &LogicExpr{expr, operator, right, Span{expr.Pos(), right.End()}}
 	}
 	return expr
 }
@@ -316,7 +392,7 @@ func (p *Parser) and() Expr {
 	for p.match(And) {
 		operator := p.previous()
 		right := p.equality()
-		expr = &LogicExpr{expr, operator, right}
+		expr = &LogicExpr{expr, operator, right, Span{expr.Pos(), right.End()}}
 	}
 	return expr
 }
@@ -326,7 +402,7 @@ func (p *Parser) equality() Expr {
 	for p.match(BangEqual, EqualEqual) {
 		operator := p.previous()
 		right := p.comparison()
-		expr = &BinaryExpr{expr, operator, right}
+		expr = &BinaryExpr{expr, operator, right, Span{expr.Pos(), right.End()}}
 	}
 	return expr
 }
@@ -336,7 +412,7 @@ func (p *Parser) comparison() Expr {
 	for p.match(Greater, GreaterEqual, Less, LessEqual) {
 		operator := p.previous()
 		right := p.term()
-		expr = &BinaryExpr{expr, operator, right}
+		expr = &BinaryExpr{expr, operator, right, Span{expr.Pos(), right.End()}}
 	}
 	return expr
 }
@@ -346,7 +422,7 @@ func (p *Parser) term() Expr {
 	for p.match(Minus, Plus) {
 		operator := p.previous()
 		right := p.factor()
-		expr = &BinaryExpr{expr, operator, right}
+		expr = &BinaryExpr{expr, operator, right, Span{expr.Pos(), right.End()}}
 	}
 	return expr
 }
@@ -356,7 +432,7 @@ func (p *Parser) factor() Expr {
 	for p.match(Slash, Star) {
 		operator := p.previous()
 		right := p.unary()
-		expr = &BinaryExpr{expr, operator, right}
+		expr = &BinaryExpr{expr, operator, right, Span{expr.Pos(), right.End()}}
 	}
 	return expr
 }
@@ -365,7 +441,7 @@ func (p *Parser) unary() Expr {
 	if p.match(Bang, Minus) {
 		operator := p.previous()
 		right := p.unary()
-		return &UnaryExpr{operator, right}
+		return &UnaryExpr{operator, right, Span{operator.Span.Start, right.End()}}
 	}
 	return p.call()
 }
@@ -377,7 +453,11 @@ func (p *Parser) call() Expr {
 			expr = p.finishCall(expr)
 		} else if p.match(Dot) {
 			name := p.consume(Identifier, "expecting property name after '.'")
-			expr = &GetExpr{expr, name}
+			expr = &GetExpr{expr, name, Span{expr.Pos(), name.Span.End}}
+		} else if p.match(LeftBracket) {
+			index := p.expression()
+			bracket := p.consume(RightBracket, "expecting ']' after index")
+			expr = &IndexExpr{expr, bracket, index, Span{expr.Pos(), bracket.Span.End}}
 		} else {
 			break
 		}
@@ -391,43 +471,105 @@ func (p *Parser) finishCall(callee Expr) Expr {
 		args = append(args, p.expression())
 		for p.match(Comma) {
 			if len(args) == maxCallArgs {
-				p.addError(parseError{p.peek(), fmt.Sprintf("can't have more than %d arguments", maxCallArgs)})
+				p.addError(parseError{p.peek(), "too-many-args", fmt.Sprintf("can't have more than %d arguments", maxCallArgs)})
 			}
 			args = append(args, p.expression())
 		}
 	}
 	paren := p.consume(RightParen, "expecting ')' after arguments")
-	return &CallExpr{callee, paren, args}
+	return &CallExpr{callee, paren, args, Span{callee.Pos(), paren.Span.End}}
 }
 
 func (p *Parser) primary() Expr {
+	start := p.peek()
 	if p.match(False) {
-		return &LiteralExpr{p.previous(), false}
+		return &LiteralExpr{start, false, start.Span}
 	}
 	if p.match(True) {
-		return &LiteralExpr{p.previous(), true}
+		return &LiteralExpr{start, true, start.Span}
 	}
 	if p.match(Nil) {
-		return &LiteralExpr{p.previous(), nil}
+		return &LiteralExpr{start, nil, start.Span}
 	}
 	if p.match(Number, String) {
-		return &LiteralExpr{p.previous(), p.previous().Literal}
+		return &LiteralExpr{start, start.Literal, start.Span}
 	}
 	if p.match(Identifier) {
-		return &VariableExpr{p.previous()}
+		return &VariableExpr{start, start.Span}
 	}
 	if p.match(LeftParen) {
 		expr := p.expression()
 		p.consume(RightParen, "expecting ')' after expression")
-		return &GroupingExpr{expr}
+		return &GroupingExpr{expr, p.spanFrom(start)}
+	}
+	if p.match(LeftBracket) {
+		return p.list()
+	}
+	if p.match(LeftBrace) {
+		return p.mapLiteral()
 	}
 	if p.match(Fun) {
 		return p.anonymousFunction()
 	}
 	if p.match(This) {
-		return &ThisExpr{p.previous()}
+		return &ThisExpr{start, start.Span}
 	}
-	panic(parseError{p.peek(), "expecting expression"})
+	if p.match(Super) {
+		p.consume(Dot, "expecting '.' after 'super'")
+		method := p.consume(Identifier, "expecting superclass method name")
+		return &SuperExpr{start, method, p.spanFrom(start)}
+	}
+	panic(parseError{p.peek(), "expecting-expression", "expecting expression"})
+}
+
+func (p *Parser) list() *ListExpr {
+	bracket := p.previous()
+	var elems []Expr
+	if !p.check(RightBracket) {
+		elems = append(elems, p.expression())
+		for p.match(Comma) {
+			elems = append(elems, p.expression())
+		}
+	}
+	p.consume(RightBracket, "expecting ']' after list elements")
+	return &ListExpr{bracket, elems, p.spanFrom(bracket)}
+}
+
+func (p *Parser) mapLiteral() *MapExpr {
+	brace := p.previous()
+	var keys, values []Expr
+	if !p.check(RightBrace) {
+		for {
+			keys = append(keys, p.expression())
+			p.consume(Colon, "expecting ':' after map key")
+			values = append(values, p.expression())
+			if !p.match(Comma) {
+				break
+			}
+		}
+	}
+	p.consume(RightBrace, "expecting '}' after map entries")
+	return &MapExpr{brace, keys, values, p.spanFrom(brace)}
+}
+
+// startsMapLiteral reports whether the '{' at the current position opens a map literal
+// instead of a block. This is the case if it's followed by an expression and a ':'.
+// An empty '{}' at the start of a statement is always considered a block.
+func (p *Parser) startsMapLiteral() (isMap bool) {
+	sub := &Parser{tokens: p.tokens, current: p.current + 1}
+	if sub.check(RightBrace) {
+		return false
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			if _, ok := err.(parseError); !ok {
+				panic(err) // Rethrow
+			}
+			isMap = false
+		}
+	}()
+	sub.expression()
+	return len(sub.errors) == 0 && sub.check(Colon)
 }
 
 func (p *Parser) anonymousFunction() *FunctionExpr {
@@ -440,6 +582,7 @@ func (p *Parser) anonymousFunction() *FunctionExpr {
 		Keyword: keyword,
 		Params:  params,
 		Body:    body,
+		Span:    p.spanFrom(keyword),
 	}
 }
 
@@ -447,6 +590,7 @@ func (p *Parser) anonymousFunction() *FunctionExpr {
 
 type parseError struct {
 	token Token
+	code  string
 	msg   string
 }
 
@@ -457,15 +601,28 @@ func (err parseError) Error() string {
 	return fmt.Sprintf("line %d at '%s': %s", err.token.Line, err.token.Lexeme, err.msg)
 }
 
+func (err parseError) Span() Span {
+	return err.token.Span
+}
+
+func (err parseError) Diagnostic() Diagnostic {
+	return Diagnostic{SeverityError, ParsePhase, err.code, err.msg, err.token.Span}
+}
+
 func (p *Parser) addError(err parseError) {
 	p.errors = append(p.errors, err)
 }
 
+// spanFrom returns the span from the start token up to the last consumed token.
+func (p *Parser) spanFrom(start Token) Span {
+	return Span{start.Span.Start, p.previous().Span.End}
+}
+
 func (p *Parser) consume(t TokenType, msg string) Token {
 	if p.check(t) {
 		return p.advance()
 	}
-	panic(parseError{p.peek(), msg})
+	panic(parseError{p.peek(), "unexpected-token", msg})
 }
 
 func (p *Parser) synchronize() {
@@ -475,7 +632,7 @@ func (p *Parser) synchronize() {
 			return
 		}
 		switch p.peek().TokenType {
-		case Class, For, Fun, If, Print, Return, Var, While:
+		case Class, For, Fun, If, Import, Print, Return, Throw, Try, Var, While:
 			return
 		}
 		p.advance()
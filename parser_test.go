@@ -1,6 +1,7 @@
 package lox_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/brunokim/kilox"
@@ -18,7 +19,8 @@ func TestParserExpression(t *testing.T) {
 		{"false", boolean(false)},
 		{"true", boolean(true)},
 		{"nil", literal(lox.Nil, nil)},
-		{"this", &lox.ThisExpr{token(lox.This, "this")}},
+		{"this", &lox.ThisExpr{Keyword: token(lox.This, "this")}},
+		{"super.foo", &lox.SuperExpr{Keyword: token(lox.Super, "super"), Method: token(lox.Identifier, "foo")}},
 		{`"abc def"`, literal(lox.String, "abc def")},
 		{"x", variableExpr("x")},
 		{"-1", &lox.UnaryExpr{Operator: token(lox.Minus, "-"), Right: number(1)}},
@@ -151,6 +153,35 @@ func TestParserExpression(t *testing.T) {
 			},
 			Name: token(lox.Identifier, "qux"),
 		}},
+		{"[]", &lox.ListExpr{Bracket: token(lox.LeftBracket, "[")}},
+		{"[1, x]", &lox.ListExpr{
+			Bracket:  token(lox.LeftBracket, "["),
+			Elements: []lox.Expr{number(1), variableExpr("x")},
+		}},
+		{"xs[0][i]", &lox.IndexExpr{
+			Object: &lox.IndexExpr{
+				Object:  variableExpr("xs"),
+				Bracket: token(lox.RightBracket, "]"),
+				Index:   number(0),
+			},
+			Bracket: token(lox.RightBracket, "]"),
+			Index:   variableExpr("i"),
+		}},
+		{"f()[0] = 1", &lox.SetIndexExpr{
+			Object: &lox.CallExpr{
+				Callee: variableExpr("f"),
+				Paren:  token(lox.RightParen, ")"),
+			},
+			Bracket: token(lox.RightBracket, "]"),
+			Index:   number(0),
+			Value:   number(1),
+		}},
+		{"{}", &lox.MapExpr{Brace: token(lox.LeftBrace, "{")}},
+		{`{"a": 1, x: y}`, &lox.MapExpr{
+			Brace:  token(lox.LeftBrace, "{"),
+			Keys:   []lox.Expr{literal(lox.String, "a"), variableExpr("x")},
+			Values: []lox.Expr{number(1), variableExpr("y")},
+		}},
 		{"foo(a).bar = 10", &lox.SetExpr{
 			Object: &lox.CallExpr{
 				Callee: variableExpr("foo"),
@@ -167,6 +198,7 @@ func TestParserExpression(t *testing.T) {
 			got := parseExpr(t, test.text)
 			opts := cmp.Options{
 				cmpopts.IgnoreFields(lox.Token{}, "Line"),
+				cmpopts.IgnoreTypes(lox.Span{}),
 				cmpopts.IgnoreFields(lox.LiteralExpr{}, "Token.Lexeme"),
 			}
 			if diff := cmp.Diff(test.want, got, opts); diff != "" {
@@ -181,14 +213,14 @@ func TestParserStatements(t *testing.T) {
 		text string
 		want []lox.Stmt
 	}{
-		{"a+2;", []lox.Stmt{lox.ExpressionStmt{&lox.BinaryExpr{
+		{"a+2;", []lox.Stmt{lox.ExpressionStmt{Expression: &lox.BinaryExpr{
 			Left:     variableExpr("a"),
 			Operator: token(lox.Plus, "+"),
 			Right:    number(2),
 		}}}},
 		{"print a; print b;", []lox.Stmt{
-			lox.PrintStmt{variableExpr("a")},
-			lox.PrintStmt{variableExpr("b")},
+			lox.PrintStmt{Expression: variableExpr("a")},
+			lox.PrintStmt{Expression: variableExpr("b")},
 		}},
 		{"var a; var b = false;", []lox.Stmt{
 			lox.VarStmt{Name: token(lox.Identifier, "a")},
@@ -197,7 +229,7 @@ func TestParserStatements(t *testing.T) {
 		{"if (a) b = 10;", []lox.Stmt{
 			lox.IfStmt{
 				Condition: variableExpr("a"),
-				Then: lox.ExpressionStmt{&lox.AssignmentExpr{
+				Then: lox.ExpressionStmt{Expression: &lox.AssignmentExpr{
 					Name:  token(lox.Identifier, "b"),
 					Value: number(10),
 				}},
@@ -206,35 +238,45 @@ func TestParserStatements(t *testing.T) {
 		{"if (a) b = 10; else a = 5;", []lox.Stmt{
 			lox.IfStmt{
 				Condition: variableExpr("a"),
-				Then: lox.ExpressionStmt{&lox.AssignmentExpr{
+				Then: lox.ExpressionStmt{Expression: &lox.AssignmentExpr{
 					Name:  token(lox.Identifier, "b"),
 					Value: number(10),
 				}},
-				Else: lox.ExpressionStmt{&lox.AssignmentExpr{
+				Else: lox.ExpressionStmt{Expression: &lox.AssignmentExpr{
 					Name:  token(lox.Identifier, "a"),
 					Value: number(5),
 				}},
 			},
 		}},
 		{"1; {2; {3; 4; {}} 5;} {6;}", []lox.Stmt{
-			lox.ExpressionStmt{number(1)},
-			lox.BlockStmt{[]lox.Stmt{
-				lox.ExpressionStmt{number(2)},
-				lox.BlockStmt{[]lox.Stmt{
-					lox.ExpressionStmt{number(3)},
-					lox.ExpressionStmt{number(4)},
+			lox.ExpressionStmt{Expression: number(1)},
+			lox.BlockStmt{Statements: []lox.Stmt{
+				lox.ExpressionStmt{Expression: number(2)},
+				lox.BlockStmt{Statements: []lox.Stmt{
+					lox.ExpressionStmt{Expression: number(3)},
+					lox.ExpressionStmt{Expression: number(4)},
 					lox.BlockStmt{},
 				}},
-				lox.ExpressionStmt{number(5)},
+				lox.ExpressionStmt{Expression: number(5)},
 			}},
-			lox.BlockStmt{[]lox.Stmt{
-				lox.ExpressionStmt{number(6)},
+			lox.BlockStmt{Statements: []lox.Stmt{
+				lox.ExpressionStmt{Expression: number(6)},
+			}},
+		}},
+		{"{a: 1}; {a;}", []lox.Stmt{
+			lox.ExpressionStmt{Expression: &lox.MapExpr{
+				Brace:  token(lox.LeftBrace, "{"),
+				Keys:   []lox.Expr{variableExpr("a")},
+				Values: []lox.Expr{number(1)},
+			}},
+			lox.BlockStmt{Statements: []lox.Stmt{
+				lox.ExpressionStmt{Expression: variableExpr("a")},
 			}},
 		}},
 		{"while (a) a = a - 1;", []lox.Stmt{
 			lox.LoopStmt{
 				Condition: variableExpr("a"),
-				Body: lox.ExpressionStmt{&lox.AssignmentExpr{
+				Body: lox.ExpressionStmt{Expression: &lox.AssignmentExpr{
 					Name: token(lox.Identifier, "a"),
 					Value: &lox.BinaryExpr{
 						Left:     variableExpr("a"),
@@ -246,16 +288,16 @@ func TestParserStatements(t *testing.T) {
 		}},
 		{"for (;;) print 42;", []lox.Stmt{
 			lox.LoopStmt{
-				Condition: &lox.LiteralExpr{token(lox.Semicolon, ";"), true},
-				Body:      lox.PrintStmt{number(42)},
+				Condition: &lox.LiteralExpr{Token: token(lox.Semicolon, ";"), Value: true},
+				Body:      lox.PrintStmt{Expression: number(42)},
 			},
 		}},
 		{"for (var i = 0;;) print i;", []lox.Stmt{
-			lox.BlockStmt{[]lox.Stmt{
+			lox.BlockStmt{Statements: []lox.Stmt{
 				lox.VarStmt{Name: token(lox.Identifier, "i"), Init: number(0)},
 				lox.LoopStmt{
-					Condition: &lox.LiteralExpr{token(lox.Semicolon, ";"), true},
-					Body:      lox.PrintStmt{variableExpr("i")},
+					Condition: &lox.LiteralExpr{Token: token(lox.Semicolon, ";"), Value: true},
+					Body:      lox.PrintStmt{Expression: variableExpr("i")},
 				},
 			}},
 		}},
@@ -266,13 +308,13 @@ func TestParserStatements(t *testing.T) {
 					Operator: token(lox.Greater, ">"),
 					Right:    number(0),
 				},
-				Body: lox.PrintStmt{variableExpr("i")},
+				Body: lox.PrintStmt{Expression: variableExpr("i")},
 			},
 		}},
 		{"for (;; i = i+1) print i;", []lox.Stmt{
 			lox.LoopStmt{
-				Condition: &lox.LiteralExpr{token(lox.Semicolon, ";"), true},
-				Body:      lox.PrintStmt{variableExpr("i")},
+				Condition: &lox.LiteralExpr{Token: token(lox.Semicolon, ";"), Value: true},
+				Body:      lox.PrintStmt{Expression: variableExpr("i")},
 				OnLoop: &lox.AssignmentExpr{
 					Name: token(lox.Identifier, "i"),
 					Value: &lox.BinaryExpr{
@@ -285,13 +327,13 @@ func TestParserStatements(t *testing.T) {
 		}},
 		{"for (;; inc) { if (a) continue; continue; }", []lox.Stmt{
 			lox.LoopStmt{
-				Condition: &lox.LiteralExpr{token(lox.Semicolon, ";"), true},
-				Body: lox.BlockStmt{[]lox.Stmt{
+				Condition: &lox.LiteralExpr{Token: token(lox.Semicolon, ";"), Value: true},
+				Body: lox.BlockStmt{Statements: []lox.Stmt{
 					lox.IfStmt{
 						Condition: variableExpr("a"),
-						Then:      lox.ContinueStmt{token(lox.Continue, "continue")},
+						Then:      lox.ContinueStmt{Keyword: token(lox.Continue, "continue")},
 					},
-					lox.ContinueStmt{token(lox.Continue, "continue")},
+					lox.ContinueStmt{Keyword: token(lox.Continue, "continue")},
 				}},
 				OnLoop: variableExpr("inc"),
 			},
@@ -308,7 +350,7 @@ func TestParserStatements(t *testing.T) {
 					{
 						Name: token(lox.Identifier, "answer"),
 						Body: []lox.Stmt{
-							lox.PrintStmt{number(42)},
+							lox.PrintStmt{Expression: number(42)},
 						},
 					},
 				},
@@ -362,12 +404,22 @@ func TestParserStatements(t *testing.T) {
 				},
 			},
 		}},
+		{"class Foo < Bar { f(){} }", []lox.Stmt{
+			lox.ClassStmt{
+				Name:       token(lox.Identifier, "Foo"),
+				Superclass: variableExpr("Bar"),
+				Methods: []lox.FunctionStmt{
+					{Name: token(lox.Identifier, "f")},
+				},
+			},
+		}},
 	}
 
 	for _, test := range tests {
 		got := parseStmts(t, test.text)
 		opts := cmp.Options{
 			cmpopts.IgnoreFields(lox.Token{}, "Line"),
+			cmpopts.IgnoreTypes(lox.Span{}),
 			cmpopts.IgnoreFields(lox.LiteralExpr{}, "Token.Lexeme"),
 		}
 		if diff := cmp.Diff(test.want, got, opts); diff != "" {
@@ -375,3 +427,33 @@ func TestParserStatements(t *testing.T) {
 		}
 	}
 }
+
+func TestParserErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"(a) = 1;", "line 1 at '=': invalid target for assignment: want variable, get or index expression, got grouping expression"},
+		{"a = (b) = 1;", "line 1 at '=': invalid target for assignment: want variable, get or index expression, got grouping expression"},
+		{"a.b = (c) = 1;", "line 1 at '=': invalid target for assignment: want variable, get or index expression, got grouping expression"},
+		{"(a) = (b) = 1;", strings.Join([]string{
+			"line 1 at '=': invalid target for assignment: want variable, get or index expression, got grouping expression",
+			"line 1 at '=': invalid target for assignment: want variable, get or index expression, got grouping expression",
+		}, "\n")},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			tokens, err := lox.NewScanner(test.text).ScanTokens()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = lox.NewParser(tokens).Parse()
+			if err == nil {
+				t.Fatalf("want err, got nil")
+			}
+			if diff := cmp.Diff(test.want, err.Error()); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
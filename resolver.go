@@ -2,7 +2,6 @@ package lox
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/brunokim/kilox/errlist"
 )
@@ -25,8 +24,11 @@ const (
 	funcParam
 	className
 	thisKeyword
+	superKeyword
 	classVar
 	instanceVar
+	catchVar
+	moduleName
 )
 
 type classType int
@@ -34,6 +36,7 @@ type classType int
 const (
 	noClass classType = iota
 	someClass
+	subClass
 )
 
 type variableState struct {
@@ -41,7 +44,6 @@ type variableState struct {
 	decl      declType
 	index     int
 	isDefined bool
-	isRead    bool
 }
 
 type scope struct {
@@ -57,6 +59,12 @@ type Resolver struct {
 	currFunc  funcType
 	currClass classType
 	isInLoop  bool
+	// Whether resolving a field initializer, where 'this' and 'super' are not bound.
+	isInFieldInit bool
+
+	symbols    []Symbol
+	globals    map[string]Token
+	unresolved []int
 }
 
 func NewResolver(interpreter *Interpreter) *Resolver {
@@ -64,11 +72,13 @@ func NewResolver(interpreter *Interpreter) *Resolver {
 		i:         interpreter,
 		currFunc:  noFunc,
 		currClass: noClass,
+		globals:   make(map[string]Token),
 	}
 }
 
 func (r *Resolver) Resolve(stmts []Stmt) error {
 	r.resolveStmts(stmts)
+	r.resolveGlobalSymbols()
 	if len(r.errors) > 0 {
 		return errlist.Of[resolveError](r.errors)
 	}
@@ -79,6 +89,7 @@ func (r *Resolver) Resolve(stmts []Stmt) error {
 
 type resolveError struct {
 	token Token
+	code  string
 	msg   string
 }
 
@@ -89,6 +100,14 @@ func (err resolveError) Error() string {
 	return fmt.Sprintf("line %d at '%s': %s", err.token.Line, err.token.Lexeme, err.msg)
 }
 
+func (err resolveError) Span() Span {
+	return err.token.Span
+}
+
+func (err resolveError) Diagnostic() Diagnostic {
+	return Diagnostic{SeverityError, ResolvePhase, err.code, err.msg, err.token.Span}
+}
+
 func (r *Resolver) addError(err resolveError) {
 	r.errors = append(r.errors, err)
 }
@@ -116,7 +135,6 @@ func (s *scope) put(name Token, decl declType) {
 		decl:      decl,
 		index:     i,
 		isDefined: false,
-		isRead:    false,
 	})
 	s.index[name.Lexeme] = i
 }
@@ -125,19 +143,29 @@ func (r *Resolver) beginScope() {
 	r.scopes = append(r.scopes, newScope())
 }
 
+// endBodyScope ends a scope that runs stmts, recording its names for the debugger.
+func (r *Resolver) endBodyScope(stmts []Stmt) {
+	scope := r.scopes[len(r.scopes)-1]
+	names := make([]string, len(scope.vars))
+	for k, v := range scope.vars {
+		names[k] = v.name.Lexeme
+	}
+	r.i.recordScope(stmts, names)
+	r.endScope()
+}
+
 func (r *Resolver) endScope() {
-	n := len(r.scopes)
-	r.checkVariables(r.scopes[n-1])
-	r.scopes = r.scopes[:n-1]
+	r.scopes = r.scopes[:len(r.scopes)-1]
 }
 
 func (r *Resolver) declare(name Token, decl declType) {
+	r.addDeclSymbol(name, decl)
 	if len(r.scopes) == 0 {
 		return
 	}
 	scope := r.scopes[len(r.scopes)-1]
 	if _, ok := scope.index[name.Lexeme]; ok {
-		r.addError(resolveError{name, "already a variable with this name in scope"})
+		r.addError(resolveError{name, "redeclared-variable", "already a variable with this name in scope"})
 		return
 	}
 	scope.put(name, decl)
@@ -168,16 +196,17 @@ func (r *Resolver) resolveExpr(expr Expr) {
 	expr.Accept(r)
 }
 
-func (r *Resolver) resolveLocal(expr Expr, name Token) {
+// resolveLocal returns the state of the local variable referred by expr, or nil if it's global.
+func (r *Resolver) resolveLocal(expr Expr, name Token) *variableState {
 	n := len(r.scopes)
 	for dist := 0; dist < n; dist++ {
 		scope := r.scopes[(n-1)-dist]
 		if state, ok := scope.get(name.Lexeme); ok {
-			state.isRead = true
 			r.i.resolve(expr, dist, state.index)
-			return
+			return state
 		}
 	}
+	return nil
 }
 
 func (r *Resolver) resolveFunction(params []Token, body []Stmt, t funcType) {
@@ -190,7 +219,7 @@ func (r *Resolver) resolveFunction(params []Token, body []Stmt, t funcType) {
 		r.define(param)
 	}
 	r.resolveStmts(body)
-	r.endScope()
+	r.endBodyScope(body)
 }
 
 func (r *Resolver) resolveMethods(methods []FunctionStmt, isStatic bool) {
@@ -198,6 +227,7 @@ func (r *Resolver) resolveMethods(methods []FunctionStmt, isStatic bool) {
 	r.declare(token, thisKeyword)
 	r.define(token)
 	for _, method := range methods {
+		r.addPropertySymbol(nil, method.Name, true)
 		ftype := methodFunc
 		if method.Name.Lexeme == "init" && !isStatic {
 			ftype = initFunc
@@ -206,6 +236,23 @@ func (r *Resolver) resolveMethods(methods []FunctionStmt, isStatic bool) {
 	}
 }
 
+func (r *Resolver) beginSuperScope(superclass *VariableExpr) {
+	if superclass == nil {
+		return
+	}
+	r.beginScope()
+	token := Token{Lexeme: "super"}
+	r.declare(token, superKeyword)
+	r.define(token)
+}
+
+func (r *Resolver) endSuperScope(superclass *VariableExpr) {
+	if superclass == nil {
+		return
+	}
+	r.endScope()
+}
+
 func (r *Resolver) resolveVarDeclaration(name Token, init Expr, decl declType) {
 	r.declare(name, decl)
 	if init != nil {
@@ -214,24 +261,6 @@ func (r *Resolver) resolveVarDeclaration(name Token, init Expr, decl declType) {
 	r.define(name)
 }
 
-// TODO: the error output order is weird, because scopes are resolved in pre-order.
-// This means that 'fun unused(x) {}' reports first for 'x', and then for 'unused'.
-// Figure out how to execute this (or at least sort it) in post-order.
-func (r *Resolver) checkVariables(scope *scope) {
-	for _, state := range scope.vars {
-		if !state.isRead && !strings.HasSuffix(state.name.Lexeme, "_") {
-			switch state.decl {
-			case local:
-				r.addError(resolveError{state.name, "local variable is never read"})
-			case funcName:
-				r.addError(resolveError{state.name, "function is never read or called"})
-			case funcParam:
-				r.addError(resolveError{state.name, "function param is never read"})
-			}
-		}
-	}
-}
-
 // ----
 
 func (r *Resolver) VisitExpressionStmt(stmt ExpressionStmt) {
@@ -257,7 +286,7 @@ func (r *Resolver) VisitIfStmt(stmt IfStmt) {
 func (r *Resolver) VisitBlockStmt(stmt BlockStmt) {
 	r.beginScope()
 	r.resolveStmts(stmt.Statements)
-	r.endScope()
+	r.endBodyScope(stmt.Statements)
 }
 
 func (r *Resolver) VisitLoopStmt(stmt LoopStmt) {
@@ -272,13 +301,13 @@ func (r *Resolver) VisitLoopStmt(stmt LoopStmt) {
 
 func (r *Resolver) VisitBreakStmt(stmt BreakStmt) {
 	if !r.isInLoop {
-		r.addError(resolveError{stmt.Keyword, "'break' can only be used within loops"})
+		r.addError(resolveError{stmt.Keyword, "break-outside-loop", "'break' can only be used within loops"})
 	}
 }
 
 func (r *Resolver) VisitContinueStmt(stmt ContinueStmt) {
 	if !r.isInLoop {
-		r.addError(resolveError{stmt.Keyword, "'continue' can only be used within loops"})
+		r.addError(resolveError{stmt.Keyword, "continue-outside-loop", "'continue' can only be used within loops"})
 	}
 }
 
@@ -291,16 +320,45 @@ func (r *Resolver) VisitFunctionStmt(stmt FunctionStmt) {
 
 func (r *Resolver) VisitReturnStmt(stmt ReturnStmt) {
 	if r.currFunc == noFunc {
-		r.addError(resolveError{stmt.Keyword, "'return' can only be used within functions"})
+		r.addError(resolveError{stmt.Keyword, "return-outside-function", "'return' can only be used within functions"})
 	}
 	if stmt.Result != nil {
 		if r.currFunc == initFunc {
-			r.addError(resolveError{stmt.Keyword, "can't return a value from an initializer"})
+			r.addError(resolveError{stmt.Keyword, "return-value-from-init", "can't return a value from an initializer"})
 		}
 		r.resolveExpr(stmt.Result)
 	}
 }
 
+func (r *Resolver) VisitImportStmt(stmt ImportStmt) {
+	r.declare(stmt.Name, moduleName)
+	r.define(stmt.Name)
+}
+
+func (r *Resolver) VisitThrowStmt(stmt ThrowStmt) {
+	r.resolveExpr(stmt.Value)
+}
+
+// VisitTryStmt resolves each clause in its own scope. The catch variable is declared in the
+// same scope as the statements in the catch block, like function params.
+func (r *Resolver) VisitTryStmt(stmt TryStmt) {
+	r.beginScope()
+	r.resolveStmts(stmt.Body)
+	r.endBodyScope(stmt.Body)
+	if stmt.CatchName != nil {
+		r.beginScope()
+		r.declare(*stmt.CatchName, catchVar)
+		r.define(*stmt.CatchName)
+		r.resolveStmts(stmt.Catch)
+		r.endBodyScope(stmt.Catch)
+	}
+	if stmt.Finally != nil {
+		r.beginScope()
+		r.resolveStmts(stmt.Finally)
+		r.endBodyScope(stmt.Finally)
+	}
+}
+
 func (r *Resolver) VisitClassStmt(stmt ClassStmt) {
 	defer func(oldType classType) { r.currClass = oldType }(r.currClass)
 	r.currClass = someClass
@@ -308,19 +366,23 @@ func (r *Resolver) VisitClassStmt(stmt ClassStmt) {
 	r.declare(stmt.Name, className)
 	r.define(stmt.Name)
 
+	if stmt.Superclass != nil {
+		r.currClass = subClass
+		if stmt.Superclass.Name.Lexeme == stmt.Name.Lexeme {
+			r.addError(resolveError{stmt.Superclass.Name, "self-inheritance", "a class can't inherit from itself"})
+		}
+		r.resolveExpr(stmt.Superclass)
+	}
+
 	// Static and instance initializers cannot refer to other names
 	// declared in the instance and class scopes, so they are initialized
 	// in the surrounding scope, the same as the class.
-	for _, decl := range stmt.StaticVars {
-		if decl.Init != nil {
-			r.resolveExpr(decl.Init)
-		}
-	}
-	for _, decl := range stmt.Vars {
-		if decl.Init != nil {
-			r.resolveExpr(decl.Init)
-		}
-	}
+	r.resolveFieldInits(stmt.StaticVars)
+	r.resolveFieldInits(stmt.Vars)
+	// If there's a superclass, 'super' is declared in a scope immediately
+	// enclosing the scope where 'this' is declared, both for static and
+	// instance methods.
+	r.beginSuperScope(stmt.Superclass)
 	r.beginScope()
 	{
 		// class scope
@@ -328,6 +390,7 @@ func (r *Resolver) VisitClassStmt(stmt ClassStmt) {
 		for _, decl := range stmt.StaticVars {
 			r.resolveVarDeclaration(decl.Name, nil, classVar)
 		}
+		r.beginSuperScope(stmt.Superclass)
 		r.beginScope()
 		{
 			// instance scope
@@ -337,8 +400,20 @@ func (r *Resolver) VisitClassStmt(stmt ClassStmt) {
 			}
 		}
 		r.endScope()
+		r.endSuperScope(stmt.Superclass)
 	}
 	r.endScope()
+	r.endSuperScope(stmt.Superclass)
+}
+
+func (r *Resolver) resolveFieldInits(decls []VarStmt) {
+	defer func(old bool) { r.isInFieldInit = old }(r.isInFieldInit)
+	r.isInFieldInit = true
+	for _, decl := range decls {
+		if decl.Init != nil {
+			r.resolveExpr(decl.Init)
+		}
+	}
 }
 
 // ----
@@ -359,20 +434,19 @@ func (r *Resolver) VisitUnaryExpr(expr *UnaryExpr) {
 }
 
 func (r *Resolver) VisitVariableExpr(expr *VariableExpr) {
-	if len(r.scopes) == 0 {
-		return
-	}
-	scope := r.scopes[len(r.scopes)-1]
-	state, ok := scope.get(expr.Name.Lexeme)
-	if ok && !state.isDefined {
-		r.addError(resolveError{expr.Name, "can't read local variable in its own initializer"})
+	if len(r.scopes) > 0 {
+		scope := r.scopes[len(r.scopes)-1]
+		state, ok := scope.get(expr.Name.Lexeme)
+		if ok && !state.isDefined {
+			r.addError(resolveError{expr.Name, "self-initializer", "can't read local variable in its own initializer"})
+		}
 	}
-	r.resolveLocal(expr, expr.Name)
+	r.addVariableSymbol(expr, expr.Name, r.resolveLocal(expr, expr.Name))
 }
 
 func (r *Resolver) VisitAssignmentExpr(expr *AssignmentExpr) {
 	r.resolveExpr(expr.Value)
-	r.resolveLocal(expr, expr.Name)
+	r.addVariableSymbol(expr, expr.Name, r.resolveLocal(expr, expr.Name))
 }
 
 func (r *Resolver) VisitLogicExpr(expr *LogicExpr) {
@@ -394,16 +468,59 @@ func (r *Resolver) VisitFunctionExpr(expr *FunctionExpr) {
 func (r *Resolver) VisitGetExpr(expr *GetExpr) {
 	r.resolveExpr(expr.Object)
 	// We don't resolve property access statically, only dinamically.
+	r.addPropertySymbol(expr, expr.Name, false)
 }
 
 func (r *Resolver) VisitSetExpr(expr *SetExpr) {
 	r.resolveExpr(expr.Value)
 	r.resolveExpr(expr.Object)
+	_, isThis := expr.Object.(*ThisExpr)
+	r.addPropertySymbol(expr, expr.Name, isThis)
 }
 
 func (r *Resolver) VisitThisExpr(expr *ThisExpr) {
 	if r.currClass == noClass {
-		r.addError(resolveError{expr.Keyword, "'this' can only be used within classes"})
+		r.addError(resolveError{expr.Keyword, "this-outside-class", "'this' can only be used within classes"})
+	} else if r.isInFieldInit {
+		r.addError(resolveError{expr.Keyword, "this-in-field-init", "'this' can't be used in a field initializer"})
+	}
+	r.resolveLocal(expr, expr.Keyword)
+}
+
+func (r *Resolver) VisitListExpr(expr *ListExpr) {
+	for _, elem := range expr.Elements {
+		r.resolveExpr(elem)
+	}
+}
+
+func (r *Resolver) VisitIndexExpr(expr *IndexExpr) {
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+}
+
+func (r *Resolver) VisitSetIndexExpr(expr *SetIndexExpr) {
+	r.resolveExpr(expr.Value)
+	r.resolveExpr(expr.Object)
+	r.resolveExpr(expr.Index)
+}
+
+func (r *Resolver) VisitMapExpr(expr *MapExpr) {
+	for i, key := range expr.Keys {
+		r.resolveExpr(key)
+		r.resolveExpr(expr.Values[i])
+	}
+}
+
+func (r *Resolver) VisitSuperExpr(expr *SuperExpr) {
+	switch r.currClass {
+	case noClass:
+		r.addError(resolveError{expr.Keyword, "super-outside-class", "'super' can only be used within classes"})
+	case someClass:
+		r.addError(resolveError{expr.Keyword, "super-without-superclass", "'super' can only be used within a subclass"})
+	default:
+		if r.isInFieldInit {
+			r.addError(resolveError{expr.Keyword, "super-in-field-init", "'super' can't be used in a field initializer"})
+		}
 	}
 	r.resolveLocal(expr, expr.Keyword)
 }
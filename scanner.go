@@ -4,38 +4,48 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/brunokim/kilox/errlist"
 )
 
 var keywords = map[string]TokenType{
 	"and":      And,
+	"as":       As,
 	"break":    Break,
+	"catch":    Catch,
 	"class":    Class,
 	"continue": Continue,
 	"else":     Else,
 	"false":    False,
+	"finally":  Finally,
 	"for":      For,
 	"fun":      Fun,
 	"if":       If,
+	"import":   Import,
 	"nil":      Nil,
 	"or":       Or,
 	"print":    Print,
 	"return":   Return,
 	"super":    Super,
 	"this":     This,
+	"throw":    Throw,
 	"true":     True,
+	"try":      Try,
 	"var":      Var,
 	"while":    While,
 }
 
 type Scanner struct {
-	source  string
-	tokens  []Token
-	start   int
-	current int
-	line    int
-	errors  []scanError
+	source    string
+	tokens    []Token
+	comments  []Token
+	start     int
+	startPos  Position
+	current   int
+	line      int
+	lineStart int
+	errors    []scanError
 }
 
 func NewScanner(source string) *Scanner {
@@ -48,12 +58,14 @@ func NewScanner(source string) *Scanner {
 func (s *Scanner) ScanTokens() ([]Token, error) {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startPos = s.position()
 		s.scanToken()
 	}
 	if len(s.errors) > 0 {
 		return nil, errlist.Of[scanError](s.errors)
 	}
-	s.tokens = append(s.tokens, Token{EOF, "", nil, s.line})
+	end := s.position()
+	s.tokens = append(s.tokens, Token{EOF, "", nil, s.line, Span{end, end}})
 	return s.tokens, nil
 }
 
@@ -69,8 +81,14 @@ func (s *Scanner) scanToken() {
 		s.addToken(LeftBrace)
 	case '}':
 		s.addToken(RightBrace)
+	case '[':
+		s.addToken(LeftBracket)
+	case ']':
+		s.addToken(RightBracket)
 	case ',':
 		s.addToken(Comma)
+	case ':':
+		s.addToken(Colon)
 	case '.':
 		s.addToken(Dot)
 	case '-':
@@ -113,6 +131,7 @@ func (s *Scanner) scanToken() {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			s.addComment()
 		} else {
 			s.addToken(Slash)
 		}
@@ -121,6 +140,7 @@ func (s *Scanner) scanToken() {
 		// Do nothing
 	case '\n':
 		s.line++
+		s.lineStart = s.current
 	// String start
 	case '"':
 		s.readString()
@@ -130,7 +150,7 @@ func (s *Scanner) scanToken() {
 		} else if isAlpha(ch) {
 			s.readIdentifier()
 		} else {
-			s.addError(s.line, fmt.Sprintf("unexpected character: %c", ch))
+			s.addError(s.line, s.span(), "unexpected-character", fmt.Sprintf("unexpected character: %c", ch))
 		}
 	}
 }
@@ -170,20 +190,23 @@ func (s *Scanner) readString() {
 	for s.peek() != '"' && !s.isAtEnd() {
 		if s.peek() == '\n' {
 			s.line++
+			s.lineStart = s.current + 1
 		}
 		if s.peek() != '\\' {
 			s.advance()
 			continue
 		}
 		// Escaped character.
-		if !(s.peekNext() == '"' || s.peekNext() == '\\') {
-			s.addError(s.line, fmt.Sprintf("invalid escaped character '%c' in string", s.peekNext()))
-		}
+		escapeStart := s.position()
 		s.advance()
 		s.advance()
+		if ch := s.previous(); !(ch == '"' || ch == '\\') {
+			span := Span{escapeStart, s.position()}
+			s.addError(s.line, span, "invalid-escape", fmt.Sprintf("invalid escaped character '%c' in string", ch))
+		}
 	}
 	if s.isAtEnd() {
-		s.addError(s.line, fmt.Sprintf("unterminated string"))
+		s.addError(s.line, s.span(), "unterminated-string", "unterminated string")
 		return
 	}
 	s.advance()                                // Consume the final '"'.
@@ -193,12 +216,22 @@ func (s *Scanner) readString() {
 	s.addLiteralToken(String, value)
 }
 
+// Comments returns the comments found by ScanTokens, in source order. They are not part
+// of the token stream, but are used to regenerate the source.
+func (s *Scanner) Comments() []Token {
+	return s.comments
+}
+
 // ----
 
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
 
+func (s *Scanner) previous() rune {
+	return rune(s.source[s.current-1])
+}
+
 func (s *Scanner) advance() rune {
 	s.current++
 	return rune(s.source[s.current-1])
@@ -229,20 +262,38 @@ func (s *Scanner) peekNext() rune {
 	return rune(s.source[s.current+1])
 }
 
+// position returns the position of the current character.
+func (s *Scanner) position() Position {
+	column := utf8.RuneCountInString(s.source[s.lineStart:s.current]) + 1
+	return Position{Offset: s.current, Line: s.line, Column: column}
+}
+
+// span returns the span of the token being scanned.
+func (s *Scanner) span() Span {
+	return Span{s.startPos, s.position()}
+}
+
 func (s *Scanner) addToken(tokenType TokenType) {
 	text := s.source[s.start:s.current]
-	s.tokens = append(s.tokens, Token{tokenType, text, nil, s.line})
+	s.tokens = append(s.tokens, Token{tokenType, text, nil, s.line, s.span()})
 }
 
 func (s *Scanner) addLiteralToken(tokenType TokenType, literal any) {
 	text := s.source[s.start:s.current]
-	s.tokens = append(s.tokens, Token{tokenType, text, literal, s.line})
+	s.tokens = append(s.tokens, Token{tokenType, text, literal, s.line, s.span()})
+}
+
+func (s *Scanner) addComment() {
+	text := strings.TrimRight(s.source[s.start:s.current], " \t\r")
+	s.comments = append(s.comments, Token{Comment, text, nil, s.line, s.span()})
 }
 
 // ----
 
 type scanError struct {
 	line int
+	span Span
+	code string
 	msg  string
 }
 
@@ -250,8 +301,16 @@ func (err scanError) Error() string {
 	return fmt.Sprintf("line %d: %s", err.line, err.msg)
 }
 
-func (s *Scanner) addError(line int, msg string) {
-	s.errors = append(s.errors, scanError{line, msg})
+func (err scanError) Span() Span {
+	return err.span
+}
+
+func (err scanError) Diagnostic() Diagnostic {
+	return Diagnostic{SeverityError, ScanPhase, err.code, err.msg, err.span}
+}
+
+func (s *Scanner) addError(line int, span Span, code, msg string) {
+	s.errors = append(s.errors, scanError{line, span, code, msg})
 }
 
 // ----
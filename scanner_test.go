@@ -30,6 +30,19 @@ func TestScanner(t *testing.T) {
 			token(lox.RightParen, ")"),
 			token(lox.EOF, ""),
 		}},
+		{"{a: 1}", []lox.Token{
+			token(lox.LeftBrace, "{"),
+			token(lox.Identifier, "a"),
+			token(lox.Colon, ":"),
+			literalToken(lox.Number, "1", 1.0),
+			token(lox.RightBrace, "}"),
+			token(lox.EOF, "")}},
+		{"xs[0]", []lox.Token{
+			token(lox.Identifier, "xs"),
+			token(lox.LeftBracket, "["),
+			literalToken(lox.Number, "0", 0.0),
+			token(lox.RightBracket, "]"),
+			token(lox.EOF, "")}},
 		{`"abc \" def \\ ghi"`, []lox.Token{
 			literalToken(lox.String, `"abc \" def \\ ghi"`, `abc " def \ ghi`),
 			token(lox.EOF, ""),
@@ -47,7 +60,7 @@ func TestScanner(t *testing.T) {
 			if err != nil {
 				t.Fatalf("want nil, got err: %v", err)
 			}
-			opts := cmp.Options{cmpopts.IgnoreFields(lox.Token{}, "Line")}
+			opts := cmp.Options{cmpopts.IgnoreFields(lox.Token{}, "Line", "Span")}
 			if d := cmp.Diff(test.want, tokens, opts); d != "" {
 				t.Errorf("(-want, +got)%s", d)
 			}
@@ -88,3 +101,46 @@ line 3: unterminated string`},
 		})
 	}
 }
+
+func TestScannerSpans(t *testing.T) {
+	text := "var a =\n\t\"ção\" + 10;"
+	s := lox.NewScanner(text)
+	tokens, err := s.ScanTokens()
+	if err != nil {
+		t.Fatalf("want nil, got err: %v", err)
+	}
+	want := []string{"1:1-1:4", "1:5-1:6", "1:7-1:8", "2:2-2:7", "2:8-2:9", "2:10-2:12", "2:12-2:13", "2:13-2:13"}
+	var got []string
+	for _, token := range tokens {
+		got = append(got, token.Span.String())
+	}
+	if d := cmp.Diff(want, got); d != "" {
+		t.Errorf("(-want, +got)%s", d)
+	}
+	// Offsets are counted in bytes, while columns are counted in characters.
+	str := tokens[3]
+	if got := text[str.Span.Start.Offset:str.Span.End.Offset]; got != str.Lexeme {
+		t.Errorf("want %q, got %q", str.Lexeme, got)
+	}
+}
+
+func TestScannerComments(t *testing.T) {
+	text := "// header\nvar a = 1; // trailing  \n// footer"
+	s := lox.NewScanner(text)
+	tokens, err := s.ScanTokens()
+	if err != nil {
+		t.Fatalf("want nil, got err: %v", err)
+	}
+	if len(tokens) != 6 {
+		t.Errorf("want comments out of the token stream, got %v", tokens)
+	}
+	want := []lox.Token{
+		{TokenType: lox.Comment, Lexeme: "// header", Line: 1},
+		{TokenType: lox.Comment, Lexeme: "// trailing", Line: 2},
+		{TokenType: lox.Comment, Lexeme: "// footer", Line: 3},
+	}
+	opts := cmp.Options{cmpopts.IgnoreFields(lox.Token{}, "Span")}
+	if d := cmp.Diff(want, s.Comments(), opts); d != "" {
+		t.Errorf("(-want, +got)%s", d)
+	}
+}
@@ -0,0 +1,96 @@
+package lox
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StackFrame is a function call that was active when a runtime error happened.
+type StackFrame struct {
+	// Name of the called function, or empty for the top-level script.
+	Function string
+	// Token being evaluated within the function: the error location for the innermost frame,
+	// and the call site of the next frame for the others.
+	Token Token
+}
+
+func (f StackFrame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<script>"
+	}
+	return fmt.Sprintf("at %s (line %d)", name, f.Token.Line)
+}
+
+// StackTrace returns the call stack of a runtime error returned by Interpret, from the
+// innermost frame to the top-level script. Returns nil if err is not a runtime error.
+func StackTrace(err error) []StackFrame {
+	var traced tracedError
+	if errors.As(err, &traced) {
+		return traced.trace
+	}
+	return nil
+}
+
+// ----
+
+type callFrame struct {
+	name  string
+	paren Token
+	// Environment of the caller, where the call was made.
+	env *Environment
+}
+
+// tracedError is a runtime error annotated with the call stack where it happened.
+type tracedError struct {
+	runtimeError
+	trace []StackFrame
+}
+
+func (i *Interpreter) pushFrame(callee any, paren Token) {
+	i.frames = append(i.frames, callFrame{calleeName(callee), paren, i.env})
+}
+
+// callToken returns the parenthesis of the innermost call, where errors within builtins are
+// reported.
+func (i *Interpreter) callToken() Token {
+	if len(i.frames) == 0 {
+		return Token{}
+	}
+	return i.frames[len(i.frames)-1].paren
+}
+
+func (i *Interpreter) popFrame() {
+	i.frames = i.frames[:len(i.frames)-1]
+}
+
+// stackTrace returns the current call stack, and resets it.
+func (i *Interpreter) stackTrace(token Token) []StackFrame {
+	trace := i.callStack(token)
+	i.frames = i.frames[:0]
+	return trace
+}
+
+// callStack returns the current call stack, where token is being evaluated in the innermost frame.
+func (i *Interpreter) callStack(token Token) []StackFrame {
+	trace := make([]StackFrame, 0, len(i.frames)+1)
+	for k := len(i.frames) - 1; k >= 0; k-- {
+		trace = append(trace, StackFrame{i.frames[k].name, token})
+		token = i.frames[k].paren
+	}
+	trace = append(trace, StackFrame{"", token})
+	return trace
+}
+
+func calleeName(callee any) string {
+	switch f := callee.(type) {
+	case function:
+		return f.name
+	case class:
+		return f.meta.name
+	case *native:
+		return f.name
+	default:
+		return fmt.Sprint(callee)
+	}
+}
@@ -0,0 +1,121 @@
+package lox_test
+
+import (
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+	"github.com/lithammer/dedent"
+)
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{
+			"print 1 + nil;",
+			[]string{"at <script> (line 1)"},
+		},
+		{
+			dedent.Dedent(`
+                fun inner(x) {
+                    return x + nil;
+                }
+                fun outer() {
+                    return inner(1);
+                }
+                print outer();`),
+			[]string{
+				"at inner (line 3)",
+				"at outer (line 6)",
+				"at <script> (line 8)",
+			},
+		},
+		{
+			dedent.Dedent(`
+                class Foo {
+                    init() {
+                        this.bar(1);
+                    }
+                    bar(x) {
+                        x();
+                    }
+                }
+                var f = fun() { return Foo(); };
+                f();`),
+			[]string{
+				"at bar (line 7)",
+				"at Foo (line 4)",
+				"at anonymous (line 10)",
+				"at <script> (line 11)",
+			},
+		},
+		{
+			// Arity errors are reported at the caller.
+			dedent.Dedent(`
+                fun f(x) { print x; }
+                fun g() { f(); }
+                g();`),
+			[]string{
+				"at g (line 3)",
+				"at <script> (line 4)",
+			},
+		},
+		{
+			// Errors within builtins are reported at their call.
+			dedent.Dedent(`
+                fun size(x) {
+                    return len(x);
+                }
+                size(1);`),
+			[]string{
+				"at <native fn len> (line 3)",
+				"at size (line 3)",
+				"at <script> (line 5)",
+			},
+		},
+		{
+			"print keys([1]);",
+			[]string{
+				"at <native fn keys> (line 1)",
+				"at <script> (line 1)",
+			},
+		},
+		{
+			// Calls unwound by a caught error are not part of the trace.
+			dedent.Dedent(`
+                fun fail() { throw "oops"; }
+                fun g() {
+                    try { fail(); } catch (e) { print e; }
+                    throw "again";
+                }
+                g();`),
+			[]string{
+				"at g (line 5)",
+				"at <script> (line 7)",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			i := lox.NewInterpreter()
+			_, err := runLoxWith(i, test.text, map[string]bool{"typing": false})
+			if err == nil {
+				t.Fatalf("want error, got nil")
+			}
+			var got []string
+			for _, frame := range lox.StackTrace(err) {
+				got = append(got, frame.String())
+			}
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+			// The stack is reset after an error.
+			_, err = runLoxWith(i, "print nil + 1;", nil)
+			if diff := cmp.Diff(1, len(lox.StackTrace(err))); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
@@ -1,9 +1,12 @@
 // Generated file, do not modify
-// Invocation: gen_ast -spec ./cmd/gen_ast/stmt.spec -dest stmt.go
+// Invocation: gen_ast -spec ./cmd/gen_ast/stmt.spec -dest stmt.go -extensions span
 package lox
 
 type Stmt interface {
 	Accept(v stmtVisitor)
+
+	Pos() Position
+	End() Position
 }
 
 type stmtVisitor interface {
@@ -18,62 +21,99 @@ type stmtVisitor interface {
 	VisitFunctionStmt(s FunctionStmt)
 	VisitReturnStmt(s ReturnStmt)
 	VisitClassStmt(s ClassStmt)
+	VisitThrowStmt(s ThrowStmt)
+	VisitTryStmt(s TryStmt)
+	VisitImportStmt(s ImportStmt)
 }
 
 type ExpressionStmt struct {
 	Expression Expr
+	Span       Span
 }
 
 type PrintStmt struct {
 	Expression Expr
+	Span       Span
 }
 
 type VarStmt struct {
 	Name Token
 	Init Expr
+	Span Span
 }
 
 type IfStmt struct {
 	Condition Expr
 	Then      Stmt
 	Else      Stmt
+	Span      Span
 }
 
 type BlockStmt struct {
 	Statements []Stmt
+	Span       Span
 }
 
 type LoopStmt struct {
 	Condition Expr
 	Body      Stmt
 	OnLoop    Expr
+	Span      Span
 }
 
 type BreakStmt struct {
 	Keyword Token
+	Span    Span
 }
 
 type ContinueStmt struct {
 	Keyword Token
+	Span    Span
 }
 
 type FunctionStmt struct {
 	Name   Token
 	Params []Token
 	Body   []Stmt
+	Span   Span
 }
 
 type ReturnStmt struct {
 	Keyword Token
 	Result  Expr
+	Span    Span
 }
 
 type ClassStmt struct {
 	Name          Token
+	Superclass    *VariableExpr
 	Methods       []FunctionStmt
 	Vars          []VarStmt
 	StaticMethods []FunctionStmt
 	StaticVars    []VarStmt
+	Span          Span
+}
+
+type ThrowStmt struct {
+	Keyword Token
+	Value   Expr
+	Span    Span
+}
+
+type TryStmt struct {
+	Keyword   Token
+	Body      []Stmt
+	CatchName *Token
+	Catch     []Stmt
+	Finally   []Stmt
+	Span      Span
+}
+
+type ImportStmt struct {
+	Keyword Token
+	Path    Token
+	Name    Token
+	Span    Span
 }
 
 func (s ExpressionStmt) Accept(v stmtVisitor) {
@@ -119,3 +159,44 @@ func (s ReturnStmt) Accept(v stmtVisitor) {
 func (s ClassStmt) Accept(v stmtVisitor) {
 	v.VisitClassStmt(s)
 }
+
+func (s ThrowStmt) Accept(v stmtVisitor) {
+	v.VisitThrowStmt(s)
+}
+
+func (s TryStmt) Accept(v stmtVisitor) {
+	v.VisitTryStmt(s)
+}
+
+func (s ImportStmt) Accept(v stmtVisitor) {
+	v.VisitImportStmt(s)
+}
+
+func (s ExpressionStmt) Pos() Position { return s.Span.Start }
+func (s ExpressionStmt) End() Position { return s.Span.End }
+func (s PrintStmt) Pos() Position      { return s.Span.Start }
+func (s PrintStmt) End() Position      { return s.Span.End }
+func (s VarStmt) Pos() Position        { return s.Span.Start }
+func (s VarStmt) End() Position        { return s.Span.End }
+func (s IfStmt) Pos() Position         { return s.Span.Start }
+func (s IfStmt) End() Position         { return s.Span.End }
+func (s BlockStmt) Pos() Position      { return s.Span.Start }
+func (s BlockStmt) End() Position      { return s.Span.End }
+func (s LoopStmt) Pos() Position       { return s.Span.Start }
+func (s LoopStmt) End() Position       { return s.Span.End }
+func (s BreakStmt) Pos() Position      { return s.Span.Start }
+func (s BreakStmt) End() Position      { return s.Span.End }
+func (s ContinueStmt) Pos() Position   { return s.Span.Start }
+func (s ContinueStmt) End() Position   { return s.Span.End }
+func (s FunctionStmt) Pos() Position   { return s.Span.Start }
+func (s FunctionStmt) End() Position   { return s.Span.End }
+func (s ReturnStmt) Pos() Position     { return s.Span.Start }
+func (s ReturnStmt) End() Position     { return s.Span.End }
+func (s ClassStmt) Pos() Position      { return s.Span.Start }
+func (s ClassStmt) End() Position      { return s.Span.End }
+func (s ThrowStmt) Pos() Position      { return s.Span.Start }
+func (s ThrowStmt) End() Position      { return s.Span.End }
+func (s TryStmt) Pos() Position        { return s.Span.Start }
+func (s TryStmt) End() Position        { return s.Span.End }
+func (s ImportStmt) Pos() Position     { return s.Span.Start }
+func (s ImportStmt) End() Position     { return s.Span.End }
@@ -0,0 +1,75 @@
+package lox
+
+// SymbolKind distinguishes variables, that are resolved statically, from properties, that
+// are only known at runtime.
+type SymbolKind int
+
+const (
+	VariableSymbol SymbolKind = iota
+	PropertySymbol
+)
+
+// Symbol is an occurrence of a name in the source, as found by the Resolver. Symbols are
+// meant for tools like editors, that navigate between declarations and references.
+type Symbol struct {
+	Kind SymbolKind
+	Name Token
+	// Declaration of a variable, that is the same as Name if this occurrence is the declaration.
+	// It's empty for properties and for globals that are not declared in the source, like builtins.
+	Decl Token
+	// Whether this occurrence declares a variable or property. Properties are declared within
+	// class bodies, or by assignment to a field of 'this'.
+	IsDecl bool
+	// Expression where the name occurs, if any.
+	Expr Expr
+}
+
+// Symbols returns the names found by Resolve, in the order they were visited.
+func (r *Resolver) Symbols() []Symbol {
+	return r.symbols
+}
+
+// ----
+
+func (r *Resolver) addDeclSymbol(name Token, decl declType) {
+	switch decl {
+	case thisKeyword, superKeyword:
+		// These names are implicitly declared, and don't appear in the source.
+		return
+	case classVar, instanceVar:
+		r.symbols = append(r.symbols, Symbol{Kind: PropertySymbol, Name: name, IsDecl: true})
+		return
+	}
+	if len(r.scopes) == 0 {
+		if _, ok := r.globals[name.Lexeme]; !ok {
+			r.globals[name.Lexeme] = name
+		}
+	}
+	r.symbols = append(r.symbols, Symbol{Kind: VariableSymbol, Name: name, Decl: name, IsDecl: true})
+}
+
+func (r *Resolver) addVariableSymbol(expr Expr, name Token, state *variableState) {
+	sym := Symbol{Kind: VariableSymbol, Name: name, Expr: expr}
+	if state != nil {
+		sym.Decl = state.name
+	} else {
+		// Globals may be declared after being referenced within a function, so their
+		// declarations are only looked up after resolving all statements.
+		r.unresolved = append(r.unresolved, len(r.symbols))
+	}
+	r.symbols = append(r.symbols, sym)
+}
+
+func (r *Resolver) addPropertySymbol(expr Expr, name Token, isDecl bool) {
+	r.symbols = append(r.symbols, Symbol{Kind: PropertySymbol, Name: name, IsDecl: isDecl, Expr: expr})
+}
+
+func (r *Resolver) resolveGlobalSymbols() {
+	for _, k := range r.unresolved {
+		sym := &r.symbols[k]
+		if decl, ok := r.globals[sym.Name.Lexeme]; ok {
+			sym.Decl = decl
+		}
+	}
+	r.unresolved = nil
+}
@@ -0,0 +1,63 @@
+package lox_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/google/go-cmp/cmp"
+	"github.com/lithammer/dedent"
+)
+
+func TestSymbols(t *testing.T) {
+	text := dedent.Dedent(`
+        fun f(x) {
+            return g(x) + len([]);
+        }
+        class A {
+            var y;
+            init() { this.z = 1; }
+        }
+        var g = fun(a) { return A().z + a; };
+        f(1);`)
+	stmts := parseStmts(t, text)
+	r := lox.NewResolver(lox.NewInterpreter())
+	if err := r.Resolve(stmts); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, sym := range r.Symbols() {
+		s := fmt.Sprintf("%s@%v", sym.Name.Lexeme, sym.Name.Span.Start)
+		switch {
+		case sym.Kind == lox.PropertySymbol && sym.IsDecl:
+			s += " property decl"
+		case sym.Kind == lox.PropertySymbol:
+			s += " property"
+		case sym.IsDecl:
+			s += " decl"
+		case sym.Decl.Lexeme != "":
+			s += fmt.Sprintf(" -> %v", sym.Decl.Span.Start)
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"f@2:5 decl",
+		"x@2:7 decl",
+		"g@3:12 -> 9:5",
+		"x@3:14 -> 2:7",
+		"len@3:19",
+		"A@5:7 decl",
+		"init@7:5 property decl",
+		"z@7:19 property decl",
+		"y@6:9 property decl",
+		"g@9:5 decl",
+		"a@9:13 decl",
+		"A@9:25 -> 5:7",
+		"z@9:29 property",
+		"a@9:33 -> 9:13",
+		"f@10:1 -> 2:5",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}
@@ -11,7 +11,10 @@ const (
 	RightParen
 	LeftBrace
 	RightBrace
+	LeftBracket
+	RightBracket
 	Comma
+	Colon
 	Dot
 	Minus
 	Plus
@@ -36,26 +39,35 @@ const (
 
 	// Keywords.
 	And
+	As
 	Break
+	Catch
 	Class
 	Continue
 	Else
 	False
+	Finally
 	Fun
 	For
 	If
+	Import
 	Nil
 	Or
 	Print
 	Return
 	Super
 	This
+	Throw
 	True
+	Try
 	Var
 	While
 
 	// Sentinel for end-of-file.
 	EOF
+
+	// Trivia, that is kept apart from the token stream.
+	Comment
 )
 
 type Token struct {
@@ -63,8 +75,40 @@ type Token struct {
 	Lexeme    string
 	Literal   any
 	Line      int
+	Span      Span
 }
 
 func (t Token) String() string {
 	return fmt.Sprintf("%v %v %v", t.TokenType, t.Lexeme, t.Literal)
 }
+
+// ----
+
+// Position is a location in the source text.
+type Position struct {
+	// Byte offset, starting at 0.
+	Offset int `json:"offset"`
+	// Line number, starting at 1.
+	Line int `json:"line"`
+	// Column number counted in characters, starting at 1.
+	Column int `json:"column"`
+}
+
+func (pos Position) String() string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// Span is a range of the source text, from Start up to End, exclusive.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%v-%v", s.Start, s.End)
+}
+
+// Contains reports whether offset is within the span.
+func (s Span) Contains(offset int) bool {
+	return s.Start.Offset <= offset && offset < s.End.Offset
+}
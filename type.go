@@ -12,7 +12,11 @@ type typeVisitor interface {
 	VisitNumberType(t NumberType)
 	VisitStringType(t StringType)
 	VisitFunctionType(t FunctionType)
+	VisitListType(t ListType)
+	VisitMapType(t MapType)
 	VisitRefType(t *RefType)
+	VisitClassType(t *ClassType)
+	VisitInstanceType(t InstanceType)
 }
 
 type NilType struct {
@@ -36,9 +40,31 @@ type FunctionType struct {
 	Return Type
 }
 
+type ListType struct {
+	Element Type
+}
+
+type MapType struct {
+	Key   Type
+	Value Type
+}
+
 type RefType struct {
 	Value Type
 	ID    int
+	Attrs []Attribute
+}
+
+type ClassType struct {
+	Name       Token
+	Superclass *ClassType
+	Fields     map[string]Type
+	Methods    map[string]Type
+	Statics    map[string]Type
+}
+
+type InstanceType struct {
+	Class *ClassType
 }
 
 func (t NilType) Accept(v typeVisitor) {
@@ -61,6 +87,22 @@ func (t FunctionType) Accept(v typeVisitor) {
 	v.VisitFunctionType(t)
 }
 
+func (t ListType) Accept(v typeVisitor) {
+	v.VisitListType(t)
+}
+
+func (t MapType) Accept(v typeVisitor) {
+	v.VisitMapType(t)
+}
+
 func (t *RefType) Accept(v typeVisitor) {
 	v.VisitRefType(t)
 }
+
+func (t *ClassType) Accept(v typeVisitor) {
+	v.VisitClassType(t)
+}
+
+func (t InstanceType) Accept(v typeVisitor) {
+	v.VisitInstanceType(t)
+}
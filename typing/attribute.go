@@ -0,0 +1,206 @@
+package typing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/brunokim/kilox"
+)
+
+// Verifier is an attribute that checks the bindings of its ref, like the verify_attributes
+// hook of Prolog's attributed variables.
+type Verifier interface {
+	lox.Attribute
+	// Verify is called before x, that has this attribute, is bound to t, and returns an error
+	// if the binding is not allowed. t is either an unbound ref, whose attributes may be
+	// merged with this one, or another type. Further constraints are added with b.
+	Verify(b Binder, x *lox.RefType, t lox.Type) error
+}
+
+// Binder is the state of a unification, as seen by verifiers. Changes are recorded, so that
+// they are undone together with the unification.
+type Binder interface {
+	// Unify adds a pair of types to be unified after the current one.
+	Unify(t1, t2 lox.Type)
+	// Unifies returns whether t1 and t2 unify, without binding any of their refs.
+	Unifies(t1, t2 lox.Type) bool
+	// SetAttrs replaces the attributes of an unbound ref.
+	SetAttrs(x *lox.RefType, attrs []lox.Attribute)
+}
+
+// findAttr returns the first attribute of type A, and its index, or -1 if there's none.
+func findAttr[A lox.Attribute](attrs []lox.Attribute) (A, int) {
+	for i, attr := range attrs {
+		if a, ok := attr.(A); ok {
+			return a, i
+		}
+	}
+	var zero A
+	return zero, -1
+}
+
+// putAttr returns a copy of attrs with attr at index i, or at the end if i is negative.
+func putAttr(attrs []lox.Attribute, i int, attr lox.Attribute) []lox.Attribute {
+	if i < 0 {
+		return append(attrs[:len(attrs):len(attrs)], attr)
+	}
+	attrs = append([]lox.Attribute(nil), attrs...)
+	attrs[i] = attr
+	return attrs
+}
+
+// mapAttrs returns a copy of attrs with each of their types replaced by f's result.
+func mapAttrs(attrs []lox.Attribute, f func(lox.Type) lox.Type) []lox.Attribute {
+	if len(attrs) == 0 {
+		return nil
+	}
+	mapped := make([]lox.Attribute, len(attrs))
+	for i, attr := range attrs {
+		mapped[i] = attr.MapTypes(f)
+	}
+	return mapped
+}
+
+// ---- Options
+
+// Options restricts the values of a ref to types that unify with one of them, e.g., the
+// operand types of an overloaded operator.
+type Options []lox.Type
+
+func (o Options) MapTypes(f func(lox.Type) lox.Type) lox.Attribute {
+	options := make(Options, len(o))
+	for i, option := range o {
+		options[i] = f(option)
+	}
+	return options
+}
+
+func (o Options) String() string {
+	options := make([]string, len(o))
+	for i, option := range o {
+		options[i] = lox.PrintType(option)
+	}
+	return strings.Join(options, "|")
+}
+
+// Verify accepts a type that unifies with one of the options. If there is a single one, it's
+// also unified with t. Otherwise, t is an instance of either option.
+//
+// A ref keeps the options allowed by both refs, and is bound to the last one left.
+func (o Options) Verify(b Binder, x *lox.RefType, t lox.Type) error {
+	y, ok := t.(*lox.RefType)
+	if !ok {
+		var fits []lox.Type
+		for _, option := range o {
+			if b.Unifies(option, t) {
+				fits = append(fits, option)
+			}
+		}
+		if len(fits) == 0 {
+			return optionsError{t, o}
+		}
+		if len(fits) == 1 {
+			b.Unify(fits[0], t)
+		}
+		return nil
+	}
+	for _, option := range o {
+		if deref(option) == y {
+			// The ref is one of the options.
+			return nil
+		}
+	}
+	other, i := findAttr[Options](y.Attrs)
+	if i < 0 {
+		b.SetAttrs(y, putAttr(y.Attrs, i, o))
+		return nil
+	}
+	var options Options
+	for _, o1 := range o {
+		for _, o2 := range other {
+			if b.Unifies(o1, o2) {
+				options = append(options, o1)
+				break
+			}
+		}
+	}
+	if len(options) == 0 {
+		return optionsError{x, other}
+	}
+	b.SetAttrs(y, putAttr(y.Attrs, i, options))
+	if len(options) == 1 {
+		// The only option left is the ref's value.
+		b.Unify(y, options[0])
+	}
+	return nil
+}
+
+// ---- Members
+
+// Members restricts the values of a ref to instances or classes that have these members, with
+// types that unify with the given ones. Nil is accepted, as it matches with anything.
+type Members map[string]lox.Type
+
+// MapTypes calls f on the members in order, so that refs are found deterministically.
+func (m Members) MapTypes(f func(lox.Type) lox.Type) lox.Attribute {
+	members := make(Members, len(m))
+	for _, name := range m.names() {
+		members[name] = f(m[name])
+	}
+	return members
+}
+
+func (m Members) String() string {
+	var members []string
+	for _, name := range m.names() {
+		members = append(members, fmt.Sprintf(".%s: %s", name, lox.PrintType(m[name])))
+	}
+	return strings.Join(members, ", ")
+}
+
+// names returns the member names in order, so that they are unified deterministically.
+func (m Members) names() []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Verify unifies the members of a class or instance with the expected types. A ref keeps the
+// members required by both refs, unifying the ones they share.
+func (m Members) Verify(b Binder, x *lox.RefType, t lox.Type) error {
+	if y, ok := t.(*lox.RefType); ok {
+		other, i := findAttr[Members](y.Attrs)
+		members := make(Members, len(m)+len(other))
+		for name, member := range other {
+			members[name] = member
+		}
+		for _, name := range m.names() {
+			if member, ok := members[name]; ok {
+				b.Unify(m[name], member)
+				continue
+			}
+			members[name] = m[name]
+		}
+		b.SetAttrs(y, putAttr(y.Attrs, i, members))
+		return nil
+	}
+	if _, ok := t.(lox.NilType); ok {
+		return nil
+	}
+	class, isStatic, ok := classOf(t)
+	if !ok {
+		return typeError{x, t}
+	}
+	for _, name := range m.names() {
+		member, _, ok := lookupMember(class, isStatic, name)
+		if !ok {
+			return typeError{x, t}
+		}
+		b.Unify(m[name], member)
+	}
+	return nil
+}
@@ -2,20 +2,31 @@ package typing
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/brunokim/kilox"
 	"github.com/brunokim/kilox/errlist"
 )
 
-type typeScope map[string]lox.Type
+// scheme is the type of a declaration, where quantified refs are replaced by new refs at each
+// use, so that it may be used with different types.
+type scheme struct {
+	t          lox.Type
+	quantified map[*lox.RefType]bool
+}
+
+type typeScope map[string]scheme
 
 func makeBuiltinTypes() typeScope {
-	scope := make(typeScope)
+	scope := make(map[string]lox.Type)
 
 	var id int
-	newRef := func() *lox.RefType {
+	newRef := func(options ...lox.Type) *lox.RefType {
 		id--
-		return &lox.RefType{ID: id}
+		if len(options) == 0 {
+			return &lox.RefType{ID: id}
+		}
+		return &lox.RefType{ID: id, Attrs: []lox.Attribute{Options(options)}}
 	}
 	t := newRef()
 	t1 := newRef()
@@ -23,21 +34,12 @@ func makeBuiltinTypes() typeScope {
 
 	// Arithmetic operators
 	{
-		x := newRef()
-		_ = []Constraint{
-			Constraint1(x, num_),
-			Constraint1(x, str_),
-		}
+		x := newRef(num_, str_)
 		scope["+"] = func_(types(x, x), x)
 	}
-	{
-		x := newRef()
-		_ = []Constraint{
-			Constraint1(x, func_(types(num_, num_), num_)),
-			Constraint1(x, func_(types(num_), num_)),
-		}
-		scope["-"] = x
-	}
+	scope["-"] = newRef(
+		func_(types(num_, num_), num_),
+		func_(types(num_), num_))
 	scope["*"] = func_(types(num_, num_), num_)
 	scope["/"] = func_(types(num_, num_), num_)
 
@@ -51,36 +53,31 @@ func makeBuiltinTypes() typeScope {
 	scope["!"] = func_(types(t), bool_)
 
 	// Logic control
-	{
-		x := newRef()
-		_ = []Constraint{
-			Constraint1(x, t1),
-			Constraint1(x, t2),
-		}
-		scope["and"] = func_(types(t1, t2), x)
-	}
-	{
-		x := newRef()
-		_ = []Constraint{
-			Constraint1(x, t1),
-			Constraint1(x, t2),
-		}
-		scope["or"] = func_(types(t1, t2), x)
-	}
+	scope["and"] = func_(types(t1, t2), newRef(t1, t2))
+	scope["or"] = func_(types(t1, t2), newRef(t1, t2))
 
 	// Builtin
 	scope["clock"] = func_(types(), num_)
 	scope["type"] = func_(types(t), t1)
 	scope["random"] = func_(types(), num_)
 	scope["randomSeed"] = func_(types(num_), nil_)
+	scope["len"] = func_(types(t), num_)
+	scope["keys"] = func_(types(lox.MapType{Key: t1, Value: t2}), lox.ListType{Element: t1})
 
-	return scope
+	builtins := make(typeScope)
+	for name, t := range scope {
+		builtins[name] = generalize(t, nil)
+	}
+	return builtins
 }
 
 type Checker struct {
-	errors []typeError
+	errors []error
 	scopes []typeScope
 	types  map[lox.Expr]lox.Type
+	// Properties read before being assigned, that are reported if they are never assigned.
+	undefined map[property]lox.Token
+	classes   []*lox.ClassType
 
 	currType   lox.Type
 	returnType *lox.RefType
@@ -94,18 +91,44 @@ func NewChecker() *Checker {
 			makeBuiltinTypes(),
 			make(typeScope), // Top-level scope
 		},
-		types: make(map[lox.Expr]lox.Type),
+		types:     make(map[lox.Expr]lox.Type),
+		undefined: make(map[property]lox.Token),
 	}
 }
 
+// DefineNatives declares the types of natives available to the program, as returned
+// by (*lox.Interpreter).NativeTypes. Their refs are generalized, so each call may bind
+// them to different types.
+func (c *Checker) DefineNatives(natives map[string]lox.Type) {
+	builtins := c.scopes[0]
+	for name, t := range natives {
+		builtins[name] = generalize(t, nil)
+	}
+}
+
+// Check infers the types of stmts, returning the types of all expressions checked so far.
+// Declarations are kept between calls, so that a program may be checked incrementally.
 func (c *Checker) Check(stmts []lox.Stmt) (map[lox.Expr]lox.Type, error) {
+	c.errors = nil
 	c.checkStmts(stmts)
+	c.checkUndefined()
 	if len(c.errors) > 0 {
-		return nil, errlist.Of[typeError](c.errors)
+		return nil, errlist.Of[error](c.errors)
 	}
 	return c.types, nil
 }
 
+// CheckExpr infers the type of expr, with the declarations of previous calls to Check.
+func (c *Checker) CheckExpr(expr lox.Expr) (lox.Type, error) {
+	c.errors = nil
+	t := c.checkExpr(expr)
+	c.checkUndefined()
+	if len(c.errors) > 0 {
+		return nil, errlist.Of[error](c.errors)
+	}
+	return t, nil
+}
+
 func (c *Checker) newRefType() *lox.RefType {
 	c.refID++
 	return &lox.RefType{ID: c.refID}
@@ -126,25 +149,90 @@ func (c *Checker) endScope() {
 
 func (c *Checker) bind(name string, type_ lox.Type) {
 	scope := c.scopes[len(c.scopes)-1]
-	if prevType, ok := scope[name]; ok {
-		c.unify(prevType, type_)
+	if prev, ok := scope[name]; ok {
+		// Redeclarations are not within a single expression, so they are only located by literals.
+		c.unify(lox.Span{}, c.instantiate(prev), type_)
 	}
-	scope[name] = type_
+	scope[name] = scheme{t: type_}
 }
 
-func (c *Checker) unify(t1, t2 lox.Type) {
-	Unify(t1, t2)
+// unify unifies types of the expression within span, where mismatches are reported.
+func (c *Checker) unify(span lox.Span, t1, t2 lox.Type) {
+	if _, err := Unify(t1, t2); err != nil {
+		if mismatch, ok := err.(mismatchError); ok && span != (lox.Span{}) {
+			err = exprError{mismatch, span}
+		}
+		c.errors = append(c.errors, err)
+	}
 }
 
 func (c *Checker) getBinding(expr lox.Expr, name string) lox.Type {
 	for i := len(c.scopes) - 1; i >= 0; i-- {
 		scope := c.scopes[i]
-		if t, ok := scope[name]; ok {
+		if s, ok := scope[name]; ok {
+			t := c.instantiate(s)
 			c.types[expr] = t
 			return t
 		}
 	}
-	panic(fmt.Sprintf("compiler error: variable %q not found, shouldn't happen after resolver", name))
+	// Globals are only looked up at runtime, so they may be referenced before their declaration,
+	// or not be declared at all. They are bound to a new ref in the top-level scope, that is
+	// unified with their declaration, if any.
+	t := c.newRefType()
+	c.scopes[1][name] = scheme{t: t}
+	c.types[expr] = t
+	return t
+}
+
+// generalize returns a scheme for t, quantifying its unbound refs that are not in env.
+func generalize(t lox.Type, env map[*lox.RefType]bool) scheme {
+	quantified := make(map[*lox.RefType]bool)
+	for _, x := range unboundRefs(t) {
+		if !env[x] {
+			quantified[x] = true
+		}
+	}
+	return scheme{t, quantified}
+}
+
+// envRefs returns the unbound refs that appear in the types of variables in scope, that
+// can't be generalized since they may be bound by later statements.
+func (c *Checker) envRefs() map[*lox.RefType]bool {
+	refs := make(map[*lox.RefType]bool)
+	for _, scope := range c.scopes {
+		for _, s := range scope {
+			for _, x := range unboundRefs(s.t) {
+				if !s.quantified[x] {
+					refs[x] = true
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// instantiate returns the type of a scheme with new refs in place of the quantified ones.
+func (c *Checker) instantiate(s scheme) lox.Type {
+	if len(s.quantified) == 0 {
+		return s.t
+	}
+	table := make(map[*lox.RefType]*lox.RefType)
+	var transform transformRef
+	transform = func(x *lox.RefType, _ []Constraint) lox.Type {
+		if !s.quantified[x] {
+			return x
+		}
+		y, ok := table[x]
+		if !ok {
+			y = c.newRefType()
+			table[x] = y
+			y.Attrs = mapAttrs(x.Attrs, func(t lox.Type) lox.Type {
+				return mapUnboundRefs(t, transform)
+			})
+		}
+		return y
+	}
+	return mapUnboundRefs(s.t, transform)
 }
 
 // ----
@@ -189,13 +277,16 @@ func (c *Checker) checkFunctionType(name string, params []lox.Token, body []lox.
 	return t
 }
 
-func (c *Checker) checkCall(callee lox.Type, args ...lox.Type) lox.Type {
+func (c *Checker) checkCall(span lox.Span, callee lox.Type, args ...lox.Type) lox.Type {
+	if class, ok := deref(callee).(*lox.ClassType); ok {
+		return c.checkConstructor(span, class, args)
+	}
 	result := c.newRefType()
 	callType := lox.FunctionType{
 		Params: args,
 		Return: result,
 	}
-	c.unify(Copy(callee, c.newRefType), callType)
+	c.unify(span, callee, callType)
 	c.currType = result
 	return result
 }
@@ -204,6 +295,169 @@ func (c *Checker) constraintReturn(t lox.Type) {
 	c.returnType.Value = t
 }
 
+// initType returns the type of a declaration's initializer, or an unknown type if it has none.
+func (c *Checker) initType(init lox.Expr) lox.Type {
+	if init == nil {
+		return c.newRefType()
+	}
+	return c.checkExpr(init)
+}
+
+// ---- Classes
+
+// property identifies a member of a class, or a static member of its metaclass.
+type property struct {
+	class    *lox.ClassType
+	isStatic bool
+	name     string
+}
+
+// classOf returns the class of an instance, or of a class' static members.
+func classOf(t lox.Type) (class *lox.ClassType, isStatic bool, ok bool) {
+	switch t := deref(t).(type) {
+	case *lox.ClassType:
+		return t, true, true
+	case lox.InstanceType:
+		return t.Class, false, true
+	}
+	return nil, false, false
+}
+
+// lookupMember searches a member in a class and its superclasses, returning its type and the
+// class where it's declared. Instance fields shadow methods, as in the interpreter.
+func lookupMember(class *lox.ClassType, isStatic bool, name string) (lox.Type, *lox.ClassType, bool) {
+	for cl := class; cl != nil; cl = cl.Superclass {
+		if isStatic {
+			if t, ok := cl.Statics[name]; ok {
+				return t, cl, true
+			}
+			continue
+		}
+		if t, ok := cl.Fields[name]; ok {
+			return t, cl, true
+		}
+		if t, ok := cl.Methods[name]; ok {
+			return t, cl, true
+		}
+	}
+	return nil, nil, false
+}
+
+// getProperty returns the type of a property of an instance or class. Properties of values
+// of other types are unknown.
+func (c *Checker) getProperty(object lox.Type, name lox.Token) lox.Type {
+	class, isStatic, ok := classOf(object)
+	if !ok {
+		return c.newRefType()
+	}
+	if t, _, ok := lookupMember(class, isStatic, name.Lexeme); ok {
+		return t
+	}
+	// The property may be assigned later, e.g., in a method checked after this one.
+	t := c.newRefType()
+	if isStatic {
+		class.Statics[name.Lexeme] = t
+	} else {
+		class.Fields[name.Lexeme] = t
+	}
+	c.undefined[property{class, isStatic, name.Lexeme}] = name
+	return t
+}
+
+// setProperty unifies an assigned value with the type of a property, declaring it if needed.
+func (c *Checker) setProperty(span lox.Span, object lox.Type, name lox.Token, value lox.Type) {
+	class, isStatic, ok := classOf(object)
+	if !ok {
+		return
+	}
+	if t, owner, ok := lookupMember(class, isStatic, name.Lexeme); ok {
+		delete(c.undefined, property{owner, isStatic, name.Lexeme})
+		c.unify(span, t, value)
+		return
+	}
+	if isStatic {
+		class.Statics[name.Lexeme] = value
+	} else {
+		class.Fields[name.Lexeme] = value
+	}
+}
+
+// checkUndefined reports the properties that were read, but never assigned. A value typed
+// with a class may be an instance of a subclass, e.g., if created by 'this' in a static
+// method, so properties declared by subclasses are not reported.
+func (c *Checker) checkUndefined() {
+	var errs []propertyError
+	for p, name := range c.undefined {
+		if c.isDeclaredBySubclass(p) {
+			continue
+		}
+		var t lox.Type = lox.InstanceType{Class: p.class}
+		if p.isStatic {
+			t = p.class
+		}
+		errs = append(errs, propertyError{name, t})
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].name.Span.Start.Offset < errs[j].name.Span.Start.Offset
+	})
+	for _, err := range errs {
+		c.errors = append(c.errors, err)
+	}
+	c.undefined = make(map[property]lox.Token)
+}
+
+func (c *Checker) isDeclaredBySubclass(p property) bool {
+	for _, class := range c.classes {
+		if class == p.class || !isSubclass(class, p.class) {
+			continue
+		}
+		if _, cl, ok := lookupMember(class, p.isStatic, p.name); ok && cl != p.class {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConstructor checks a call to a class, that creates an instance with its 'init' method.
+func (c *Checker) checkConstructor(span lox.Span, class *lox.ClassType, args []lox.Type) lox.Type {
+	instance := lox.InstanceType{Class: class}
+	var params []lox.Type
+	for cl := class; cl != nil; cl = cl.Superclass {
+		if init, ok := cl.Methods["init"]; ok {
+			fn, ok := deref(init).(lox.FunctionType)
+			if !ok {
+				// The call is within a method checked before 'init', so the params are unknown.
+				c.currType = instance
+				return instance
+			}
+			params = fn.Params
+			break
+		}
+	}
+	c.checkCall(span, lox.FunctionType{Params: params, Return: instance}, args...)
+	c.currType = instance
+	return instance
+}
+
+// checkMethods checks the bodies of methods declared in members, within a scope where 'this'
+// and 'super' have the given types. The return type of 'init' is always 'this'.
+func (c *Checker) checkMethods(methods []lox.FunctionStmt, members map[string]lox.Type, this, super lox.Type) {
+	_, isInstance := this.(lox.InstanceType)
+	c.beginScope()
+	if super != nil {
+		c.bind("super", super)
+	}
+	c.bind("this", this)
+	for _, method := range methods {
+		t := c.checkFunctionType("", method.Params, method.Body).(lox.FunctionType)
+		if isInstance && method.Name.Lexeme == "init" {
+			t.Return = this
+		}
+		c.unify(method.Span, members[method.Name.Lexeme], t)
+	}
+	c.endScope()
+}
+
 // ----
 
 func (c *Checker) VisitExpressionStmt(stmt lox.ExpressionStmt) {
@@ -218,13 +472,7 @@ func (c *Checker) VisitPrintStmt(stmt lox.PrintStmt) {
 // TODO: handle case where an uninitialized variable is read/returned before first
 // assignment, in which case it should be nil.
 func (c *Checker) VisitVarStmt(stmt lox.VarStmt) {
-	var t lox.Type
-	if stmt.Init != nil {
-		t = c.checkExpr(stmt.Init)
-	} else {
-		t = c.newRefType()
-	}
-	c.bind(stmt.Name.Lexeme, t)
+	c.bind(stmt.Name.Lexeme, c.initType(stmt.Init))
 }
 
 func (c *Checker) VisitIfStmt(stmt lox.IfStmt) {
@@ -261,9 +509,22 @@ func (c *Checker) VisitContinueStmt(stmt lox.ContinueStmt) {
 	// Do nothing.
 }
 
+// Within its body, a function has a single type, so that recursive calls are checked against
+// the declaration. Afterwards, it's generalized, so that each call may use different types
+// for the refs that are not shared with other variables in scope.
 func (c *Checker) VisitFunctionStmt(stmt lox.FunctionStmt) {
 	name := stmt.Name.Lexeme
-	c.checkFunctionType(name, stmt.Params, stmt.Body)
+	scope := c.scopes[len(c.scopes)-1]
+	// A previous binding, e.g., from a reference before the declaration, is unified after the
+	// return type is known.
+	prev, hasPrev := scope[name]
+	delete(scope, name)
+	t := c.checkFunctionType(name, stmt.Params, stmt.Body)
+	if hasPrev {
+		c.unify(lox.Span{}, c.instantiate(prev), t)
+	}
+	delete(scope, name)
+	scope[name] = generalize(t, c.envRefs())
 }
 
 func (c *Checker) VisitReturnStmt(stmt lox.ReturnStmt) {
@@ -274,8 +535,69 @@ func (c *Checker) VisitReturnStmt(stmt lox.ReturnStmt) {
 	c.constraintReturn(c.checkExpr(stmt.Result))
 }
 
+// Each class declaration creates a new class type, that is the type of the class itself and
+// of its static members. Instances have a type that refers to the class, where their fields
+// and methods are declared.
 func (c *Checker) VisitClassStmt(stmt lox.ClassStmt) {
-	panic("lox.(*Checker).visitClassStmt is not implemented")
+	class := &lox.ClassType{
+		Name:    stmt.Name,
+		Fields:  make(map[string]lox.Type),
+		Methods: make(map[string]lox.Type),
+		Statics: make(map[string]lox.Type),
+	}
+	var super, superInstance lox.Type
+	if stmt.Superclass != nil {
+		super = c.checkExpr(stmt.Superclass)
+		superInstance = c.newRefType()
+		if superclass, ok := deref(super).(*lox.ClassType); ok {
+			class.Superclass = superclass
+			superInstance = lox.InstanceType{Class: superclass}
+		}
+	}
+	c.bind(stmt.Name.Lexeme, class)
+	c.classes = append(c.classes, class)
+	// Initializers are evaluated in the enclosing scope, when the class is declared.
+	for _, decl := range stmt.StaticVars {
+		class.Statics[decl.Name.Lexeme] = c.initType(decl.Init)
+	}
+	for _, decl := range stmt.Vars {
+		class.Fields[decl.Name.Lexeme] = c.initType(decl.Init)
+	}
+	// Methods are declared before checking their bodies, so that they may call each other.
+	for _, method := range stmt.StaticMethods {
+		class.Statics[method.Name.Lexeme] = c.newRefType()
+	}
+	for _, method := range stmt.Methods {
+		class.Methods[method.Name.Lexeme] = c.newRefType()
+	}
+	c.checkMethods(stmt.Methods, class.Methods, lox.InstanceType{Class: class}, superInstance)
+	c.checkMethods(stmt.StaticMethods, class.Statics, class, super)
+}
+
+func (c *Checker) VisitImportStmt(stmt lox.ImportStmt) {
+	// Modules are checked independently, so nothing is known about their members.
+	c.bind(stmt.Name.Lexeme, c.newRefType())
+}
+
+func (c *Checker) VisitThrowStmt(stmt lox.ThrowStmt) {
+	// Any value may be thrown.
+	c.checkExpr(stmt.Value)
+}
+
+func (c *Checker) VisitTryStmt(stmt lox.TryStmt) {
+	c.beginScope()
+	c.checkStmts(stmt.Body)
+	c.endScope()
+	if stmt.CatchName != nil {
+		// The caught value may have any type, since it may come from any throw statement.
+		c.beginScope()
+		c.bind(stmt.CatchName.Lexeme, c.newRefType())
+		c.checkStmts(stmt.Catch)
+		c.endScope()
+	}
+	c.beginScope()
+	c.checkStmts(stmt.Finally)
+	c.endScope()
 }
 
 // ----
@@ -284,7 +606,7 @@ func (c *Checker) VisitBinaryExpr(expr *lox.BinaryExpr) {
 	op := c.getBinding(expr, expr.Operator.Lexeme)
 	left := c.checkExpr(expr.Left)
 	right := c.checkExpr(expr.Right)
-	c.checkCall(op, left, right)
+	c.checkCall(expr.Span, op, left, right)
 }
 
 func (c *Checker) VisitGroupingExpr(expr *lox.GroupingExpr) {
@@ -311,7 +633,7 @@ func (c *Checker) VisitLiteralExpr(expr *lox.LiteralExpr) {
 func (c *Checker) VisitUnaryExpr(expr *lox.UnaryExpr) {
 	op := c.getBinding(expr, expr.Operator.Lexeme)
 	right := c.checkExpr(expr.Right)
-	c.checkCall(op, right)
+	c.checkCall(expr.Span, op, right)
 }
 
 func (c *Checker) VisitVariableExpr(expr *lox.VariableExpr) {
@@ -320,7 +642,7 @@ func (c *Checker) VisitVariableExpr(expr *lox.VariableExpr) {
 
 func (c *Checker) VisitAssignmentExpr(expr *lox.AssignmentExpr) {
 	t := c.checkExpr(expr.Value)
-	c.bind(expr.Name.Lexeme, t)
+	c.unify(expr.Span, c.getBinding(expr, expr.Name.Lexeme), t)
 	c.currType = t
 }
 
@@ -328,7 +650,7 @@ func (c *Checker) VisitLogicExpr(expr *lox.LogicExpr) {
 	op := c.getBinding(expr, expr.Operator.Lexeme)
 	left := c.checkExpr(expr.Left)
 	right := c.checkExpr(expr.Right)
-	c.checkCall(op, left, right)
+	c.checkCall(expr.Span, op, left, right)
 }
 
 func (c *Checker) VisitCallExpr(expr *lox.CallExpr) {
@@ -337,7 +659,7 @@ func (c *Checker) VisitCallExpr(expr *lox.CallExpr) {
 	for i, arg := range expr.Args {
 		args[i] = c.checkExpr(arg)
 	}
-	c.checkCall(t, args...)
+	c.checkCall(expr.Span, t, args...)
 }
 
 func (c *Checker) VisitFunctionExpr(expr *lox.FunctionExpr) {
@@ -345,13 +667,61 @@ func (c *Checker) VisitFunctionExpr(expr *lox.FunctionExpr) {
 }
 
 func (c *Checker) VisitGetExpr(expr *lox.GetExpr) {
-	panic("typing.(*Checker).visitGetExpr is not implemented")
+	c.currType = c.getProperty(c.checkExpr(expr.Object), expr.Name)
 }
 
 func (c *Checker) VisitSetExpr(expr *lox.SetExpr) {
-	panic("typing.(*Checker).visitSetExpr is not implemented")
+	object := c.checkExpr(expr.Object)
+	value := c.checkExpr(expr.Value)
+	c.setProperty(expr.Span, object, expr.Name, value)
+	c.currType = value
 }
 
 func (c *Checker) VisitThisExpr(expr *lox.ThisExpr) {
-	panic("typing.(*Checker).visitThisExpr is not implemented")
+	c.currType = c.getBinding(expr, "this")
+}
+
+func (c *Checker) VisitListExpr(expr *lox.ListExpr) {
+	elem := c.newRefType()
+	for _, e := range expr.Elements {
+		c.unify(lox.Span{Start: e.Pos(), End: e.End()}, elem, c.checkExpr(e))
+	}
+	c.currType = lox.ListType{Element: elem}
+}
+
+func (c *Checker) VisitMapExpr(expr *lox.MapExpr) {
+	key, value := c.newRefType(), c.newRefType()
+	for i, k := range expr.Keys {
+		c.unify(lox.Span{Start: k.Pos(), End: k.End()}, key, c.checkExpr(k))
+		c.unify(lox.Span{Start: expr.Values[i].Pos(), End: expr.Values[i].End()}, value, c.checkExpr(expr.Values[i]))
+	}
+	c.currType = lox.MapType{Key: key, Value: value}
+}
+
+// checkIndex unifies the object with a map, if it's already known to be one, or with a list
+// otherwise. Returns the type of the object's values.
+func (c *Checker) checkIndex(span lox.Span, object lox.Expr, index lox.Expr) lox.Type {
+	value := c.newRefType()
+	t := c.checkExpr(object)
+	if _, ok := deref(t).(lox.MapType); ok {
+		c.unify(span, t, lox.MapType{Key: c.checkExpr(index), Value: value})
+	} else {
+		c.unify(span, t, lox.ListType{Element: value})
+		c.unify(lox.Span{Start: index.Pos(), End: index.End()}, c.checkExpr(index), num_)
+	}
+	return value
+}
+
+func (c *Checker) VisitIndexExpr(expr *lox.IndexExpr) {
+	c.currType = c.checkIndex(expr.Span, expr.Object, expr.Index)
+}
+
+func (c *Checker) VisitSetIndexExpr(expr *lox.SetIndexExpr) {
+	value := c.checkExpr(expr.Value)
+	c.unify(expr.Span, c.checkIndex(expr.Span, expr.Object, expr.Index), value)
+	c.currType = value
+}
+
+func (c *Checker) VisitSuperExpr(expr *lox.SuperExpr) {
+	c.currType = c.getProperty(c.getBinding(expr, "super"), expr.Method)
 }
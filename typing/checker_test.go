@@ -47,6 +47,7 @@ func TestCheck(t *testing.T) {
 				"$.1.Condition.Left":                     num_,                                                 // line 2: a
 				"$.1.Body.Statements.0.Init":             func_(types_(bref_(num_), bref_(num_)), bref_(num_)), // line 3: a + 1
 				"$.1.Body.Statements.0.Init.Left":        num_,                                                 // line 3: a
+				"$.1.Body.Statements.1.Expression":       num_,                                                 // line 4: a = b
 				"$.1.Body.Statements.1.Expression.Value": bref_(num_),                                          // line 4: b
 			},
 		},
@@ -96,3 +97,295 @@ func TestCheck(t *testing.T) {
 		})
 	}
 }
+
+// Globals may be referenced before their declaration, or not be declared at all.
+func TestCheckUndeclaredGlobals(t *testing.T) {
+	stmts := parse(t, dedent.Dedent(`
+        fun f() { return g(); }
+        fun g() { return 1; }
+        print f();
+        print foo;`))
+	types, err := typing.NewChecker().Check(stmts)
+	if err != nil {
+		t.Fatalf("got err: %v", err)
+	}
+	want := map[string]string{
+		"$.2.Expression.Callee": "(Fun () Number)",
+		"$.3.Expression":        "_1",
+	}
+	for path, wantType := range want {
+		elem, err := valuepath.Walk(path, stmts)
+		if err != nil {
+			t.Fatalf("invalid path %q: %v", path, err)
+		}
+		if got := typing.FormatType(types[elem.(lox.Expr)]); got != wantType {
+			t.Errorf("%s: want %s, got %s", path, wantType, got)
+		}
+	}
+}
+
+func TestCheckClasses(t *testing.T) {
+	stmts := parse(t, dedent.Dedent(`
+        class Point {
+            var x = 0;
+            init(x, y) {
+                this.x = x;
+                this.y = y;
+            }
+            norm() {
+                return this.x * this.x + this.y * this.y;
+            }
+            class origin() {
+                return this(0, 0);
+            }
+        }
+        class Point3 < Point {
+            class var count = 0;
+            init(x, y, z) {
+                super.init(x, y);
+                this.z = z;
+                Point3.count = Point3.count + 1;
+            }
+        }
+        var p = Point(1, 2);`))
+	c := typing.NewChecker()
+	if _, err := c.Check(stmts); err != nil {
+		t.Fatalf("want nil, got err: %v", err)
+	}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"Point", "(Class Point)"},
+		{"p", "Point"},
+		{"p.x", "Number"},
+		{"p.norm", "(Fun () Number)"},
+		{"p.init(3, 4)", "Point"},
+		{"Point.origin()", "Point"},
+		{"Point3(1, 2, 3)", "Point3"},
+		{"Point3(1, 2, 3).norm()", "Number"},
+		{"Point3(1, 2, 3).z", "Number"},
+		{"Point3.count", "Number"},
+	}
+	for _, test := range tests {
+		expr := parseExpr(t, test.expr)
+		got, err := c.CheckExpr(expr)
+		if err != nil {
+			t.Errorf("%s: want nil, got err: %v", test.expr, err)
+			continue
+		}
+		if d := cmp.Diff(test.want, typing.FormatType(got)); d != "" {
+			t.Errorf("%s: (-want, +got)%s", test.expr, d)
+		}
+	}
+}
+
+func TestCheckUndefinedProperty(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{
+			"class A {} print A().x;",
+			"line 1 at 'x': undefined property 'x' in A",
+		},
+		{
+			"class A { m() { return this.x; } }",
+			"line 1 at 'x': undefined property 'x' in A",
+		},
+		{
+			"class A { class m() { return this.x; } }",
+			"line 1 at 'x': undefined property 'x' in (Class A)",
+		},
+		{
+			"class A { m() { return this.x; } } class B < A { init() { this.x = 1; } }",
+			"",
+		},
+		{
+			"class A { m() { return this.x; } n() { this.x = 1; } }",
+			"",
+		},
+	}
+	for _, test := range tests {
+		_, err := typing.NewChecker().Check(parse(t, test.text))
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if d := cmp.Diff(test.want, got); d != "" {
+			t.Errorf("%s: (-want, +got)%s", test.text, d)
+		}
+	}
+}
+
+func TestCheckPolymorphism(t *testing.T) {
+	stmts := parse(t, dedent.Dedent(`
+        fun id(x) {
+            return x;
+        }
+        fun const(x) {
+            fun inner(y_) {
+                return x;
+            }
+            return inner;
+        }
+        fun fact(n) {
+            if (n <= 1) {
+                return 1;
+            }
+            return n * fact(n - 1);
+        }
+        fun twice(f, x) {
+            return f(f(x));
+        }
+        fun apply(g) {
+            g(1);
+            return g;
+        }
+        fun makeCounter() {
+            var count = 0;
+            fun counter() {
+                count = count + 1;
+                return count;
+            }
+            return counter;
+        }
+        var a = id(1);
+        var b = id("a");`))
+	c := typing.NewChecker()
+	if _, err := c.Check(stmts); err != nil {
+		t.Fatalf("want nil, got err: %v", err)
+	}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"id", "(Fun (_1) _1)"},
+		{"a", "Number"},
+		{"b", "String"},
+		{"id(true)", "Bool"},
+		{"const", "(Fun (_1) (Fun (_2) _1))"},
+		{`const(1)("a")`, "Number"},
+		{"fact", "(Fun (Number) Number)"},
+		{"twice", "(Fun ((Fun (_1) _1) _1) _1)"},
+		{`twice(id, "a")`, "String"},
+		{"apply", "(Fun ((Fun (Number) _1)) (Fun (Number) _1))"},
+		{"makeCounter", "(Fun () (Fun () Number))"},
+	}
+	for _, test := range tests {
+		expr := parseExpr(t, test.expr)
+		got, err := c.CheckExpr(expr)
+		if err != nil {
+			t.Errorf("%s: want nil, got err: %v", test.expr, err)
+			continue
+		}
+		if d := cmp.Diff(test.want, typing.FormatType(got)); d != "" {
+			t.Errorf("%s: (-want, +got)%s", test.expr, d)
+		}
+	}
+}
+
+func TestCheckOverloads(t *testing.T) {
+	stmts := parse(t, dedent.Dedent(`
+        fun add(a, b) {
+            return a + b;
+        }
+        fun neg(a) {
+            return -a;
+        }
+        fun sub(a, b) {
+            return a - b;
+        }
+        fun either(a, b) {
+            return a or b;
+        }`))
+	c := typing.NewChecker()
+	if _, err := c.Check(stmts); err != nil {
+		t.Fatalf("want nil, got err: %v", err)
+	}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"add", "(Fun (_1{Number|String} _1{Number|String}) _1{Number|String})"},
+		{"add(1, 2)", "Number"},
+		{`add("a", "b")`, "String"},
+		{`"a" + "b"`, "String"},
+		{"neg", "(Fun (Number) Number)"},
+		{"sub", "(Fun (Number Number) Number)"},
+		{"-1", "Number"},
+		{"either", "(Fun (_1 _2) _3{_1|_2})"},
+		{`1 and "a"`, "_1{Number|String}"},
+	}
+	for _, test := range tests {
+		expr := parseExpr(t, test.expr)
+		got, err := c.CheckExpr(expr)
+		if err != nil {
+			t.Errorf("%s: want nil, got err: %v", test.expr, err)
+			continue
+		}
+		if d := cmp.Diff(test.want, typing.FormatType(got)); d != "" {
+			t.Errorf("%s: (-want, +got)%s", test.expr, d)
+		}
+	}
+}
+
+func TestCheckOverloadErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"true + 1", "line 1 at 'true': Bool doesn't match any of Number | String"},
+		{`1 + "a"`, "line 1 at '1': Number != String"},
+		{`-"a"`, `line 1 at '"a"': (Fun (String) _2) doesn't match any of (Fun (Number Number) Number) | (Fun (Number) Number)`},
+	}
+	for _, test := range tests {
+		c := typing.NewChecker()
+		_, err := c.CheckExpr(parseExpr(t, test.expr))
+		if err == nil {
+			t.Errorf("%s: want err, got nil", test.expr)
+			continue
+		}
+		if d := cmp.Diff(test.want, err.Error()); d != "" {
+			t.Errorf("%s: (-want, +got)%s", test.expr, d)
+		}
+	}
+}
+
+// Mismatches are located at literals within the checked expression, or at the expression.
+func TestCheckErrorLocation(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		pos  lox.Position
+	}{
+		{
+			"fun f(x) { return x + 1; }\nf(\"a\");",
+			`line 2 at '"a"': Number != String`,
+			lox.Position{Offset: 29, Line: 2, Column: 3},
+		},
+		{
+			"fun g() { return \"s\"; }\nfun h() { return 1; }\nvar l = [g(), h()];",
+			"line 3: String != Number",
+			lox.Position{Offset: 60, Line: 3, Column: 15},
+		},
+		{
+			"fun n() { return 1; }\nvar m = {\"a\": 1};\nm[n()] = 2;",
+			"line 3: String != Number",
+			lox.Position{Offset: 40, Line: 3, Column: 1},
+		},
+	}
+	for _, test := range tests {
+		_, err := typing.NewChecker().Check(parse(t, test.text))
+		if err == nil {
+			t.Errorf("%s: want err, got nil", test.text)
+			continue
+		}
+		if d := cmp.Diff(test.want, err.Error()); d != "" {
+			t.Errorf("%s: (-want, +got)%s", test.text, d)
+		}
+		if d := cmp.Diff(test.pos, lox.Diagnostics(err)[0].Span.Start); d != "" {
+			t.Errorf("%s: (-want, +got)%s", test.text, d)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package typing
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/brunokim/kilox"
 	"github.com/brunokim/kilox/errlist"
@@ -25,22 +26,91 @@ type CallGoal struct {
 	Type     lox.Type
 }
 
+// ChoiceGoal succeeds if any of its alternatives does, trying them in order. It's created
+// for statements that execute conditionally, like 'if' and loops.
+type ChoiceGoal struct {
+	Alternatives [][]Goal
+}
+
+// PropertyGoal unifies Type with the member Name of an instance or class. Properties of
+// values of unknown type are not checked.
+type PropertyGoal struct {
+	Object lox.Type
+	Name   string
+	Type   lox.Type
+}
+
 func (BindingGoal) isGoal()     {}
 func (UnificationGoal) isGoal() {}
 func (*CallGoal) isGoal()       {}
+func (*ChoiceGoal) isGoal()     {}
+func (PropertyGoal) isGoal()    {}
 
+// TypeClause states that a function has the type Head, if all goals in Body are satisfied.
+// Locals are the refs created for this clause, that are renamed each time it's called. Other
+// refs are shared with the enclosing scopes.
 type TypeClause struct {
-	ID   int
-	Name string
-	Head lox.FunctionType
-	Body []Goal
-}
+	ID     int
+	Name   string
+	Head   lox.FunctionType
+	Body   []Goal
+	Locals []*lox.RefType
+}
+
+// Clauses of builtin functions and operators. Overloads have the same ID, and are tried in
+// order.
+var builtinClauses = makeBuiltinClauses()
+
+func makeBuiltinClauses() []TypeClause {
+	var refID int
+	newRef := func() *lox.RefType {
+		refID--
+		return &lox.RefType{ID: refID}
+	}
+	t, t1, t2 := newRef(), newRef(), newRef()
+	list := lox.ListType{Element: t}
+	dict := lox.MapType{Key: t1, Value: t2}
+
+	var clauses []TypeClause
+	var clauseID int
+	facts := func(name string, heads ...lox.FunctionType) {
+		clauseID--
+		for _, head := range heads {
+			clauses = append(clauses, TypeClause{clauseID, name, head, nil, unboundRefs(head)})
+		}
+	}
 
-var builtinClauses = []TypeClause{
-	{-99, "+", func_(types(num_, num_), num_), nil},
-	{-98, "-", func_(types(num_, num_), num_), nil},
-	{-97, "*", func_(types(num_, num_), num_), nil},
-	{-96, "*", func_(types(num_, num_), num_), nil},
+	// Arithmetic operators
+	facts("+", func_(types(num_, num_), num_), func_(types(str_, str_), str_))
+	facts("-", func_(types(num_, num_), num_), func_(types(num_), num_))
+	facts("*", func_(types(num_, num_), num_))
+	facts("/", func_(types(num_, num_), num_))
+
+	// Logic operators
+	facts("<", func_(types(num_, num_), bool_))
+	facts("<=", func_(types(num_, num_), bool_))
+	facts(">", func_(types(num_, num_), bool_))
+	facts(">=", func_(types(num_, num_), bool_))
+	facts("==", func_(types(t1, t2), bool_))
+	facts("!=", func_(types(t1, t2), bool_))
+	facts("!", func_(types(t), bool_))
+
+	// Logic control, that returns either operand.
+	facts("and", func_(types(t1, t2), t1), func_(types(t1, t2), t2))
+	facts("or", func_(types(t1, t2), t1), func_(types(t1, t2), t2))
+
+	// Index access and assignment, named after their syntax.
+	facts("[]", func_(types(list, num_), t), func_(types(dict, t1), t2))
+	facts("[]=", func_(types(list, num_, t), t), func_(types(dict, t1, t2), t2))
+
+	// Builtin
+	facts("clock", func_(types(), num_))
+	facts("type", func_(types(t), t1))
+	facts("random", func_(types(), num_))
+	facts("randomSeed", func_(types(num_), nil_))
+	facts("len", func_(types(t), num_))
+	facts("keys", func_(types(dict), lox.ListType{Element: t1}))
+	return clauses
 }
 
 // ---- Error
@@ -62,39 +132,51 @@ const (
 	staticScope
 )
 
+// forward is a call to a name that was not declared yet, that is resolved by a later
+// function declaration.
+type forward struct {
+	name lox.Token
+	call *CallGoal
+}
+
 type scope struct {
 	m         *logicModel
 	enclosing *scope
 	clause    *TypeClause
+	path      *goalPath
 
 	refs      map[string]*lox.RefType
 	clauseIDs map[string]int
-	forwards  map[string][]*CallGoal
+	forwards  map[string][]forward
 
 	returnRef *lox.RefType
-	hasReturn bool
 	dynType   dynType
 }
 
 func builtinScope(m *logicModel) *scope {
 	s := newScope(m, dynamicScope)
 	for _, clause := range builtinClauses {
-		s.refs[clause.Name] = m.newRef()
-		s.refs[clause.Name].Value = clause.Head
 		s.clauseIDs[clause.Name] = clause.ID
 	}
 	return s
 }
 
+// newScope creates a scope within the current one, that appends goals to the same clause.
 func newScope(m *logicModel, dynType dynType) *scope {
-	return &scope{
+	s := &scope{
 		m:         m,
 		enclosing: m.scope,
 		refs:      make(map[string]*lox.RefType),
 		clauseIDs: make(map[string]int),
-		forwards:  make(map[string][]*CallGoal),
+		forwards:  make(map[string][]forward),
 		dynType:   dynType,
 	}
+	if m.scope != nil {
+		s.clause = m.scope.clause
+		s.path = m.scope.path
+		s.returnRef = m.scope.returnRef
+	}
+	return s
 }
 
 func (s *scope) search(name string) (*lox.RefType, bool) {
@@ -107,6 +189,16 @@ func (s *scope) search(name string) (*lox.RefType, bool) {
 	return nil, false
 }
 
+func (s *scope) searchClause(name string) (int, bool) {
+	for s != nil {
+		if id, ok := s.clauseIDs[name]; ok {
+			return id, true
+		}
+		s = s.enclosing
+	}
+	return 0, false
+}
+
 func (s *scope) ref(name string) *lox.RefType {
 	if name == "_" {
 		return s.m.newRef()
@@ -117,8 +209,40 @@ func (s *scope) ref(name string) *lox.RefType {
 	return s.refs[name]
 }
 
-func (s *scope) addForward(name string, call *CallGoal) {
-	s.forwards[name] = append(s.forwards[name], call)
+func (s *scope) addForward(name lox.Token, call *CallGoal) {
+	s.forwards[name.Lexeme] = append(s.forwards[name.Lexeme], forward{name, call})
+}
+
+// ---- Goal path
+
+// goalPath is where the goals of the current execution path are appended. A path may continue
+// in several goal lists, e.g., after an 'if' whose branches end differently. It ends at a
+// return, break or continue statement, and the goals of unreachable statements are dropped.
+type goalPath struct {
+	goals []*[]Goal
+	ended bool
+	// Goal lists where the path ended with break or continue, that continue after the loop.
+	breaks []*[]Goal
+}
+
+func newGoalPath(goals *[]Goal) *goalPath {
+	return &goalPath{goals: []*[]Goal{goals}}
+}
+
+func (p *goalPath) append(goal Goal) {
+	if p.ended {
+		return
+	}
+	for _, goals := range p.goals {
+		*goals = append(*goals, goal)
+	}
+}
+
+func (p *goalPath) breakLoop() {
+	if !p.ended {
+		p.breaks = append(p.breaks, p.goals...)
+		p.ended = true
+	}
 }
 
 // ---- Logic model
@@ -127,6 +251,10 @@ type logicModel struct {
 	clauses []TypeClause
 	errors  []logicError
 	scope   *scope
+	// Clause with the goals of top-level statements.
+	script *TypeClause
+	// Classes whose methods are being visited, innermost last.
+	classes []*lox.ClassType
 
 	currType lox.Type
 
@@ -135,18 +263,28 @@ type logicModel struct {
 }
 
 func newLogicModel() *logicModel {
-	m := &logicModel{}
+	m := &logicModel{script: &TypeClause{Name: "<script>", Head: func_(nil, nil_)}}
 	m.scope = builtinScope(m)
 	m.scope = newScope(m, dynamicScope) // global scope
+	m.scope.clause = m.script
+	m.scope.path = newGoalPath(&m.script.Body)
 	return m
 }
 
+// BuildClauses returns the clauses of the functions declared in a program, followed by a
+// clause for its top-level statements, if they have any goal.
 func BuildClauses(stmts []lox.Stmt) ([]TypeClause, error) {
 	m := newLogicModel()
 	m.visitStmts(stmts)
+	m.checkForwards()
 	if len(m.errors) > 0 {
 		return nil, errlist.Of[logicError](m.errors)
 	}
+	if len(m.script.Body) > 0 {
+		m.clauseID++
+		m.script.ID = m.clauseID
+		m.clauses = append(m.clauses, *m.script)
+	}
 	return m.clauses, nil
 }
 
@@ -154,6 +292,24 @@ func (m *logicModel) addError(err logicError) {
 	m.errors = append(m.errors, err)
 }
 
+// checkForwards reports the names that were never declared as functions.
+func (m *logicModel) checkForwards() {
+	var fwds []forward
+	for _, fs := range m.scope.forwards {
+		fwds = append(fwds, fs[0])
+	}
+	sort.Slice(fwds, func(i, j int) bool {
+		return fwds[i].name.Span.Start.Offset < fwds[j].name.Span.Start.Offset
+	})
+	for _, fwd := range fwds {
+		msg := fmt.Sprintf("undefined name '%s'", fwd.name.Lexeme)
+		if _, ok := m.scope.refs[fwd.name.Lexeme]; ok {
+			msg = fmt.Sprintf("'%s' is used before its declaration, and is not a function", fwd.name.Lexeme)
+		}
+		m.addError(logicError{fmt.Sprintf("line %d: %s", fwd.name.Line, msg)})
+	}
+}
+
 // ----
 
 func (m *logicModel) visitStmts(stmts []lox.Stmt) {
@@ -173,7 +329,11 @@ func (m *logicModel) visitExpr(expr lox.Expr) lox.Type {
 
 func (m *logicModel) newRef() *lox.RefType {
 	m.refID++
-	return &lox.RefType{ID: m.refID}
+	x := &lox.RefType{ID: m.refID}
+	if cl := m.scope.clause; cl != nil {
+		cl.Locals = append(cl.Locals, x)
+	}
+	return x
 }
 
 func (m *logicModel) localRef(name lox.Token) *lox.RefType {
@@ -185,34 +345,91 @@ func (m *logicModel) search(name lox.Token) (*lox.RefType, bool) {
 }
 
 func (m *logicModel) appendBinding(x *lox.RefType, t lox.Type) {
-	cl := m.scope.clause
-	cl.Body = append(cl.Body, BindingGoal{x, t})
+	m.scope.path.append(BindingGoal{x, t})
 }
 
 func (m *logicModel) appendUnification(t1, t2 lox.Type) {
-	cl := m.scope.clause
-	cl.Body = append(cl.Body, UnificationGoal{t1, t2})
+	m.scope.path.append(UnificationGoal{t1, t2})
 }
 
 func (m *logicModel) appendCall(clauseID int, t lox.Type) *CallGoal {
-	cl := m.scope.clause
 	call := &CallGoal{clauseID, t}
-	cl.Body = append(cl.Body, call)
+	m.scope.path.append(call)
 	return call
 }
 
+func (m *logicModel) appendProperty(object lox.Type, name lox.Token, t lox.Type) {
+	m.scope.path.append(PropertyGoal{object, name.Lexeme, t})
+}
+
+func (m *logicModel) beginScope() {
+	m.scope = newScope(m, staticScope)
+}
+
+// endScope restores the enclosing scope, where forward references that were not resolved
+// may still be declared.
+func (m *logicModel) endScope() {
+	for name, fwds := range m.scope.forwards {
+		m.scope.enclosing.forwards[name] = append(m.scope.enclosing.forwards[name], fwds...)
+	}
+	m.scope = m.scope.enclosing
+}
+
+// choice appends a ChoiceGoal with an alternative for each branch, that visits statements
+// within it. Following goals are appended after the choice, if all branches complete
+// normally, or else to each goal list where a branch continues.
+func (m *logicModel) choice(branches ...func()) {
+	path := m.scope.path
+	goals, ended := path.goals, path.ended
+	choice := &ChoiceGoal{Alternatives: make([][]Goal, len(branches))}
+	path.append(choice)
+	var open []*[]Goal
+	isJoined := true
+	for i, branch := range branches {
+		alternative := &choice.Alternatives[i]
+		path.goals, path.ended = []*[]Goal{alternative}, ended
+		branch()
+		if path.ended {
+			isJoined = false
+			continue
+		}
+		if len(path.goals) != 1 || path.goals[0] != alternative {
+			isJoined = false
+		}
+		open = append(open, path.goals...)
+	}
+	switch {
+	case len(open) == 0:
+		path.goals, path.ended = goals, true
+	case isJoined:
+		path.goals, path.ended = goals, false
+	default:
+		path.goals, path.ended = open, false
+	}
+}
+
 // ----
 
+// nameType returns the type of a name. Builtins and names that are not declared yet are
+// resolved by calls, so that each use may have a different type.
 func (m *logicModel) nameType(name lox.Token) lox.Type {
-	x, ok := m.search(name)
-	if !ok {
-		x = m.newRef()
-		call := m.appendCall(0, x)
-		m.scope.addForward(name.Lexeme, call)
+	if x, ok := m.search(name); ok {
+		return x
 	}
+	x := m.newRef()
+	if id, ok := m.scope.searchClause(name.Lexeme); ok {
+		m.appendCall(id, x)
+		return x
+	}
+	call := m.appendCall(0, x)
+	m.scope.addForward(name, call)
 	return x
 }
 
+func (m *logicModel) builtinType(name string) lox.Type {
+	return m.nameType(lox.Token{TokenType: lox.Identifier, Lexeme: name})
+}
+
 func (m *logicModel) callType(calleeType lox.Type, args ...lox.Expr) lox.Type {
 	argTypes := make([]lox.Type, len(args))
 	for i, arg := range args {
@@ -224,6 +441,63 @@ func (m *logicModel) callType(calleeType lox.Type, args ...lox.Expr) lox.Type {
 	return returnType
 }
 
+// constructorType returns the type of a call to a class, that creates an instance with its
+// 'init' method.
+func (m *logicModel) constructorType(class *lox.ClassType, args ...lox.Expr) lox.Type {
+	instance := lox.InstanceType{Class: class}
+	var init lox.Type = lox.FunctionType{Return: instance}
+	if t, _, ok := lookupMember(class, false, "init"); ok {
+		init = t
+	}
+	m.callType(init, args...)
+	return instance
+}
+
+// function creates a clause for a function, that returns nil if its body completes normally.
+// Initializers have a known return type ret, and don't return nil.
+func (m *logicModel) function(clauseID int, name string, params []lox.Token, body []lox.Stmt, ret lox.Type) lox.FunctionType {
+	cl := TypeClause{ID: clauseID, Name: name}
+	m.beginScope()
+	m.scope.clause = &cl
+	m.scope.path = newGoalPath(&cl.Body)
+
+	// Create clause with function type as head.
+	paramTypes := make([]lox.Type, len(params))
+	for i, param := range params {
+		paramTypes[i] = m.localRef(param)
+	}
+	m.scope.returnRef = m.newRef()
+	m.scope.returnRef.Value = ret
+	cl.Head = lox.FunctionType{Params: paramTypes, Return: m.scope.returnRef}
+	m.visitStmts(body)
+	if !m.scope.path.ended && ret == nil {
+		m.appendBinding(m.scope.returnRef, nil_)
+	}
+	m.clauses = append(m.clauses, cl)
+	m.endScope()
+	return cl.Head
+}
+
+// methods creates a clause for each method, within a scope where 'this' and 'super' have
+// the given types. Their types are declared in members.
+func (m *logicModel) methods(class *lox.ClassType, methods []lox.FunctionStmt, members map[string]lox.Type, this, super lox.Type) {
+	m.beginScope()
+	if super != nil {
+		m.scope.ref("super").Value = super
+	}
+	m.scope.ref("this").Value = this
+	for _, method := range methods {
+		var ret lox.Type
+		if _, ok := this.(lox.InstanceType); ok && method.Name.Lexeme == "init" {
+			ret = this
+		}
+		m.clauseID++
+		name := class.Name.Lexeme + "." + method.Name.Lexeme
+		members[method.Name.Lexeme] = m.function(m.clauseID, name, method.Params, method.Body, ret)
+	}
+	m.endScope()
+}
+
 // ---- Expr
 
 func (m *logicModel) VisitBinaryExpr(e *lox.BinaryExpr) {
@@ -263,7 +537,10 @@ func (m *logicModel) VisitVariableExpr(e *lox.VariableExpr) {
 
 func (m *logicModel) VisitAssignmentExpr(e *lox.AssignmentExpr) {
 	t := m.visitExpr(e.Value)
-	x := m.localRef(e.Name)
+	x, ok := m.search(e.Name)
+	if !ok {
+		x = m.localRef(e.Name)
+	}
 	m.appendBinding(x, t)
 	m.currType = t
 }
@@ -275,23 +552,77 @@ func (m *logicModel) VisitLogicExpr(e *lox.LogicExpr) {
 
 func (m *logicModel) VisitCallExpr(e *lox.CallExpr) {
 	calleeType := m.visitExpr(e.Callee)
+	if class, ok := deref(calleeType).(*lox.ClassType); ok {
+		m.currType = m.constructorType(class, e.Args...)
+		return
+	}
 	m.currType = m.callType(calleeType, e.Args...)
 }
 
 func (m *logicModel) VisitFunctionExpr(e *lox.FunctionExpr) {
-	panic("typing.(*logicModel).VisitFunctionExpr is not implemented")
+	m.clauseID++
+	m.currType = m.function(m.clauseID, "<fn>", e.Params, e.Body, nil)
 }
 
 func (m *logicModel) VisitGetExpr(e *lox.GetExpr) {
-	panic("typing.(*logicModel).VisitGetExpr is not implemented")
+	object := m.visitExpr(e.Object)
+	t := m.newRef()
+	m.appendProperty(object, e.Name, t)
+	m.currType = t
 }
 
+// Assigning to a property of 'this' declares it as a field, if it's not a member yet.
 func (m *logicModel) VisitSetExpr(e *lox.SetExpr) {
-	panic("typing.(*logicModel).VisitSetExpr is not implemented")
+	t := m.visitExpr(e.Value)
+	object := m.visitExpr(e.Object)
+	if _, ok := e.Object.(*lox.ThisExpr); ok {
+		class, isStatic, ok := classOf(object)
+		if _, _, isMember := lookupMember(class, isStatic, e.Name.Lexeme); ok && !isMember {
+			if isStatic {
+				class.Statics[e.Name.Lexeme] = m.newRef()
+			} else {
+				class.Fields[e.Name.Lexeme] = m.newRef()
+			}
+		}
+	}
+	m.appendProperty(object, e.Name, t)
+	m.currType = t
 }
 
 func (m *logicModel) VisitThisExpr(e *lox.ThisExpr) {
-	panic("typing.(*logicModel).VisitThisExpr is not implemented")
+	m.currType = m.nameType(e.Keyword)
+}
+
+func (m *logicModel) VisitListExpr(e *lox.ListExpr) {
+	elem := m.newRef()
+	for _, element := range e.Elements {
+		m.appendUnification(elem, m.visitExpr(element))
+	}
+	m.currType = lox.ListType{Element: elem}
+}
+
+func (m *logicModel) VisitIndexExpr(e *lox.IndexExpr) {
+	m.currType = m.callType(m.builtinType("[]"), e.Object, e.Index)
+}
+
+func (m *logicModel) VisitSetIndexExpr(e *lox.SetIndexExpr) {
+	m.currType = m.callType(m.builtinType("[]="), e.Object, e.Index, e.Value)
+}
+
+func (m *logicModel) VisitMapExpr(e *lox.MapExpr) {
+	key, value := m.newRef(), m.newRef()
+	for i, k := range e.Keys {
+		m.appendUnification(key, m.visitExpr(k))
+		m.appendUnification(value, m.visitExpr(e.Values[i]))
+	}
+	m.currType = lox.MapType{Key: key, Value: value}
+}
+
+func (m *logicModel) VisitSuperExpr(e *lox.SuperExpr) {
+	super := m.nameType(e.Keyword)
+	t := m.newRef()
+	m.appendProperty(super, e.Method, t)
+	m.currType = t
 }
 
 // ---- Stmt
@@ -311,24 +642,58 @@ func (m *logicModel) VisitVarStmt(s lox.VarStmt) {
 	}
 }
 
+// Each branch of an 'if' is an alternative, so that they may return different types.
 func (m *logicModel) VisitIfStmt(s lox.IfStmt) {
-	panic("typing.(*logicModel).VisitIfStmt is not implemented")
+	m.visitExpr(s.Condition)
+	m.choice(
+		func() { m.visitStmt(s.Then) },
+		func() {
+			if s.Else != nil {
+				m.visitStmt(s.Else)
+			}
+		})
 }
 
 func (m *logicModel) VisitBlockStmt(s lox.BlockStmt) {
-	panic("typing.(*logicModel).VisitBlockStmt is not implemented")
+	m.block(s.Statements)
+}
+
+func (m *logicModel) block(stmts []lox.Stmt) {
+	m.beginScope()
+	m.visitStmts(stmts)
+	m.endScope()
 }
 
+// A loop either executes its body, or skips it. Statements after a 'break' or 'continue' are
+// unreachable, but the ones after the loop are not.
 func (m *logicModel) VisitLoopStmt(s lox.LoopStmt) {
-	panic("typing.(*logicModel).VisitLoopStmt is not implemented")
+	m.visitExpr(s.Condition)
+	path := m.scope.path
+	breaks := path.breaks
+	m.choice(
+		func() {
+			path.breaks = nil
+			m.visitStmt(s.Body)
+			if !path.ended {
+				path.breaks = append(path.breaks, path.goals...)
+			}
+			if len(path.breaks) > 0 {
+				path.goals, path.ended = path.breaks, false
+			}
+			if s.OnLoop != nil {
+				m.visitExpr(s.OnLoop)
+			}
+		},
+		func() {})
+	path.breaks = breaks
 }
 
 func (m *logicModel) VisitBreakStmt(s lox.BreakStmt) {
-	panic("typing.(*logicModel).VisitBreakStmt is not implemented")
+	m.scope.path.breakLoop()
 }
 
 func (m *logicModel) VisitContinueStmt(s lox.ContinueStmt) {
-	panic("typing.(*logicModel).VisitContinueStmt is not implemented")
+	m.scope.path.breakLoop()
 }
 
 func (m *logicModel) VisitFunctionStmt(s lox.FunctionStmt) {
@@ -337,51 +702,91 @@ func (m *logicModel) VisitFunctionStmt(s lox.FunctionStmt) {
 	m.scope.clauseIDs[s.Name.Lexeme] = m.clauseID
 	funRef := m.localRef(s.Name)
 
-	if goals, ok := m.scope.forwards[s.Name.Lexeme]; ok && m.scope.dynType == dynamicScope {
+	if fwds, ok := m.scope.forwards[s.Name.Lexeme]; ok && m.scope.dynType == dynamicScope {
 		// This statement defines a name forward-referenced before. Mutate those call goals to point
 		// to this newly created clause.
-		for _, goal := range goals {
-			goal.ClauseID = m.clauseID
+		for _, fwd := range fwds {
+			fwd.call.ClauseID = m.clauseID
 		}
 		delete(m.scope.forwards, s.Name.Lexeme)
 	}
+	funRef.Value = m.function(m.clauseID, s.Name.Lexeme, s.Params, s.Body, nil)
+}
 
-	// Push new scope.
-	m.scope = newScope(m, staticScope)
+func (m *logicModel) VisitReturnStmt(s lox.ReturnStmt) {
+	var t lox.Type = nil_
+	if s.Result != nil {
+		t = m.visitExpr(s.Result)
+	}
+	m.appendBinding(m.scope.returnRef, t)
+	m.scope.path.ended = true
+}
 
-	// Create clause with function type as head.
-	params := make([]lox.Type, len(s.Params))
-	for i, param := range s.Params {
-		params[i] = m.localRef(param)
+// Classes are types whose members are declared while building their clauses, and are
+// accessed with property goals when solving them.
+func (m *logicModel) VisitClassStmt(s lox.ClassStmt) {
+	class := &lox.ClassType{
+		Name:    s.Name,
+		Fields:  make(map[string]lox.Type),
+		Methods: make(map[string]lox.Type),
+		Statics: make(map[string]lox.Type),
 	}
-	m.scope.returnRef = m.newRef()
-	funType := lox.FunctionType{params, m.scope.returnRef}
-	funRef.Value = funType
-	cl := TypeClause{
-		ID:   m.clauseID,
-		Name: s.Name.Lexeme,
-		Head: funType,
+	var super, superInstance lox.Type
+	if s.Superclass != nil {
+		super = m.visitExpr(s.Superclass)
+		superInstance = m.newRef()
+		if superclass, ok := deref(super).(*lox.ClassType); ok {
+			class.Superclass = superclass
+			superInstance = lox.InstanceType{Class: superclass}
+		}
 	}
-	m.scope.clause = &cl
-	m.visitStmts(s.Body)
-	if !m.scope.hasReturn {
-		m.appendBinding(m.scope.returnRef, nil_)
+	m.localRef(s.Name).Value = class
+	// Initializers are evaluated in the enclosing scope, when the class is declared.
+	for _, decl := range s.StaticVars {
+		class.Statics[decl.Name.Lexeme] = m.initType(decl.Init)
 	}
-	m.clauses = append(m.clauses, cl)
-
-	// Append forwards to enclosing scope and restore it.
-	for name, goals := range m.scope.forwards {
-		m.scope.enclosing.forwards[name] = append(m.scope.enclosing.forwards[name], goals...)
+	for _, decl := range s.Vars {
+		class.Fields[decl.Name.Lexeme] = m.initType(decl.Init)
 	}
-	m.scope = m.scope.enclosing
+	m.methods(class, s.Methods, class.Methods, lox.InstanceType{Class: class}, superInstance)
+	m.methods(class, s.StaticMethods, class.Statics, class, super)
 }
 
-func (m *logicModel) VisitReturnStmt(s lox.ReturnStmt) {
-	t := m.visitExpr(s.Result)
-	m.appendBinding(m.scope.returnRef, t)
-	m.scope.hasReturn = true
-}
-
-func (m *logicModel) VisitClassStmt(s lox.ClassStmt) {
-	panic("typing.(*logicModel).VisitClassStmt is not implemented")
+// initType returns the type of a declaration's initializer, or an unknown type if it has none.
+func (m *logicModel) initType(init lox.Expr) lox.Type {
+	if init == nil {
+		return m.newRef()
+	}
+	return m.visitExpr(init)
+}
+
+// Modules are built independently, so nothing is known about their members.
+func (m *logicModel) VisitImportStmt(s lox.ImportStmt) {
+	m.localRef(s.Name)
+}
+
+// Any value may be thrown.
+func (m *logicModel) VisitThrowStmt(s lox.ThrowStmt) {
+	m.visitExpr(s.Value)
+	m.scope.path.ended = true
+}
+
+// The catch block may run after any statement of the try block, so the path only ends if
+// both of them end, or if the finally block does.
+func (m *logicModel) VisitTryStmt(s lox.TryStmt) {
+	path := m.scope.path
+	m.block(s.Body)
+	ended := path.ended
+	if s.CatchName != nil {
+		path.ended = false
+		// The caught value may have any type, since it may come from any throw statement.
+		m.beginScope()
+		m.localRef(*s.CatchName)
+		m.visitStmts(s.Catch)
+		m.endScope()
+		ended = ended && path.ended
+	}
+	path.ended = false
+	m.block(s.Finally)
+	path.ended = path.ended || ended
 }
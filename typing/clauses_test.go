@@ -3,12 +3,16 @@ package typing_test
 import (
 	"testing"
 
+	"github.com/brunokim/kilox"
 	"github.com/brunokim/kilox/typing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/lithammer/dedent"
 )
 
+var ignoreLocals = cmpopts.IgnoreFields(typing.TypeClause{}, "Locals")
+
 func TestBuildClauses(t *testing.T) {
 	tests := []struct {
 		text string
@@ -142,11 +146,10 @@ func TestBuildClauses(t *testing.T) {
 		},
 		{
 			`fun foo() { return "a" + "b"; }`,
-			clauses_(clause_(1, "foo", func_(types_(), refi_(6)),
-				unify_(
-					brefi_(1, func_(types_(num_, num_), num_)),
-					func_(types_(str_, str_), refi_(7))),
-				binding_(refi_(6), refi_(7)))),
+			clauses_(clause_(1, "foo", func_(types_(), refi_(2)),
+				call_(-1, refi_(3)),
+				unify_(refi_(3), func_(types_(str_, str_), refi_(4))),
+				binding_(refi_(2), refi_(4)))),
 		},
 		{
 			dedent.Dedent(`
@@ -219,6 +222,46 @@ func TestBuildClauses(t *testing.T) {
 					binding_(refi_(16), nil_)),
 			),
 		},
+		{
+			dedent.Dedent(`
+            fun f(x) {
+                if (x) return 1;
+                return "a";
+            }`),
+			clauses_(clause_(1, "f", func_(types_(refi_(2)), refi_(3)),
+				choice_(
+					goals_(binding_(refi_(3), num_)),
+					goals_(binding_(refi_(3), str_))))),
+		},
+		{
+			dedent.Dedent(`
+            fun f(x) {
+                while (x) {
+                    if (x) break;
+                    x = 1;
+                }
+            }`),
+			clauses_(clause_(1, "f", func_(types_(refi_(2)), refi_(3)),
+				choice_(
+					goals_(choice_(
+						goals_(binding_(refi_(3), nil_)),
+						goals_(binding_(refi_(2), num_), binding_(refi_(3), nil_)))),
+					goals_(binding_(refi_(3), nil_))))),
+		},
+		{
+			"fun f() { return [1][0]; }",
+			clauses_(clause_(1, "f", func_(types_(), refi_(2)),
+				call_(-14, refi_(3)),
+				unify_(refi_(4), num_),
+				unify_(refi_(3), func_(types_(lox.ListType{Element: refi_(4)}, num_), refi_(5))),
+				binding_(refi_(2), refi_(5)))),
+		},
+		{
+			"print 1 + 2;",
+			clauses_(clause_(1, "<script>", func_(types_(), nil_),
+				call_(-1, refi_(1)),
+				unify_(refi_(1), func_(types_(num_, num_), refi_(2))))),
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.text, func(t *testing.T) {
@@ -228,9 +271,95 @@ func TestBuildClauses(t *testing.T) {
 				t.Errorf("got err: %v", err)
 				return
 			}
-			if diff := cmp.Diff(test.want, clauses, ignoreTypeFields); diff != "" {
+			// Refs are compared by order of appearance, since their IDs depend on how many
+			// were created before.
+			want, got := renumberRefs(test.want), renumberRefs(clauses)
+			if diff := cmp.Diff(want, got, ignoreTypeFields, ignoreLocals); diff != "" {
 				t.Errorf("(-want,+got):\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestBuildClausesErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"fun f() { return g(); }", "line 1: undefined name 'g'"},
+		{"fun f() { return a; }\nvar a = 1;", "line 1: 'a' is used before its declaration, and is not a function"},
+	}
+	for _, test := range tests {
+		_, err := typing.BuildClauses(parse(t, test.text))
+		if err == nil {
+			t.Errorf("%q: want err, got nil", test.text)
+			continue
+		}
+		if diff := cmp.Diff(test.want, err.Error()); diff != "" {
+			t.Errorf("%q: (-want,+got):\n%s", test.text, diff)
+		}
+	}
+}
+
+// renumberRefs replaces the IDs of refs in clauses, in place, by their order of appearance.
+// Refs with the same ID get the same number.
+func renumberRefs(clauses []typing.TypeClause) []typing.TypeClause {
+	ids := make(map[int]int)
+	seen := make(map[*lox.RefType]bool)
+	var visitType func(t lox.Type)
+	visitType = func(t lox.Type) {
+		switch t := t.(type) {
+		case *lox.RefType:
+			if seen[t] {
+				return
+			}
+			seen[t] = true
+			id, ok := ids[t.ID]
+			if !ok {
+				id = len(ids) + 1
+				ids[t.ID] = id
+			}
+			t.ID = id
+			if t.Value != nil {
+				visitType(t.Value)
+			}
+		case lox.FunctionType:
+			for _, param := range t.Params {
+				visitType(param)
+			}
+			visitType(t.Return)
+		case lox.ListType:
+			visitType(t.Element)
+		case lox.MapType:
+			visitType(t.Key)
+			visitType(t.Value)
+		}
+	}
+	var visitGoals func(goals []typing.Goal)
+	visitGoals = func(goals []typing.Goal) {
+		for _, goal := range goals {
+			switch g := goal.(type) {
+			case typing.BindingGoal:
+				visitType(g.Ref)
+				visitType(g.Type)
+			case typing.UnificationGoal:
+				visitType(g.T1)
+				visitType(g.T2)
+			case *typing.CallGoal:
+				visitType(g.Type)
+			case *typing.ChoiceGoal:
+				for _, alternative := range g.Alternatives {
+					visitGoals(alternative)
+				}
+			case typing.PropertyGoal:
+				visitType(g.Object)
+				visitType(g.Type)
+			}
+		}
+	}
+	for _, cl := range clauses {
+		visitType(cl.Head)
+		visitGoals(cl.Body)
+	}
+	return clauses
+}
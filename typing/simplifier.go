@@ -34,6 +34,20 @@ func (s *simplifier) VisitFunctionType(t lox.FunctionType) {
 	}
 }
 
+func (s *simplifier) VisitListType(t lox.ListType) {
+	s.currType = lox.ListType{Element: s.simplify(t.Element)}
+}
+
+func (s *simplifier) VisitMapType(t lox.MapType) {
+	s.currType = lox.MapType{
+		Key:   s.simplify(t.Key),
+		Value: s.simplify(t.Value),
+	}
+}
+
+func (s *simplifier) VisitClassType(t *lox.ClassType)      { s.currType = t }
+func (s *simplifier) VisitInstanceType(t lox.InstanceType) { s.currType = t }
+
 func (s *simplifier) VisitRefType(t *lox.RefType) {
 	s.currType = t
 }
@@ -0,0 +1,439 @@
+package typing
+
+import (
+	"fmt"
+
+	"github.com/brunokim/kilox"
+)
+
+// Solve returns the solutions of goals within a program, where each call goal is resolved by
+// the clauses with its ID, or by a builtin clause. Each solution binds the unbound refs of
+// goals, in order of appearance, to their resolved types.
+//
+// Choices and calls to overloaded clauses are backtracked, trying each alternative in order.
+// Solutions that are an instance of another one, like those repeated in several branches,
+// are dropped. Refs are restored to their original state after solving.
+//
+// The body of a clause is only proved when it's called, and functions referenced after
+// their declaration are not called, but share the type of the clause head. To solve a whole
+// program, include the bodies of all clauses in goals.
+func Solve(clauses []TypeClause, goals ...Goal) []Constraint {
+	s := newSolver(clauses)
+	refs := goalRefs(goals)
+	var solutions []Constraint
+	s.goals = pushGoals(goals, nil)
+	for {
+		if s.goals == nil {
+			solutions = append(solutions, solution(refs))
+			if !s.backtrack() {
+				break
+			}
+			continue
+		}
+		goal := s.goals.goal
+		s.goals = s.goals.next
+		if !s.step(goal) && !s.backtrack() {
+			break
+		}
+	}
+	s.undo(0)
+	return mostGeneral(refs, solutions)
+}
+
+// goalList is a linked list of goals to prove, that shares its tail with the choice points.
+type goalList struct {
+	goal Goal
+	next *goalList
+}
+
+func pushGoals(goals []Goal, next *goalList) *goalList {
+	for i := len(goals) - 1; i >= 0; i-- {
+		next = &goalList{goals[i], next}
+	}
+	return next
+}
+
+// choicePoint stores the alternatives that were not tried yet, and the state to restore
+// before trying them.
+type choicePoint struct {
+	alternatives [][]Goal
+	goals        *goalList
+	trailSize    int
+}
+
+type solver struct {
+	// Clauses by ID, copied before solving, so that calls copy them as they were built.
+	clauses map[int][]TypeClause
+	goals   *goalList
+	choices []choicePoint
+	// Changes to refs, that are undone on backtracking.
+	trail []trailEntry
+	refID int
+}
+
+func newSolver(clauses []TypeClause) *solver {
+	s := &solver{clauses: make(map[int][]TypeClause)}
+	for _, cl := range clauses {
+		for _, x := range cl.Locals {
+			if x.ID > s.refID {
+				s.refID = x.ID
+			}
+		}
+	}
+	for _, cls := range [][]TypeClause{builtinClauses, clauses} {
+		for _, cl := range cls {
+			s.clauses[cl.ID] = append(s.clauses[cl.ID], s.rename(cl))
+		}
+	}
+	return s
+}
+
+func (s *solver) newRef() *lox.RefType {
+	s.refID++
+	return &lox.RefType{ID: s.refID}
+}
+
+func (s *solver) step(goal Goal) bool {
+	switch g := goal.(type) {
+	case BindingGoal:
+		return s.unify(g.Ref, g.Type)
+	case UnificationGoal:
+		return s.unify(g.T1, g.T2)
+	case *CallGoal:
+		var alternatives [][]Goal
+		for _, cl := range s.clauses[g.ClauseID] {
+			cl = s.rename(cl)
+			alternatives = append(alternatives, append([]Goal{UnificationGoal{g.Type, cl.Head}}, cl.Body...))
+		}
+		return s.choose(alternatives)
+	case *ChoiceGoal:
+		return s.choose(g.Alternatives)
+	case PropertyGoal:
+		return s.property(g)
+	}
+	panic(fmt.Sprintf("unhandled goal type %T", goal))
+}
+
+func (s *solver) unify(t1, t2 lox.Type) bool {
+	u := newUnifier(t1, t2)
+	err := u.run()
+	s.trail = append(s.trail, u.trail...)
+	return err == nil
+}
+
+// property unifies a goal's type with the member of an instance or class.
+func (s *solver) property(g PropertyGoal) bool {
+	if x, ok := deref(g.Object).(*lox.RefType); ok && x.Value == nil {
+		return true
+	}
+	class, isStatic, ok := classOf(g.Object)
+	if !ok {
+		return false
+	}
+	t, _, ok := lookupMember(class, isStatic, g.Name)
+	if !ok {
+		return false
+	}
+	return s.unify(t, g.Type)
+}
+
+// choose tries the first alternative, creating a choice point for the others.
+func (s *solver) choose(alternatives [][]Goal) bool {
+	if len(alternatives) == 0 {
+		return false
+	}
+	if len(alternatives) > 1 {
+		s.choices = append(s.choices, choicePoint{alternatives[1:], s.goals, len(s.trail)})
+	}
+	s.goals = pushGoals(alternatives[0], s.goals)
+	return true
+}
+
+// backtrack restores the state of the last choice point, and tries its next alternative.
+// Returns false if there are no choice points left.
+func (s *solver) backtrack() bool {
+	n := len(s.choices)
+	if n == 0 {
+		return false
+	}
+	cp := &s.choices[n-1]
+	s.undo(cp.trailSize)
+	s.goals = pushGoals(cp.alternatives[0], cp.goals)
+	cp.alternatives = cp.alternatives[1:]
+	if len(cp.alternatives) == 0 {
+		s.choices = s.choices[:n-1]
+	}
+	return true
+}
+
+func (s *solver) undo(size int) {
+	undoTrail(s.trail[size:])
+	s.trail = s.trail[:size]
+}
+
+// ---- Renaming
+
+// rename copies a clause, replacing its local refs with new ones.
+func (s *solver) rename(cl TypeClause) TypeClause {
+	r := renamer{
+		locals: make(map[*lox.RefType]bool),
+		table:  make(map[*lox.RefType]*lox.RefType),
+		newRef: s.newRef,
+	}
+	for _, x := range cl.Locals {
+		r.locals[x] = true
+	}
+	head := r.rename(cl.Head).(lox.FunctionType)
+	body := r.renameGoals(cl.Body)
+	locals := make([]*lox.RefType, len(cl.Locals))
+	for i, x := range cl.Locals {
+		locals[i] = r.rename(x).(*lox.RefType)
+	}
+	return TypeClause{cl.ID, cl.Name, head, body, locals}
+}
+
+type renamer struct {
+	locals map[*lox.RefType]bool
+	table  map[*lox.RefType]*lox.RefType
+	newRef func() *lox.RefType
+}
+
+func (r renamer) rename(t lox.Type) lox.Type {
+	switch t := t.(type) {
+	case *lox.RefType:
+		if !r.locals[t] {
+			return t
+		}
+		if y, ok := r.table[t]; ok {
+			return y
+		}
+		y := r.newRef()
+		r.table[t] = y
+		if t.Value != nil {
+			y.Value = r.rename(t.Value)
+		}
+		y.Attrs = mapAttrs(t.Attrs, r.rename)
+		return y
+	case lox.FunctionType:
+		params := make([]lox.Type, len(t.Params))
+		for i, param := range t.Params {
+			params[i] = r.rename(param)
+		}
+		return lox.FunctionType{Params: params, Return: r.rename(t.Return)}
+	case lox.ListType:
+		return lox.ListType{Element: r.rename(t.Element)}
+	case lox.MapType:
+		return lox.MapType{Key: r.rename(t.Key), Value: r.rename(t.Value)}
+	}
+	// Classes are nominal, so their members are never copied.
+	return t
+}
+
+func (r renamer) renameGoals(goals []Goal) []Goal {
+	renamed := make([]Goal, len(goals))
+	for i, goal := range goals {
+		switch g := goal.(type) {
+		case BindingGoal:
+			renamed[i] = BindingGoal{r.rename(g.Ref).(*lox.RefType), r.rename(g.Type)}
+		case UnificationGoal:
+			renamed[i] = UnificationGoal{r.rename(g.T1), r.rename(g.T2)}
+		case *CallGoal:
+			renamed[i] = &CallGoal{g.ClauseID, r.rename(g.Type)}
+		case *ChoiceGoal:
+			alternatives := make([][]Goal, len(g.Alternatives))
+			for j, alternative := range g.Alternatives {
+				alternatives[j] = r.renameGoals(alternative)
+			}
+			renamed[i] = &ChoiceGoal{alternatives}
+		case PropertyGoal:
+			renamed[i] = PropertyGoal{r.rename(g.Object), g.Name, r.rename(g.Type)}
+		default:
+			panic(fmt.Sprintf("unhandled goal type %T", goal))
+		}
+	}
+	return renamed
+}
+
+// ---- Solutions
+
+// goalRefs returns the unbound refs within goals, in order of appearance.
+func goalRefs(goals []Goal) []*lox.RefType {
+	var refs []*lox.RefType
+	seen := make(map[*lox.RefType]bool)
+	add := func(ts ...lox.Type) {
+		for _, t := range ts {
+			for _, x := range unboundRefs(t) {
+				if !seen[x] {
+					seen[x] = true
+					refs = append(refs, x)
+				}
+			}
+		}
+	}
+	var visit func(goals []Goal)
+	visit = func(goals []Goal) {
+		for _, goal := range goals {
+			switch g := goal.(type) {
+			case BindingGoal:
+				add(g.Ref, g.Type)
+			case UnificationGoal:
+				add(g.T1, g.T2)
+			case *CallGoal:
+				add(g.Type)
+			case *ChoiceGoal:
+				for _, alternative := range g.Alternatives {
+					visit(alternative)
+				}
+			case PropertyGoal:
+				add(g.Object, g.Type)
+			}
+		}
+	}
+	visit(goals)
+	return refs
+}
+
+// solution returns the values of the bound refs. Values are copied, since refs are unbound
+// on backtracking, and the unbound refs within them are copied with the same ID.
+func solution(refs []*lox.RefType) Constraint {
+	c := NewConstraint()
+	table := make(map[*lox.RefType]*lox.RefType)
+	path := make(map[*lox.RefType]bool)
+	var resolve func(t lox.Type) lox.Type
+	resolve = func(t lox.Type) lox.Type {
+		switch t := t.(type) {
+		case *lox.RefType:
+			if t.Value == nil {
+				y, ok := table[t]
+				if !ok {
+					y = &lox.RefType{ID: t.ID}
+					table[t] = y
+					y.Attrs = mapAttrs(t.Attrs, resolve)
+				}
+				return y
+			}
+			if path[t] {
+				// Stop at recursive types.
+				return t
+			}
+			path[t] = true
+			defer delete(path, t)
+			return resolve(t.Value)
+		case lox.FunctionType:
+			params := make([]lox.Type, len(t.Params))
+			for i, param := range t.Params {
+				params[i] = resolve(param)
+			}
+			return lox.FunctionType{Params: params, Return: resolve(t.Return)}
+		case lox.ListType:
+			return lox.ListType{Element: resolve(t.Element)}
+		case lox.MapType:
+			return lox.MapType{Key: resolve(t.Key), Value: resolve(t.Value)}
+		}
+		return t
+	}
+	for _, x := range refs {
+		if x.Value != nil {
+			c.Put(x, resolve(x.Value))
+		}
+	}
+	return c
+}
+
+// mostGeneral returns the solutions that are not an instance of another one, keeping the
+// first of the solutions that are equal up to the renaming of their refs.
+func mostGeneral(refs []*lox.RefType, solutions []Constraint) []Constraint {
+	tuples := make([]lox.Type, len(solutions))
+	for i, c := range solutions {
+		// Refs without a value are unbound, and match any type.
+		values := make([]lox.Type, len(refs))
+		for j, x := range refs {
+			t, ok := c.Get(x)
+			if !ok {
+				t = &lox.RefType{ID: x.ID}
+			}
+			values[j] = t
+		}
+		tuples[i] = lox.FunctionType{Params: values, Return: lox.NilType{}}
+	}
+	var general []Constraint
+	for i, t1 := range tuples {
+		subsumed := false
+		for j, t2 := range tuples {
+			if i == j || !isInstance(t1, t2) {
+				continue
+			}
+			if j < i || !isInstance(t2, t1) {
+				subsumed = true
+				break
+			}
+		}
+		if !subsumed {
+			general = append(general, solutions[i])
+		}
+	}
+	return general
+}
+
+// isInstance returns whether t is an instance of general, that is, whether it's obtained by
+// replacing unbound refs in general. Refs with attributes only match themselves.
+func isInstance(t, general lox.Type) bool {
+	return matchType(general, t, make(map[*lox.RefType]lox.Type))
+}
+
+// matchType is like unification, but only binds the refs of general, in table.
+func matchType(general, t lox.Type, table map[*lox.RefType]lox.Type) bool {
+	switch g := general.(type) {
+	case *lox.RefType:
+		if g.Value != nil || len(g.Attrs) > 0 {
+			return g == t
+		}
+		if value, ok := table[g]; ok {
+			return equalTypes(value, t)
+		}
+		table[g] = t
+		return true
+	case lox.FunctionType:
+		f, ok := t.(lox.FunctionType)
+		if !ok || len(g.Params) != len(f.Params) {
+			return false
+		}
+		for i := range g.Params {
+			if !matchType(g.Params[i], f.Params[i], table) {
+				return false
+			}
+		}
+		return matchType(g.Return, f.Return, table)
+	case lox.ListType:
+		l, ok := t.(lox.ListType)
+		return ok && matchType(g.Element, l.Element, table)
+	case lox.MapType:
+		m, ok := t.(lox.MapType)
+		return ok && matchType(g.Key, m.Key, table) && matchType(g.Value, m.Value, table)
+	}
+	return general == t
+}
+
+// equalTypes returns whether t1 and t2 have the same structure, with the same refs.
+func equalTypes(t1, t2 lox.Type) bool {
+	switch t1 := t1.(type) {
+	case lox.FunctionType:
+		t2, ok := t2.(lox.FunctionType)
+		if !ok || len(t1.Params) != len(t2.Params) {
+			return false
+		}
+		for i := range t1.Params {
+			if !equalTypes(t1.Params[i], t2.Params[i]) {
+				return false
+			}
+		}
+		return equalTypes(t1.Return, t2.Return)
+	case lox.ListType:
+		t2, ok := t2.(lox.ListType)
+		return ok && equalTypes(t1.Element, t2.Element)
+	case lox.MapType:
+		t2, ok := t2.(lox.MapType)
+		return ok && equalTypes(t1.Key, t2.Key) && equalTypes(t1.Value, t2.Value)
+	}
+	return t1 == t2
+}
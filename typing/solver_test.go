@@ -0,0 +1,213 @@
+package typing_test
+
+import (
+	"testing"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/typing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/lithammer/dedent"
+)
+
+// solveFunction returns the types of a function in each solution of a call to its clause.
+func solveFunction(t *testing.T, clauses []typing.TypeClause, name string) []string {
+	for _, cl := range clauses {
+		if cl.Name != name {
+			continue
+		}
+		x := &lox.RefType{ID: -1000}
+		var got []string
+		for _, solution := range typing.Solve(clauses, call_(cl.ID, x)) {
+			t, _ := solution.Get(x)
+			got = append(got, typing.FormatType(t))
+		}
+		return got
+	}
+	t.Fatalf("clause %q not found", name)
+	return nil
+}
+
+func TestSolve(t *testing.T) {
+	tests := []struct {
+		text string
+		name string
+		want []string
+	}{
+		{
+			"fun add(a, b) { return a + b; }",
+			"add",
+			[]string{"(Fun (Number Number) Number)", "(Fun (String String) String)"},
+		},
+		{
+			"fun neg(a) { return -a; }",
+			"neg",
+			[]string{"(Fun (Number) Number)"},
+		},
+		{
+			`fun f() { return "a" - 1; }`,
+			"f",
+			nil,
+		},
+		{
+			dedent.Dedent(`
+            fun oneof(x, y, z) {
+                var r = random();
+                if (r < 1/3) return x;
+                if (r < 2/3) return y;
+                return z;
+            }`),
+			"oneof",
+			[]string{"(Fun (_1 _2 _3) _1)", "(Fun (_1 _2 _3) _2)", "(Fun (_1 _2 _3) _3)"},
+		},
+		{
+			dedent.Dedent(`
+            fun add_or_sub(ch, x, y) {
+                var fn;
+                if (ch == "+")
+                    fn = add;
+                else
+                    fn = sub;
+                return fn(x, y);
+            }
+            fun add(a, b) { return a + b; }
+            fun sub(a, b) { return a - b; }`),
+			"add_or_sub",
+			[]string{
+				"(Fun (_1 Number Number) Number)",
+				"(Fun (_1 String String) String)",
+			},
+		},
+		{
+			"fun first(l) { return l[0] + 1; }",
+			"first",
+			[]string{"(Fun ((List Number)) Number)", "(Fun ((Map Number Number)) Number)"},
+		},
+		{
+			dedent.Dedent(`
+            fun f(c) {
+                while (c) {
+                    if (c) break;
+                    return 1;
+                }
+                return "a";
+            }`),
+			"f",
+			[]string{"(Fun (_1) String)", "(Fun (_1) Number)"},
+		},
+		{
+			"fun f(a) { return a or 1; }",
+			"f",
+			[]string{"(Fun (_1) _1)", "(Fun (_1) Number)"},
+		},
+		{
+			dedent.Dedent(`
+            fun f() { return g(); }
+            fun g() { return id(1); }
+            fun id(x) { return x; }`),
+			"f",
+			[]string{"(Fun () Number)"},
+		},
+		{
+			// Recursive calls share the head, so the result of a function that only returns
+			// by calling itself is unbound, and more general than the one of its base case.
+			"fun f(n) { if (n < 1) return 0; return f(n - 1); }",
+			"f",
+			[]string{"(Fun (Number) _1)"},
+		},
+		{
+			dedent.Dedent(`
+            fun even(n) {
+                if (n == 0) return true;
+                return odd(n - 1);
+            }
+            fun odd(n) {
+                if (n == 0) return false;
+                return even(n - 1);
+            }`),
+			"even",
+			[]string{"(Fun (_1) Bool)", "(Fun (Number) _1)"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			clauses, err := typing.BuildClauses(parse(t, test.text))
+			if err != nil {
+				t.Fatalf("got err: %v", err)
+			}
+			got := solveFunction(t, clauses, test.name)
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("(-want,+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// Functions referenced after their declaration share their head, so their bodies are only
+// solved if they are part of the goals.
+func TestSolveProgram(t *testing.T) {
+	clauses, err := typing.BuildClauses(parse(t, dedent.Dedent(`
+        class Counter {
+            init() {
+                this.count = 0;
+            }
+            inc() {
+                this.count = this.count + 1;
+                return this.count;
+            }
+        }
+        fun makeCounter() {
+            var count = 0;
+            fun counter() {
+                count = count + 1;
+                return count;
+            }
+            return counter;
+        }
+        var a = Counter().inc();
+        var b = makeCounter()();`)))
+	if err != nil {
+		t.Fatalf("got err: %v", err)
+	}
+	var goals []typing.Goal
+	heads := make(map[string]*lox.RefType)
+	for _, cl := range clauses {
+		goals = append(goals, cl.Body...)
+		heads[cl.Name] = ref_()
+		goals = append(goals, unify_(heads[cl.Name], cl.Head))
+	}
+	solutions := typing.Solve(clauses, goals...)
+	if len(solutions) != 1 {
+		t.Fatalf("want 1 solution, got %d", len(solutions))
+	}
+	want := map[string]string{
+		"Counter.init": "(Fun () Counter)",
+		"Counter.inc":  "(Fun () Number)",
+		"counter":      "(Fun () Number)",
+		"makeCounter":  "(Fun () (Fun () Number))",
+		"<script>":     "(Fun () Nil)",
+	}
+	got := make(map[string]string)
+	for name, x := range heads {
+		t, _ := solutions[0].Get(x)
+		got[name] = typing.FormatType(t)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("(-want,+got):\n%s", diff)
+	}
+}
+
+func TestSolveRestoresRefs(t *testing.T) {
+	clauses, err := typing.BuildClauses(parse(t, "fun id(x) { return x; }"))
+	if err != nil {
+		t.Fatalf("got err: %v", err)
+	}
+	head := clauses[0].Head
+	solutions := typing.Solve(clauses, clauses[0].Body...)
+	if len(solutions) != 1 {
+		t.Fatalf("want 1 solution, got %d", len(solutions))
+	}
+	if got := typing.FormatType(head); got != "(Fun (_1) _2)" {
+		t.Errorf("want unbound head after solving, got %s", got)
+	}
+}
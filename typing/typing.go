@@ -2,6 +2,7 @@ package typing
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/brunokim/kilox"
 	"github.com/brunokim/kilox/ordered"
@@ -54,6 +55,16 @@ func deref(t lox.Type) lox.Type {
 	}
 }
 
+// isSubclass returns whether class is equal to or inherits from super.
+func isSubclass(class, super *lox.ClassType) bool {
+	for ; class != nil; class = class.Superclass {
+		if class == super {
+			return true
+		}
+	}
+	return false
+}
+
 // ----
 
 type typeError struct {
@@ -61,9 +72,225 @@ type typeError struct {
 }
 
 func (err typeError) Error() string {
+	if token, ok := err.token(); ok {
+		return fmt.Sprintf("line %d at '%s': %s", token.Line, token.Lexeme, err.message())
+	}
+	return err.message()
+}
+
+func (err typeError) message() string {
 	return fmt.Sprintf("%v != %v", err.t1, err.t2)
 }
 
+// Span returns the location of the first mismatched type that originated from a literal,
+// or an empty span if neither did.
+func (err typeError) Span() lox.Span {
+	token, _ := err.token()
+	return token.Span
+}
+
+func (err typeError) Diagnostic() lox.Diagnostic {
+	return lox.Diagnostic{
+		Severity: lox.SeverityError,
+		Phase:    lox.TypePhase,
+		Code:     "type-mismatch",
+		Message:  err.message(),
+		Span:     err.Span(),
+	}
+}
+
+func (err typeError) token() (lox.Token, bool) {
+	for _, t := range err.types() {
+		if tokens := literalTokens(t); len(tokens) > 0 {
+			return tokens[0], true
+		}
+	}
+	return lox.Token{}, false
+}
+
+func (err typeError) types() []lox.Type {
+	return []lox.Type{err.t1, err.t2}
+}
+
+// typeToken returns the token of a primitive type, if it was inferred from a literal.
+func typeToken(t lox.Type) (lox.Token, bool) {
+	var token lox.Token
+	switch t := deref(t).(type) {
+	case lox.NilType:
+		token = t.Token
+	case lox.BoolType:
+		token = t.Token
+	case lox.NumberType:
+		token = t.Token
+	case lox.StringType:
+		token = t.Token
+	}
+	return token, token.Lexeme != ""
+}
+
+// optionsError is reported when a type doesn't unify with any of the options of a ref.
+type optionsError struct {
+	t       lox.Type
+	options []lox.Type
+}
+
+func (err optionsError) Error() string {
+	if token, ok := err.token(); ok {
+		return fmt.Sprintf("line %d at '%s': %s", token.Line, token.Lexeme, err.message())
+	}
+	return err.message()
+}
+
+func (err optionsError) message() string {
+	options := make([]string, len(err.options))
+	for i, option := range err.options {
+		options[i] = lox.PrintType(option)
+	}
+	return fmt.Sprintf("%v doesn't match any of %s", lox.PrintType(err.t), strings.Join(options, " | "))
+}
+
+func (err optionsError) Span() lox.Span {
+	token, _ := err.token()
+	return token.Span
+}
+
+func (err optionsError) Diagnostic() lox.Diagnostic {
+	return lox.Diagnostic{
+		Severity: lox.SeverityError,
+		Phase:    lox.TypePhase,
+		Code:     "no-matching-option",
+		Message:  err.message(),
+		Span:     err.Span(),
+	}
+}
+
+// token returns the first token of a literal within the mismatched type, e.g., an argument
+// of an operator call.
+func (err optionsError) token() (lox.Token, bool) {
+	if tokens := literalTokens(err.t); len(tokens) > 0 {
+		return tokens[0], true
+	}
+	return lox.Token{}, false
+}
+
+func (err optionsError) types() []lox.Type {
+	return []lox.Type{err.t}
+}
+
+// literalTokens returns the tokens of the types within t inferred from literals, in order
+// of appearance.
+func literalTokens(t lox.Type) []lox.Token {
+	var tokens []lox.Token
+	seen := make(map[*lox.RefType]bool)
+	var search func(t lox.Type)
+	search = func(t lox.Type) {
+		if x, ok := t.(*lox.RefType); ok {
+			if seen[x] || x.Value == nil {
+				// Stop at unbound refs and recursive types.
+				return
+			}
+			seen[x] = true
+			search(x.Value)
+			return
+		}
+		if token, ok := typeToken(t); ok {
+			tokens = append(tokens, token)
+			return
+		}
+		switch t := t.(type) {
+		case lox.FunctionType:
+			for _, param := range t.Params {
+				search(param)
+			}
+			search(t.Return)
+		case lox.ListType:
+			search(t.Element)
+		case lox.MapType:
+			search(t.Key)
+			search(t.Value)
+		}
+	}
+	search(t)
+	return tokens
+}
+
+// mismatchError is an error from unification, that is located at a literal within the
+// mismatched types.
+type mismatchError interface {
+	error
+	Diagnostic() lox.Diagnostic
+	message() string
+	types() []lox.Type
+}
+
+// exprError is a mismatch found while checking an expression. It's located at a literal
+// within the mismatched types, if there's one within the expression, or at the expression
+// otherwise, since literals elsewhere may be far from the mistake, e.g., in a function body.
+type exprError struct {
+	mismatchError
+	span lox.Span
+}
+
+func (err exprError) Error() string {
+	if token, ok := err.token(); ok {
+		return fmt.Sprintf("line %d at '%s': %s", token.Line, token.Lexeme, err.message())
+	}
+	return fmt.Sprintf("line %d: %s", err.span.Start.Line, err.message())
+}
+
+func (err exprError) Span() lox.Span {
+	if token, ok := err.token(); ok {
+		return token.Span
+	}
+	return err.span
+}
+
+func (err exprError) Diagnostic() lox.Diagnostic {
+	d := err.mismatchError.Diagnostic()
+	d.Span = err.Span()
+	return d
+}
+
+func (err exprError) token() (lox.Token, bool) {
+	for _, t := range err.types() {
+		for _, token := range literalTokens(t) {
+			if err.span.Contains(token.Span.Start.Offset) {
+				return token, true
+			}
+		}
+	}
+	return lox.Token{}, false
+}
+
+// propertyError is reported for a property that is read from an instance or class, but is
+// never declared or assigned.
+type propertyError struct {
+	name  lox.Token
+	class lox.Type
+}
+
+func (err propertyError) Error() string {
+	return fmt.Sprintf("line %d at '%s': %s", err.name.Line, err.name.Lexeme, err.message())
+}
+
+func (err propertyError) message() string {
+	return fmt.Sprintf("undefined property '%s' in %v", err.name.Lexeme, lox.PrintType(err.class))
+}
+
+func (err propertyError) Span() lox.Span {
+	return err.name.Span
+}
+
+func (err propertyError) Diagnostic() lox.Diagnostic {
+	return lox.Diagnostic{
+		Severity: lox.SeverityError,
+		Phase:    lox.TypePhase,
+		Code:     "undefined-property",
+		Message:  err.message(),
+		Span:     err.name.Span,
+	}
+}
+
 // ----
 
 type transformRef func(x *lox.RefType, cnstrs []Constraint) lox.Type
@@ -77,19 +304,83 @@ func Ground(t lox.Type) (lox.Type, bool) {
 	return t, isGround
 }
 
+// unboundRefs returns the unbound refs within t and their attributes, in order of appearance.
+func unboundRefs(t lox.Type) []*lox.RefType {
+	var refs []*lox.RefType
+	seen := make(map[*lox.RefType]bool)
+	var transform transformRef
+	transform = func(x *lox.RefType, _ []Constraint) lox.Type {
+		if !seen[x] {
+			seen[x] = true
+			refs = append(refs, x)
+			mapAttrs(x.Attrs, func(t lox.Type) lox.Type {
+				return mapUnboundRefs(t, transform)
+			})
+		}
+		return x
+	}
+	mapUnboundRefs(t, transform)
+	return refs
+}
+
 func Copy(t lox.Type, newRef func() *lox.RefType) lox.Type {
 	table := make(map[*lox.RefType]*lox.RefType)
-	transform := func(x *lox.RefType, constraints []Constraint) lox.Type {
+	var transform transformRef
+	transform = func(x *lox.RefType, constraints []Constraint) lox.Type {
 		y, ok := table[x]
 		if !ok {
 			y = newRef()
 			table[x] = y
+			y.Attrs = mapAttrs(x.Attrs, func(t lox.Type) lox.Type {
+				return mapUnboundRefs(t, transform)
+			})
 		}
 		return y
 	}
 	return mapUnboundRefs(t, transform)
 }
 
+// FormatType prints t for users, replacing bound refs with their values and renumbering
+// unbound refs in order of appearance.
+func FormatType(t lox.Type) string {
+	names := make(map[*lox.RefType]*lox.RefType)
+	path := make(map[*lox.RefType]bool)
+	var resolve func(t lox.Type) lox.Type
+	resolve = func(t lox.Type) lox.Type {
+		switch t := t.(type) {
+		case *lox.RefType:
+			if t.Value == nil {
+				x, ok := names[t]
+				if !ok {
+					x = &lox.RefType{ID: len(names) + 1}
+					names[t] = x
+					x.Attrs = mapAttrs(t.Attrs, resolve)
+				}
+				return x
+			}
+			if path[t] {
+				// Stop at recursive types.
+				return t
+			}
+			path[t] = true
+			defer delete(path, t)
+			return resolve(t.Value)
+		case lox.FunctionType:
+			params := make([]lox.Type, len(t.Params))
+			for i, param := range t.Params {
+				params[i] = resolve(param)
+			}
+			return lox.FunctionType{Params: params, Return: resolve(t.Return)}
+		case lox.ListType:
+			return lox.ListType{Element: resolve(t.Element)}
+		case lox.MapType:
+			return lox.MapType{Key: resolve(t.Key), Value: resolve(t.Value)}
+		}
+		return t
+	}
+	return lox.PrintType(resolve(t))
+}
+
 // ----
 
 func mapUnboundRefs(t lox.Type, f transformRef) lox.Type {
@@ -126,18 +417,33 @@ func (m *refMapper) VisitFunctionType(t lox.FunctionType) {
 	m.state = lox.FunctionType{params, result}
 }
 
+func (m *refMapper) VisitListType(t lox.ListType) {
+	m.state = lox.ListType{Element: m.visit(t.Element)}
+}
+
+func (m *refMapper) VisitMapType(t lox.MapType) {
+	key := m.visit(t.Key)
+	value := m.visit(t.Value)
+	m.state = lox.MapType{Key: key, Value: value}
+}
+
+// Classes are nominal, so their members are never copied.
+func (m *refMapper) VisitClassType(t *lox.ClassType)      { m.state = t }
+func (m *refMapper) VisitInstanceType(t lox.InstanceType) { m.state = t }
+
 func (m *refMapper) VisitRefType(t *lox.RefType) {
+	if t.Value == nil {
+		m.state = m.transform(t, nil)
+		return
+	}
 	if _, ok := m.seen[t]; ok {
+		// Stop at recursive types.
 		m.state = t
 		return
 	}
 	m.seen[t] = struct{}{}
-	if t.Value != nil {
-		m.visit(t.Value)
-	} else {
-		m.state = m.transform(t, nil)
-		// TODO
-	}
+	defer delete(m.seen, t)
+	m.visit(t.Value)
 }
 
 func (m *refMapper) VisitConstraints(cnstrs []Constraint) []Constraint {
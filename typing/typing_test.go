@@ -23,6 +23,10 @@ func types_(ts ...lox.Type) []lox.Type {
 	return ts
 }
 
+func attrs_(attrs ...lox.Attribute) []lox.Attribute {
+	return attrs
+}
+
 // Unbound ref
 func ref_() *lox.RefType {
 	return &lox.RefType{}
@@ -80,7 +84,15 @@ func call_(clauseID int, t lox.Type) *typing.CallGoal {
 }
 
 func clause_(clauseID int, name string, head lox.FunctionType, body ...typing.Goal) typing.TypeClause {
-	return typing.TypeClause{clauseID, name, head, body}
+	return typing.TypeClause{ID: clauseID, Name: name, Head: head, Body: body}
+}
+
+func choice_(alternatives ...[]typing.Goal) *typing.ChoiceGoal {
+	return &typing.ChoiceGoal{Alternatives: alternatives}
+}
+
+func goals_(goals ...typing.Goal) []typing.Goal {
+	return goals
 }
 
 func clauses_(cls ...typing.TypeClause) []typing.TypeClause {
@@ -111,6 +123,18 @@ func parse(t *testing.T, text string) []lox.Stmt {
 	return stmts
 }
 
+func parseExpr(t *testing.T, text string) lox.Expr {
+	tokens, err := lox.NewScanner(text).ScanTokens()
+	if err != nil {
+		t.Fatalf("scanner: %v", err)
+	}
+	expr, err := lox.NewParser(tokens).ParseExpression()
+	if err != nil {
+		t.Fatalf("parser: %v", err)
+	}
+	return expr
+}
+
 // ----
 
 var ignoreTypeFields = cmp.Options{
@@ -12,21 +12,65 @@ type unifier struct {
 	stack      []typePair
 	err        error
 	constraint Constraint
+	// Changes to refs, that are undone if a tentative unification fails.
+	trail []trailEntry
 
 	t2 lox.Type
 }
 
+// trailEntry records the state of an unbound ref before it's bound or has its attributes changed.
+type trailEntry struct {
+	ref   *lox.RefType
+	attrs []lox.Attribute
+}
+
+// Unify binds refs in t1 and t2 so that they become the same type. Refs with attributes that
+// are verifiers may only be bound if they are verified, like refs with Options.
 func Unify(t1, t2 lox.Type) (Constraint, error) {
-	u := &unifier{
+	u := newUnifier(t1, t2)
+	if err := u.run(); err != nil {
+		return Constraint{}, err
+	}
+	return u.constraint, nil
+}
+
+func newUnifier(t1, t2 lox.Type) *unifier {
+	return &unifier{
 		constraint: NewConstraint(),
 		stack:      []typePair{{t1, t2}},
 	}
+}
+
+func (u *unifier) run() error {
 	for len(u.stack) > 0 {
 		if err := u.unifyStep(); err != nil {
-			return Constraint{}, err
+			return err
 		}
 	}
-	return u.constraint, nil
+	return nil
+}
+
+// unifies returns whether t1 and t2 unify, without binding any of their refs.
+func unifies(t1, t2 lox.Type) bool {
+	u := newUnifier(t1, t2)
+	err := u.run()
+	u.undo()
+	return err == nil
+}
+
+// undo restores the refs changed by this unifier.
+func (u *unifier) undo() {
+	undoTrail(u.trail)
+	u.trail = nil
+}
+
+// undoTrail restores refs to their state before the changes in trail, in reverse order.
+func undoTrail(trail []trailEntry) {
+	for i := len(trail) - 1; i >= 0; i-- {
+		entry := trail[i]
+		entry.ref.Value = nil
+		entry.ref.Attrs = entry.attrs
+	}
 }
 
 func (u *unifier) push(t1, t2 lox.Type) {
@@ -58,14 +102,43 @@ func (u *unifier) unifyStep() error {
 	return u.match(t1, t2)
 }
 
+// bindRef binds x to t, if all its verifiers accept it. Other attributes are moved to t, if
+// it's an unbound ref.
 func (u *unifier) bindRef(x *lox.RefType, t lox.Type) {
 	if x.Value != nil {
 		panic(fmt.Sprintf("compiler error: expecting to be called on an unbound ref, got %v", lox.PrintType(x)))
 	}
+	for _, attr := range x.Attrs {
+		if v, ok := attr.(Verifier); ok {
+			if err := v.Verify(u, x, t); err != nil {
+				u.err = err
+				return
+			}
+		} else if y, ok := t.(*lox.RefType); ok {
+			u.SetAttrs(y, putAttr(y.Attrs, -1, attr))
+		}
+	}
+	u.trail = append(u.trail, trailEntry{x, x.Attrs})
 	x.Value = t
+	x.Attrs = nil
 	u.constraint.Put(x, t)
 }
 
+// ---- Binder
+
+func (u *unifier) Unify(t1, t2 lox.Type) {
+	u.push(t1, t2)
+}
+
+func (u *unifier) Unifies(t1, t2 lox.Type) bool {
+	return unifies(t1, t2)
+}
+
+func (u *unifier) SetAttrs(x *lox.RefType, attrs []lox.Attribute) {
+	u.trail = append(u.trail, trailEntry{x, x.Attrs})
+	x.Attrs = attrs
+}
+
 // ---- Type visitor
 
 func (u *unifier) VisitNilType(t1 lox.NilType) {
@@ -106,17 +179,54 @@ func (u *unifier) VisitFunctionType(t1 lox.FunctionType) {
 	}
 }
 
+func (u *unifier) VisitListType(t1 lox.ListType) {
+	t2, ok := u.t2.(lox.ListType)
+	if !ok {
+		u.err = typeError{t1, u.t2}
+		return
+	}
+	u.push(t1.Element, t2.Element)
+}
+
+func (u *unifier) VisitMapType(t1 lox.MapType) {
+	t2, ok := u.t2.(lox.MapType)
+	if !ok {
+		u.err = typeError{t1, u.t2}
+		return
+	}
+	u.push(t1.Value, t2.Value)
+	u.push(t1.Key, t2.Key)
+}
+
+// Classes are nominal, so they only unify with themselves.
+func (u *unifier) VisitClassType(t1 *lox.ClassType) {
+	if t2, ok := u.t2.(*lox.ClassType); !ok || t1 != t2 {
+		u.err = typeError{t1, u.t2}
+	}
+}
+
+// Instances unify if one's class is a subclass of the other's, since they share the
+// members of the superclass.
+func (u *unifier) VisitInstanceType(t1 lox.InstanceType) {
+	t2, ok := u.t2.(lox.InstanceType)
+	if !ok || !(isSubclass(t1.Class, t2.Class) || isSubclass(t2.Class, t1.Class)) {
+		u.err = typeError{t1, u.t2}
+	}
+}
+
 func (u *unifier) VisitRefType(x *lox.RefType) {
 	y, ok := u.t2.(*lox.RefType)
 	if !ok {
 		u.bindRef(x, u.t2)
 		return
 	}
-	if x.ID < y.ID {
-		u.bindRef(y, x)
-	} else if x.ID > y.ID {
-		u.bindRef(x, y)
-	} else {
+	if x == y {
 		// They are the same ref, do nothing.
+		return
+	}
+	// Bind the newest ref to the oldest, that receives its attributes.
+	if x.ID < y.ID {
+		x, y = y, x
 	}
+	u.bindRef(x, y)
 }
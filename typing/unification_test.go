@@ -81,3 +81,201 @@ func TestUnifier(t *testing.T) {
 		})
 	}
 }
+
+func TestUnifierErrorDiagnostic(t *testing.T) {
+	num := lox.NumberType{Token: lox.Token{
+		TokenType: lox.Number,
+		Lexeme:    "10",
+		Line:      2,
+		Span:      lox.Span{Start: lox.Position{Offset: 5, Line: 2, Column: 3}, End: lox.Position{Offset: 7, Line: 2, Column: 5}},
+	}}
+	_, err := typing.Unify(str_, num)
+	if err == nil {
+		t.Fatalf("want err, got nil")
+	}
+	want := []lox.Diagnostic{{
+		Severity: lox.SeverityError,
+		Phase:    lox.TypePhase,
+		Code:     "type-mismatch",
+		Message:  "String != Number",
+		Span:     num.Token.Span,
+	}}
+	if diff := cmp.Diff(want, lox.Diagnostics(err)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+	if diff := cmp.Diff("line 2 at '10': String != Number", err.Error()); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
+
+func TestUnifierOptions(t *testing.T) {
+	// Unbound ref with options.
+	oref_ := func(id int, options ...lox.Type) *lox.RefType {
+		return &lox.RefType{ID: id, Attrs: attrs_(typing.Options(options))}
+	}
+	tests := []struct {
+		desc   string
+		t1, t2 lox.Type
+		want   string
+	}{
+		{"one option fits", oref_(1, num_, str_), str_, "String"},
+		{"narrow options", oref_(1, num_, str_, bool_), oref_(2, str_, bool_, func_(nil, num_)), "_1{String|Bool}"},
+		{"single option left", oref_(1, num_, str_), oref_(2, str_), "String"},
+		{"ref without options", oref_(1, num_, str_), refi_(2), "_1{Number|String}"},
+		{
+			"bind option",
+			oref_(1, func_(types_(num_), num_), func_(types_(num_, num_), num_)),
+			func_(types_(refi_(2)), refi_(3)),
+			"(Fun (Number) Number)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := typing.Unify(test.t1, test.t2); err != nil {
+				t.Fatalf("got err: %v", err)
+			}
+			if diff := cmp.Diff(test.want, typing.FormatType(test.t1)); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnifierOptionsError(t *testing.T) {
+	tests := []struct {
+		t1, t2 lox.Type
+		want   string
+	}{
+		{&lox.RefType{ID: 1, Attrs: attrs_(typing.Options{num_, str_})}, bool_, "Bool doesn't match any of Number | String"},
+		{
+			&lox.RefType{ID: 1, Attrs: attrs_(typing.Options{num_, str_})},
+			&lox.RefType{ID: 2, Attrs: attrs_(typing.Options{bool_, func_(nil, num_)})},
+			"_2{Bool|(Fun () Number)} doesn't match any of Number | String",
+		},
+	}
+	for _, test := range tests {
+		_, err := typing.Unify(test.t1, test.t2)
+		if err == nil {
+			t.Errorf("%v = %v: want err, got nil", test.t1, test.t2)
+			continue
+		}
+		if diff := cmp.Diff(test.want, err.Error()); diff != "" {
+			t.Errorf("%v = %v: (-want, +got)\n%s", test.t1, test.t2, diff)
+		}
+	}
+}
+
+func TestUnifierMembers(t *testing.T) {
+	point := &lox.ClassType{
+		Name:   lox.Token{Lexeme: "Point"},
+		Fields: map[string]lox.Type{"x": num_, "y": num_},
+	}
+	instance := lox.InstanceType{Class: point}
+	// Unbound ref with members.
+	mref_ := func(id int, members typing.Members) *lox.RefType {
+		return &lox.RefType{ID: id, Attrs: attrs_(members)}
+	}
+	// Types of member 'x' in each test.
+	xs := []*lox.RefType{refi_(2), refi_(2), refi_(2), refi_(2)}
+	tests := []struct {
+		desc   string
+		t1, t2 lox.Type
+		want   string
+	}{
+		{"instance", mref_(1, typing.Members{"x": xs[0]}), instance, "(Fun (Point) Number)"},
+		{"merge members", mref_(1, typing.Members{"x": xs[1]}), mref_(4, typing.Members{"y": refi_(3)}), "(Fun (_1{.x: _2, .y: _3}) _2)"},
+		{"shared member", mref_(1, typing.Members{"x": num_}), mref_(4, typing.Members{"x": xs[2]}), "(Fun (_1{.x: Number}) Number)"},
+		{"nil", mref_(1, typing.Members{"x": xs[3]}), nil_, "(Fun (Nil) _1)"},
+	}
+	for i, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			if _, err := typing.Unify(test.t1, test.t2); err != nil {
+				t.Fatalf("got err: %v", err)
+			}
+			got := typing.FormatType(func_(types_(test.t1), xs[i]))
+			if diff := cmp.Diff(test.want, got); diff != "" {
+				t.Errorf("(-want, +got)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUnifierMembersError(t *testing.T) {
+	point := &lox.ClassType{
+		Name:   lox.Token{Lexeme: "Point"},
+		Fields: map[string]lox.Type{"x": num_},
+	}
+	instance := lox.InstanceType{Class: point}
+	tests := []struct {
+		t1, t2 lox.Type
+		want   string
+	}{
+		{&lox.RefType{ID: 1, Attrs: attrs_(typing.Members{"z": num_})}, instance, "_1{.z: Number} != Point"},
+		{&lox.RefType{ID: 1, Attrs: attrs_(typing.Members{"x": num_})}, num_, "_1{.x: Number} != Number"},
+		{&lox.RefType{ID: 1, Attrs: attrs_(typing.Members{"x": str_})}, instance, "String != Number"},
+	}
+	for _, test := range tests {
+		_, err := typing.Unify(test.t1, test.t2)
+		if err == nil {
+			t.Errorf("%v = %v: want err, got nil", test.t1, test.t2)
+			continue
+		}
+		if diff := cmp.Diff(test.want, err.Error()); diff != "" {
+			t.Errorf("%v = %v: (-want, +got)\n%s", test.t1, test.t2, diff)
+		}
+	}
+}
+
+// notType is a verifier that forbids its ref from being bound to a type.
+type notType struct {
+	t lox.Type
+}
+
+func (attr notType) MapTypes(f func(lox.Type) lox.Type) lox.Attribute {
+	return notType{f(attr.t)}
+}
+
+func (attr notType) String() string {
+	return "not " + lox.PrintType(attr.t)
+}
+
+func (attr notType) Verify(b typing.Binder, x *lox.RefType, t lox.Type) error {
+	if y, ok := t.(*lox.RefType); ok {
+		b.SetAttrs(y, append(attrs_(attr), y.Attrs...))
+		return nil
+	}
+	if b.Unifies(attr.t, t) {
+		return fmt.Errorf("%s can't be %s", lox.PrintType(x), lox.PrintType(t))
+	}
+	return nil
+}
+
+// label is an attribute without verification.
+type label string
+
+func (attr label) MapTypes(f func(lox.Type) lox.Type) lox.Attribute { return attr }
+func (attr label) String() string                                   { return string(attr) }
+
+func TestUnifierVerifier(t *testing.T) {
+	x1 := &lox.RefType{ID: 1}
+	x2 := &lox.RefType{ID: 2, Attrs: attrs_(notType{bool_}, label("b"), typing.Options{num_, str_, bool_})}
+	if _, err := typing.Unify(x1, x2); err != nil {
+		t.Fatalf("got err: %v", err)
+	}
+	if diff := cmp.Diff("_1{not Bool}{b}{Number|String|Bool}", typing.FormatType(x1)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+	_, err := typing.Unify(x1, bool_)
+	if err == nil {
+		t.Fatalf("want err, got nil")
+	}
+	if diff := cmp.Diff("_1{not Bool}{b}{Number|String|Bool} can't be Bool", err.Error()); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+	if _, err := typing.Unify(x1, str_); err != nil {
+		t.Fatalf("got err: %v", err)
+	}
+	if diff := cmp.Diff("String", typing.FormatType(x1)); diff != "" {
+		t.Errorf("(-want, +got)\n%s", diff)
+	}
+}
@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/brunokim/kilox"
+)
+
+// builtins returns the same natives available in the tree-walking interpreter, using
+// the clock and random source from vm.
+func builtins(vm *VM) []*native {
+	return []*native{
+		{name: "clock", arity: 0, fn: vm.clockFunc},
+		{name: "type", arity: 1, fn: typeFunc},
+		{name: "random", arity: 0, fn: vm.randomFunc},
+		{name: "randomSeed", arity: 1, fn: vm.randomSeedFunc},
+		{name: "len", arity: 1, fn: lenFunc},
+		{name: "keys", arity: 1, fn: keysFunc},
+	}
+}
+
+func (vm *VM) clockFunc(args []any) (any, error) {
+	return vm.clock(), nil
+}
+
+func typeFunc(args []any) (any, error) {
+	arg := args[0]
+	switch v := arg.(type) {
+	case nil:
+		return lox.NilType{}, nil
+	case bool:
+		return lox.BoolType{}, nil
+	case float64:
+		return lox.NumberType{}, nil
+	case string:
+		return lox.StringType{}, nil
+	case *instance:
+		return v.class, nil
+	case *class:
+		return metaClass{v}, nil
+	case metaClass, metaType:
+		return metaType{}, nil
+	case *list:
+		return lox.ListType{Element: &lox.RefType{ID: 1}}, nil
+	case *dict:
+		return lox.MapType{Key: &lox.RefType{ID: 1}, Value: &lox.RefType{ID: 2}}, nil
+	case *closure:
+		return genericFunctionType(v.fn.Arity), nil
+	case *boundMethod:
+		return genericFunctionType(v.method.fn.Arity), nil
+	case *native:
+		return genericFunctionType(v.arity), nil
+	default:
+		return nil, fmt.Errorf("unhandled type(%v) (%s)", arg, typeName(arg))
+	}
+}
+
+func (vm *VM) randomFunc(args []any) (any, error) {
+	return vm.rand.Float64(), nil
+}
+
+func (vm *VM) randomSeedFunc(args []any) (any, error) {
+	seed, ok := args[0].(float64)
+	if !ok {
+		return nil, fmt.Errorf("unhandled randomSeed(%v) (%s)", args[0], typeName(args[0]))
+	}
+	vm.rand.Seed(int64(seed))
+	return nil, nil
+}
+
+func lenFunc(args []any) (any, error) {
+	switch v := args[0].(type) {
+	case *list:
+		return float64(len(v.elements)), nil
+	case *dict:
+		return float64(v.entries.Len()), nil
+	case string:
+		return float64(len(v)), nil
+	default:
+		return nil, fmt.Errorf("unhandled len(%v) (%s)", v, typeName(v))
+	}
+}
+
+func keysFunc(args []any) (any, error) {
+	d, ok := args[0].(*dict)
+	if !ok {
+		return nil, fmt.Errorf("unhandled keys(%v) (%s)", args[0], typeName(args[0]))
+	}
+	keys := d.entries.Keys()
+	elems := make([]any, len(keys))
+	for i, key := range keys {
+		elems[i] = key.value()
+	}
+	return &list{elems}, nil
+}
@@ -0,0 +1,236 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/brunokim/kilox"
+)
+
+type OpCode byte
+
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	OpGetLocal
+	OpSetLocal
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	OpGetUpvalue
+	OpSetUpvalue
+	OpGetProperty
+	OpSetProperty
+	OpGetSuper
+	OpGetIndex
+	OpSetIndex
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpNot
+	OpNegate
+	OpPrint
+	OpJump
+	OpJumpIfFalse
+	OpLoop
+	OpCall
+	OpClosure
+	OpCloseUpvalue
+	OpReturn
+	OpList
+	OpMap
+	OpClass
+	OpInherit
+	OpMethod
+	OpStaticMethod
+	OpStaticVar
+	OpField
+	OpTry
+	OpTryFinally
+	OpEndTry
+	OpThrow
+	OpRethrow
+	OpImport
+)
+
+var opNames = [...]string{
+	OpConstant:     "OP_CONSTANT",
+	OpNil:          "OP_NIL",
+	OpTrue:         "OP_TRUE",
+	OpFalse:        "OP_FALSE",
+	OpPop:          "OP_POP",
+	OpGetLocal:     "OP_GET_LOCAL",
+	OpSetLocal:     "OP_SET_LOCAL",
+	OpGetGlobal:    "OP_GET_GLOBAL",
+	OpDefineGlobal: "OP_DEFINE_GLOBAL",
+	OpSetGlobal:    "OP_SET_GLOBAL",
+	OpGetUpvalue:   "OP_GET_UPVALUE",
+	OpSetUpvalue:   "OP_SET_UPVALUE",
+	OpGetProperty:  "OP_GET_PROPERTY",
+	OpSetProperty:  "OP_SET_PROPERTY",
+	OpGetSuper:     "OP_GET_SUPER",
+	OpGetIndex:     "OP_GET_INDEX",
+	OpSetIndex:     "OP_SET_INDEX",
+	OpEqual:        "OP_EQUAL",
+	OpNotEqual:     "OP_NOT_EQUAL",
+	OpGreater:      "OP_GREATER",
+	OpGreaterEqual: "OP_GREATER_EQUAL",
+	OpLess:         "OP_LESS",
+	OpLessEqual:    "OP_LESS_EQUAL",
+	OpAdd:          "OP_ADD",
+	OpSubtract:     "OP_SUBTRACT",
+	OpMultiply:     "OP_MULTIPLY",
+	OpDivide:       "OP_DIVIDE",
+	OpNot:          "OP_NOT",
+	OpNegate:       "OP_NEGATE",
+	OpPrint:        "OP_PRINT",
+	OpJump:         "OP_JUMP",
+	OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+	OpLoop:         "OP_LOOP",
+	OpCall:         "OP_CALL",
+	OpClosure:      "OP_CLOSURE",
+	OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+	OpReturn:       "OP_RETURN",
+	OpList:         "OP_LIST",
+	OpMap:          "OP_MAP",
+	OpClass:        "OP_CLASS",
+	OpInherit:      "OP_INHERIT",
+	OpMethod:       "OP_METHOD",
+	OpStaticMethod: "OP_STATIC_METHOD",
+	OpStaticVar:    "OP_STATIC_VAR",
+	OpField:        "OP_FIELD",
+	OpTry:          "OP_TRY",
+	OpTryFinally:   "OP_TRY_FINALLY",
+	OpEndTry:       "OP_END_TRY",
+	OpThrow:        "OP_THROW",
+	OpRethrow:      "OP_RETHROW",
+	OpImport:       "OP_IMPORT",
+}
+
+func (op OpCode) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("OpCode(%d)", op)
+}
+
+// ----
+
+// Chunk is a sequence of bytecode instructions.
+//
+// Operands are stored inline after each opcode, either as a single byte or as two
+// bytes in big-endian order. Each byte is associated to the token that originated it,
+// so that runtime errors can be reported in the same way as the tree-walking interpreter.
+type Chunk struct {
+	Code      []byte
+	Tokens    []lox.Token
+	Constants []any
+}
+
+func (c *Chunk) write(b byte, token lox.Token) {
+	c.Code = append(c.Code, b)
+	c.Tokens = append(c.Tokens, token)
+}
+
+func (c *Chunk) write16(x uint16, token lox.Token) {
+	c.write(byte(x>>8), token)
+	c.write(byte(x), token)
+}
+
+func (c *Chunk) read16(offset int) uint16 {
+	return uint16(c.Code[offset])<<8 | uint16(c.Code[offset+1])
+}
+
+func (c *Chunk) addConstant(value any) int {
+	for i, constant := range c.Constants {
+		// Only primitive values are deduplicated.
+		if isPrimitive(value) && constant == value {
+			return i
+		}
+	}
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+func isPrimitive(value any) bool {
+	switch value.(type) {
+	case nil, bool, float64, string:
+		return true
+	}
+	return false
+}
+
+// ----
+
+// Disassemble writes a human-readable listing of the chunk's instructions, followed by
+// the listing of every function defined within it.
+func (c *Chunk) Disassemble(w io.Writer, name string) {
+	fmt.Fprintf(w, "== %s ==\n", name)
+	for offset := 0; offset < len(c.Code); {
+		offset = c.disassembleInstruction(w, offset)
+	}
+	for _, constant := range c.Constants {
+		if fn, ok := constant.(*Function); ok {
+			fn.Chunk.Disassemble(w, fn.String())
+		}
+	}
+}
+
+func (c *Chunk) disassembleInstruction(w io.Writer, offset int) int {
+	fmt.Fprintf(w, "%04d ", offset)
+	if offset > 0 && c.Tokens[offset].Line == c.Tokens[offset-1].Line {
+		fmt.Fprint(w, "   | ")
+	} else {
+		fmt.Fprintf(w, "%4d ", c.Tokens[offset].Line)
+	}
+	op := OpCode(c.Code[offset])
+	switch op {
+	case OpConstant, OpGetGlobal, OpDefineGlobal, OpSetGlobal,
+		OpGetProperty, OpSetProperty, OpGetSuper,
+		OpClass, OpMethod, OpStaticMethod, OpStaticVar, OpField, OpImport:
+		index := c.read16(offset + 1)
+		fmt.Fprintf(w, "%-16s %4d '%v'\n", op, index, repr(c.Constants[index]))
+		return offset + 3
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall:
+		fmt.Fprintf(w, "%-16s %4d\n", op, c.Code[offset+1])
+		return offset + 2
+	case OpList, OpMap:
+		fmt.Fprintf(w, "%-16s %4d\n", op, c.read16(offset+1))
+		return offset + 3
+	case OpJump, OpJumpIfFalse, OpTry, OpTryFinally:
+		jump := int(c.read16(offset + 1))
+		fmt.Fprintf(w, "%-16s %4d -> %d\n", op, offset, offset+3+jump)
+		return offset + 3
+	case OpLoop:
+		jump := int(c.read16(offset + 1))
+		fmt.Fprintf(w, "%-16s %4d -> %d\n", op, offset, offset+3-jump)
+		return offset + 3
+	case OpClosure:
+		index := c.read16(offset + 1)
+		fn := c.Constants[index].(*Function)
+		fmt.Fprintf(w, "%-16s %4d %v\n", op, index, fn)
+		offset += 3
+		for i := 0; i < fn.upvalueCount; i++ {
+			kind := "upvalue"
+			if c.Code[offset] == 1 {
+				kind = "local"
+			}
+			fmt.Fprintf(w, "%04d    |                     %s %d\n", offset, kind, c.Code[offset+1])
+			offset += 2
+		}
+		return offset
+	default:
+		fmt.Fprintf(w, "%s\n", op)
+		return offset + 1
+	}
+}
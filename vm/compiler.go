@@ -0,0 +1,874 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/errlist"
+)
+
+// Resolution holds the static position of local variables, as computed by lox.Resolver.
+//
+// It's implemented by *lox.Interpreter.
+type Resolution interface {
+	LocalPosition(expr lox.Expr) (distance int, index int, ok bool)
+}
+
+type compileError struct {
+	token lox.Token
+	msg   string
+}
+
+func (err compileError) Error() string {
+	return fmt.Sprintf("line %d at '%s': %s", err.token.Line, err.token.Lexeme, err.msg)
+}
+
+// ----
+
+type funcKind int
+
+const (
+	scriptFunc funcKind = iota
+	namedFunc
+	methodFunc
+	initFunc
+)
+
+// local is a stack slot in a function's frame.
+type local struct {
+	slot       int
+	isCaptured bool
+}
+
+// variable mirrors a declaration in one of the resolver's scopes.
+//
+// Names that are never read at runtime, like fields, have a nil local.
+type variable struct {
+	fc    *funcCompiler
+	local *local
+}
+
+// scope mirrors a resolver's scope, so that local positions can be mapped to variables.
+type scope struct {
+	vars []*variable
+	// Number of locals in the current function when the scope began.
+	localCount int
+}
+
+type loop struct {
+	localCount int
+	// Number of try statements in the current function when the loop began.
+	tryCount  int
+	breaks    []int
+	continues []int
+}
+
+// tryBlock is a try statement being compiled. Jumping out of it requires removing its
+// handler and running its finally clause.
+type tryBlock struct {
+	// Number of handlers installed for the clause being compiled.
+	handlerCount int
+	finally      []lox.Stmt
+	// Scopes enclosing the statement, where the finally clause is resolved.
+	scopes []*scope
+}
+
+type upvalueRef struct {
+	isLocal bool
+	index   int
+}
+
+type funcCompiler struct {
+	enclosing *funcCompiler
+	fn        *Function
+	kind      funcKind
+	locals    []*local
+	upvalues  []upvalueRef
+	loops     []*loop
+	tries     []*tryBlock
+}
+
+func newFuncCompiler(enclosing *funcCompiler, name string, kind funcKind) *funcCompiler {
+	return &funcCompiler{
+		enclosing: enclosing,
+		fn:        &Function{Name: name, Chunk: &Chunk{}},
+		kind:      kind,
+		// Slot 0 is reserved for the function being called, or 'this' within methods.
+		locals: []*local{{slot: 0}},
+	}
+}
+
+// Compiler translates resolved statements into bytecode.
+//
+// Global variables are accessed by name, and local variables are stored in stack
+// slots, or as upvalues if they are captured by a closure.
+type Compiler struct {
+	res    Resolution
+	fc     *funcCompiler
+	scopes []*scope
+	token  lox.Token
+	errs   errlist.Of[compileError]
+}
+
+func NewCompiler(res Resolution) *Compiler {
+	return &Compiler{res: res}
+}
+
+// Compile returns the top-level function for stmts, that must have been resolved
+// with the same Resolution used by the compiler.
+func (c *Compiler) Compile(stmts []lox.Stmt) (*Function, error) {
+	c.fc = newFuncCompiler(nil, "", scriptFunc)
+	c.scopes = nil
+	c.errs = nil
+	for _, stmt := range stmts {
+		c.compileStmt(stmt)
+	}
+	c.emitReturn()
+	fn := c.fc.fn
+	c.fc = nil
+	if len(c.errs) > 0 {
+		return nil, c.errs
+	}
+	return fn, nil
+}
+
+func (c *Compiler) addError(token lox.Token, msg string) {
+	c.errs = append(c.errs, compileError{token, msg})
+}
+
+func (c *Compiler) compileStmt(stmt lox.Stmt) {
+	stmt.Accept(c)
+}
+
+func (c *Compiler) compileExpr(expr lox.Expr) {
+	expr.Accept(c)
+}
+
+// ---- emitters
+
+func (c *Compiler) chunk() *Chunk {
+	return c.fc.fn.Chunk
+}
+
+func (c *Compiler) emit(op OpCode, token lox.Token) {
+	if token.Line > 0 {
+		c.token = token
+	}
+	c.chunk().write(byte(op), c.token)
+}
+
+func (c *Compiler) emitByte(op OpCode, b int, token lox.Token) {
+	c.emit(op, token)
+	c.chunk().write(byte(b), c.token)
+}
+
+func (c *Compiler) emit16(op OpCode, x int, token lox.Token) {
+	c.emit(op, token)
+	c.chunk().write16(uint16(x), c.token)
+}
+
+func (c *Compiler) makeConstant(value any) int {
+	index := c.chunk().addConstant(value)
+	if index > math.MaxUint16 {
+		c.addError(c.token, "too many constants in one chunk")
+		return 0
+	}
+	return index
+}
+
+func (c *Compiler) emitConstant(op OpCode, value any, token lox.Token) {
+	c.emit16(op, c.makeConstant(value), token)
+}
+
+func (c *Compiler) emitJump(op OpCode, token lox.Token) int {
+	c.emit16(op, 0xffff, token)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *Compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > math.MaxUint16 {
+		c.addError(c.token, "too much code to jump over")
+		return
+	}
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *Compiler) emitLoop(start int) {
+	c.emit(OpLoop, c.token)
+	jump := len(c.chunk().Code) - start + 2
+	if jump > math.MaxUint16 {
+		c.addError(c.token, "loop body too large")
+	}
+	c.chunk().write16(uint16(jump), c.token)
+}
+
+func (c *Compiler) emitReturn() {
+	c.emitDefaultResult()
+	c.emit(OpReturn, c.token)
+}
+
+// emitDefaultResult emits the result of a function without a return value, that is 'this'
+// within initializers.
+func (c *Compiler) emitDefaultResult() {
+	if c.fc.kind == initFunc {
+		c.emitByte(OpGetLocal, 0, c.token)
+	} else {
+		c.emit(OpNil, c.token)
+	}
+}
+
+// ---- scopes and variables
+
+func (c *Compiler) beginScope() {
+	c.scopes = append(c.scopes, &scope{localCount: len(c.fc.locals)})
+}
+
+// endScope discards the locals declared within the current scope.
+func (c *Compiler) endScope() {
+	s := c.popScope()
+	c.discardLocals(s.localCount)
+	c.fc.locals = c.fc.locals[:s.localCount]
+}
+
+// popScope ends the current scope without emitting any code, as in the end of a function body.
+func (c *Compiler) popScope() *scope {
+	n := len(c.scopes)
+	s := c.scopes[n-1]
+	c.scopes = c.scopes[:n-1]
+	return s
+}
+
+// discardLocals emits code to pop locals from the stack until there are only n of them,
+// closing the upvalues of captured locals.
+func (c *Compiler) discardLocals(n int) {
+	for i := len(c.fc.locals) - 1; i >= n; i-- {
+		if c.fc.locals[i].isCaptured {
+			c.emit(OpCloseUpvalue, c.token)
+		} else {
+			c.emit(OpPop, c.token)
+		}
+	}
+}
+
+func (c *Compiler) addLocal(token lox.Token) *local {
+	l := &local{slot: len(c.fc.locals)}
+	if l.slot > math.MaxUint8 {
+		c.addError(token, "too many local variables in function")
+	}
+	c.fc.locals = append(c.fc.locals, l)
+	return l
+}
+
+// removeLocal forgets the topmost local, that must have been discarded already.
+func (c *Compiler) removeLocal() {
+	c.fc.locals = c.fc.locals[:len(c.fc.locals)-1]
+}
+
+// declare adds a variable to the current scope, stored in the next stack slot.
+// Returns nil if the variable is global.
+func (c *Compiler) declare(name lox.Token) *variable {
+	if len(c.scopes) == 0 {
+		return nil
+	}
+	v := &variable{fc: c.fc, local: c.addLocal(name)}
+	s := c.scopes[len(c.scopes)-1]
+	s.vars = append(s.vars, v)
+	return v
+}
+
+// declareName adds a variable to the current scope that has no storage of its own.
+func (c *Compiler) declareName() *variable {
+	v := &variable{}
+	s := c.scopes[len(c.scopes)-1]
+	s.vars = append(s.vars, v)
+	return v
+}
+
+func (c *Compiler) variableAt(distance, index int) *variable {
+	n := len(c.scopes)
+	if distance >= n || index >= len(c.scopes[n-1-distance].vars) {
+		panic(fmt.Errorf("compiler error: no variable at position {dist: %d, idx: %d}", distance, index))
+	}
+	return c.scopes[n-1-distance].vars[index]
+}
+
+func (c *Compiler) lookup(expr lox.Expr) (*variable, bool) {
+	distance, index, ok := c.res.LocalPosition(expr)
+	if !ok {
+		return nil, false
+	}
+	return c.variableAt(distance, index), true
+}
+
+func (c *Compiler) emitGet(v *variable, name lox.Token) {
+	if v.local == nil {
+		c.addError(name, "can't access this variable directly")
+		return
+	}
+	if v.fc == c.fc {
+		c.emitByte(OpGetLocal, v.local.slot, name)
+	} else {
+		c.emitByte(OpGetUpvalue, c.resolveUpvalue(c.fc, v, name), name)
+	}
+}
+
+func (c *Compiler) emitSet(v *variable, name lox.Token) {
+	if v.local == nil {
+		c.addError(name, "can't access this variable directly")
+		return
+	}
+	if v.fc == c.fc {
+		c.emitByte(OpSetLocal, v.local.slot, name)
+	} else {
+		c.emitByte(OpSetUpvalue, c.resolveUpvalue(c.fc, v, name), name)
+	}
+}
+
+// resolveUpvalue returns the index of v in fc's upvalues, capturing it from each
+// enclosing function until the one where it's declared.
+func (c *Compiler) resolveUpvalue(fc *funcCompiler, v *variable, name lox.Token) int {
+	if fc.enclosing == v.fc {
+		v.local.isCaptured = true
+		return c.addUpvalue(fc, upvalueRef{isLocal: true, index: v.local.slot}, name)
+	}
+	index := c.resolveUpvalue(fc.enclosing, v, name)
+	return c.addUpvalue(fc, upvalueRef{isLocal: false, index: index}, name)
+}
+
+func (c *Compiler) addUpvalue(fc *funcCompiler, ref upvalueRef, name lox.Token) int {
+	for i, upvalue := range fc.upvalues {
+		if upvalue == ref {
+			return i
+		}
+	}
+	if len(fc.upvalues) > math.MaxUint8 {
+		c.addError(name, "too many closure variables in function")
+		return 0
+	}
+	fc.upvalues = append(fc.upvalues, ref)
+	return len(fc.upvalues) - 1
+}
+
+// function compiles a function body and emits a closure for it.
+//
+// Within methods, this is the variable holding 'this', that is stored in the method's slot 0.
+func (c *Compiler) function(name string, params []lox.Token, body []lox.Stmt, kind funcKind, token lox.Token, this *variable) {
+	fc := newFuncCompiler(c.fc, name, kind)
+	fc.fn.Arity = len(params)
+	if this != nil {
+		this.fc = fc
+		this.local = fc.locals[0]
+	}
+	c.fc = fc
+	c.beginScope()
+	for _, param := range params {
+		c.declare(param)
+	}
+	for _, stmt := range body {
+		c.compileStmt(stmt)
+	}
+	c.popScope()
+	c.emitReturn()
+	c.fc = fc.enclosing
+
+	fc.fn.upvalueCount = len(fc.upvalues)
+	c.emitConstant(OpClosure, fc.fn, token)
+	for _, upvalue := range fc.upvalues {
+		isLocal := 0
+		if upvalue.isLocal {
+			isLocal = 1
+		}
+		c.chunk().write(byte(isLocal), c.token)
+		c.chunk().write(byte(upvalue.index), c.token)
+	}
+}
+
+// ---- statements
+
+func (c *Compiler) VisitExpressionStmt(stmt lox.ExpressionStmt) {
+	c.compileExpr(stmt.Expression)
+	c.emit(OpPop, c.token)
+}
+
+func (c *Compiler) VisitPrintStmt(stmt lox.PrintStmt) {
+	c.compileExpr(stmt.Expression)
+	c.emit(OpPrint, c.token)
+}
+
+func (c *Compiler) VisitVarStmt(stmt lox.VarStmt) {
+	// The variable is declared before its initializer is compiled, because it may
+	// be referenced by a closure within it.
+	v := c.declare(stmt.Name)
+	if stmt.Init != nil {
+		c.compileExpr(stmt.Init)
+	} else {
+		c.emit(OpNil, stmt.Name)
+	}
+	if v == nil {
+		c.emitConstant(OpDefineGlobal, stmt.Name.Lexeme, stmt.Name)
+	}
+}
+
+func (c *Compiler) VisitIfStmt(stmt lox.IfStmt) {
+	c.compileExpr(stmt.Condition)
+	thenJump := c.emitJump(OpJumpIfFalse, c.token)
+	c.emit(OpPop, c.token)
+	c.compileStmt(stmt.Then)
+	elseJump := c.emitJump(OpJump, c.token)
+	c.patchJump(thenJump)
+	c.emit(OpPop, c.token)
+	if stmt.Else != nil {
+		c.compileStmt(stmt.Else)
+	}
+	c.patchJump(elseJump)
+}
+
+func (c *Compiler) VisitBlockStmt(stmt lox.BlockStmt) {
+	c.beginScope()
+	for _, s := range stmt.Statements {
+		c.compileStmt(s)
+	}
+	c.endScope()
+}
+
+func (c *Compiler) VisitLoopStmt(stmt lox.LoopStmt) {
+	start := len(c.chunk().Code)
+	c.compileExpr(stmt.Condition)
+	exitJump := c.emitJump(OpJumpIfFalse, c.token)
+	c.emit(OpPop, c.token)
+
+	l := &loop{localCount: len(c.fc.locals), tryCount: len(c.fc.tries)}
+	c.fc.loops = append(c.fc.loops, l)
+	c.compileStmt(stmt.Body)
+	c.fc.loops = c.fc.loops[:len(c.fc.loops)-1]
+
+	for _, jump := range l.continues {
+		c.patchJump(jump)
+	}
+	if stmt.OnLoop != nil {
+		c.compileExpr(stmt.OnLoop)
+		c.emit(OpPop, c.token)
+	}
+	c.emitLoop(start)
+	c.patchJump(exitJump)
+	c.emit(OpPop, c.token)
+	for _, jump := range l.breaks {
+		c.patchJump(jump)
+	}
+}
+
+func (c *Compiler) VisitBreakStmt(stmt lox.BreakStmt) {
+	l := c.fc.loops[len(c.fc.loops)-1]
+	c.exitTries(l.tryCount)
+	c.discardLocals(l.localCount)
+	l.breaks = append(l.breaks, c.emitJump(OpJump, stmt.Keyword))
+}
+
+func (c *Compiler) VisitContinueStmt(stmt lox.ContinueStmt) {
+	l := c.fc.loops[len(c.fc.loops)-1]
+	c.exitTries(l.tryCount)
+	c.discardLocals(l.localCount)
+	l.continues = append(l.continues, c.emitJump(OpJump, stmt.Keyword))
+}
+
+func (c *Compiler) VisitFunctionStmt(stmt lox.FunctionStmt) {
+	v := c.declare(stmt.Name)
+	c.function(stmt.Name.Lexeme, stmt.Params, stmt.Body, namedFunc, stmt.Name, nil)
+	if v == nil {
+		c.emitConstant(OpDefineGlobal, stmt.Name.Lexeme, stmt.Name)
+	}
+}
+
+func (c *Compiler) VisitReturnStmt(stmt lox.ReturnStmt) {
+	if stmt.Result == nil {
+		c.token = stmt.Keyword
+		c.emitDefaultResult()
+	} else {
+		c.compileExpr(stmt.Result)
+	}
+	if len(c.fc.tries) > 0 {
+		// The result is kept in a hidden local while finally clauses run.
+		c.addLocal(stmt.Keyword)
+		c.exitTries(0)
+		c.removeLocal()
+	}
+	c.emit(OpReturn, stmt.Keyword)
+}
+
+func (c *Compiler) VisitImportStmt(stmt lox.ImportStmt) {
+	v := c.declare(stmt.Name)
+	c.emitConstant(OpImport, stmt.Path.Literal, stmt.Path)
+	if v == nil {
+		c.emitConstant(OpDefineGlobal, stmt.Name.Lexeme, stmt.Name)
+	}
+}
+
+func (c *Compiler) VisitThrowStmt(stmt lox.ThrowStmt) {
+	c.compileExpr(stmt.Value)
+	c.emit(OpThrow, stmt.Keyword)
+}
+
+// VisitTryStmt installs a handler for the body, that jumps to the catch clause. If there's a
+// finally clause, another handler is installed for the body and catch clause, that runs
+// it and rethrows the exception.
+//
+// Otherwise, the finally clause is compiled at each exit from the statement: at its end,
+// and before return, break and continue statements.
+func (c *Compiler) VisitTryStmt(stmt lox.TryStmt) {
+	t := &tryBlock{finally: stmt.Finally, scopes: c.scopes[:len(c.scopes):len(c.scopes)]}
+	c.fc.tries = append(c.fc.tries, t)
+	var finallyHandler, catchHandler int
+	hasFinally := stmt.Finally != nil
+	if hasFinally {
+		finallyHandler = c.emitJump(OpTryFinally, stmt.Keyword)
+		t.handlerCount++
+	}
+	if stmt.CatchName != nil {
+		catchHandler = c.emitJump(OpTry, stmt.Keyword)
+		t.handlerCount++
+	}
+	c.compileBlock(stmt.Body)
+	if stmt.CatchName != nil {
+		c.emit(OpEndTry, c.token)
+		exit := c.emitJump(OpJump, c.token)
+
+		// The caught value is on top of the stack, in the slot of the catch variable.
+		c.patchJump(catchHandler)
+		t.handlerCount--
+		c.beginScope()
+		c.declare(*stmt.CatchName)
+		for _, s := range stmt.Catch {
+			c.compileStmt(s)
+		}
+		c.endScope()
+		c.patchJump(exit)
+	}
+	if hasFinally {
+		c.emit(OpEndTry, c.token)
+	}
+	c.fc.tries = c.fc.tries[:len(c.fc.tries)-1]
+	if !hasFinally {
+		return
+	}
+	c.compileFinally(t)
+	exit := c.emitJump(OpJump, c.token)
+
+	// The pending exception is on top of the stack, and is rethrown after the finally clause.
+	c.patchJump(finallyHandler)
+	c.addLocal(stmt.Keyword)
+	c.compileFinally(t)
+	c.removeLocal()
+	c.emit(OpRethrow, c.token)
+	c.patchJump(exit)
+}
+
+// compileBlock compiles stmts in a new scope.
+func (c *Compiler) compileBlock(stmts []lox.Stmt) {
+	c.beginScope()
+	for _, s := range stmts {
+		c.compileStmt(s)
+	}
+	c.endScope()
+}
+
+// compileFinally compiles the finally clause of t within the scopes enclosing the statement,
+// even if the current scope is nested within its body.
+func (c *Compiler) compileFinally(t *tryBlock) {
+	scopes := c.scopes
+	c.scopes = t.scopes
+	c.compileBlock(t.finally)
+	c.scopes = scopes
+}
+
+// exitTries emits code to jump out of the try statements being compiled, from the innermost
+// one until the n-th, removing their handlers and running their finally clauses.
+func (c *Compiler) exitTries(n int) {
+	tries := c.fc.tries
+	for i := len(tries) - 1; i >= n; i-- {
+		t := tries[i]
+		// The finally clause is only within the enclosing try statements.
+		c.fc.tries = tries[:i]
+		for j := 0; j < t.handlerCount; j++ {
+			c.emit(OpEndTry, c.token)
+		}
+		if t.finally != nil {
+			c.compileFinally(t)
+		}
+	}
+	c.fc.tries = tries
+}
+
+func (c *Compiler) VisitClassStmt(stmt lox.ClassStmt) {
+	name := stmt.Name
+	// The class is kept in a stack slot while its members are defined. For local classes,
+	// it's the slot of the declared variable.
+	v := c.declare(name)
+	var classLocal *local
+	if v != nil {
+		classLocal = v.local
+	} else {
+		classLocal = c.addLocal(name)
+	}
+	c.emitConstant(OpClass, name.Lexeme, name)
+	// 'super' is stored in a hidden local, that is captured by methods.
+	var super *variable
+	if stmt.Superclass != nil {
+		c.compileExpr(stmt.Superclass)
+		super = &variable{fc: c.fc, local: c.addLocal(stmt.Superclass.Name)}
+		c.emit(OpInherit, stmt.Superclass.Name)
+	}
+	c.emitByte(OpGetLocal, classLocal.slot, name)
+
+	// Scopes must mirror the ones created by the resolver.
+	c.beginSuperScope(super)
+	c.beginScope()
+	{
+		// class scope
+		this := c.declareName()
+		for _, method := range stmt.StaticMethods {
+			c.function(method.Name.Lexeme, method.Params, method.Body, methodFunc, method.Name, this)
+			c.emitConstant(OpStaticMethod, method.Name.Lexeme, method.Name)
+		}
+	}
+	c.popScope()
+	c.popSuperScope(super)
+
+	// Initializers are evaluated in the surrounding scope.
+	for _, decl := range stmt.StaticVars {
+		c.varInit(decl)
+		c.emitConstant(OpStaticVar, decl.Name.Lexeme, decl.Name)
+	}
+	for _, decl := range stmt.Vars {
+		c.varInit(decl)
+		c.emitConstant(OpField, decl.Name.Lexeme, decl.Name)
+	}
+
+	c.beginSuperScope(super)
+	c.beginScope()
+	{
+		// class scope
+		c.declareName() // this
+		for range stmt.StaticVars {
+			c.declareName()
+		}
+		c.beginSuperScope(super)
+		c.beginScope()
+		{
+			// instance scope
+			this := c.declareName()
+			for _, method := range stmt.Methods {
+				kind := methodFunc
+				if method.Name.Lexeme == "init" {
+					kind = initFunc
+				}
+				c.function(method.Name.Lexeme, method.Params, method.Body, kind, method.Name, this)
+				c.emitConstant(OpMethod, method.Name.Lexeme, method.Name)
+			}
+		}
+		c.popScope()
+		c.popSuperScope(super)
+	}
+	c.popScope()
+	c.popSuperScope(super)
+
+	c.emit(OpPop, c.token)
+	if super != nil {
+		c.discardLocals(len(c.fc.locals) - 1)
+		c.removeLocal()
+	}
+	if v == nil {
+		c.emitConstant(OpDefineGlobal, name.Lexeme, name)
+		c.removeLocal()
+	}
+}
+
+func (c *Compiler) varInit(decl lox.VarStmt) {
+	if decl.Init != nil {
+		c.compileExpr(decl.Init)
+	} else {
+		c.emit(OpNil, decl.Name)
+	}
+}
+
+func (c *Compiler) beginSuperScope(super *variable) {
+	if super == nil {
+		return
+	}
+	c.beginScope()
+	s := c.scopes[len(c.scopes)-1]
+	s.vars = append(s.vars, super)
+}
+
+func (c *Compiler) popSuperScope(super *variable) {
+	if super == nil {
+		return
+	}
+	c.popScope()
+}
+
+// ---- expressions
+
+func (c *Compiler) VisitBinaryExpr(expr *lox.BinaryExpr) {
+	c.compileExpr(expr.Left)
+	c.compileExpr(expr.Right)
+	token := expr.Operator
+	switch token.TokenType {
+	case lox.BangEqual:
+		c.emit(OpNotEqual, token)
+	case lox.EqualEqual:
+		c.emit(OpEqual, token)
+	case lox.Greater:
+		c.emit(OpGreater, token)
+	case lox.GreaterEqual:
+		c.emit(OpGreaterEqual, token)
+	case lox.Less:
+		c.emit(OpLess, token)
+	case lox.LessEqual:
+		c.emit(OpLessEqual, token)
+	case lox.Minus:
+		c.emit(OpSubtract, token)
+	case lox.Plus:
+		c.emit(OpAdd, token)
+	case lox.Slash:
+		c.emit(OpDivide, token)
+	case lox.Star:
+		c.emit(OpMultiply, token)
+	default:
+		panic(fmt.Errorf("compiler error: unimplemented binary operator %s", token.TokenType))
+	}
+}
+
+func (c *Compiler) VisitGroupingExpr(expr *lox.GroupingExpr) {
+	c.compileExpr(expr.Expression)
+}
+
+func (c *Compiler) VisitLiteralExpr(expr *lox.LiteralExpr) {
+	switch expr.Value {
+	case nil:
+		c.emit(OpNil, expr.Token)
+	case true:
+		c.emit(OpTrue, expr.Token)
+	case false:
+		c.emit(OpFalse, expr.Token)
+	default:
+		c.emitConstant(OpConstant, expr.Value, expr.Token)
+	}
+}
+
+func (c *Compiler) VisitUnaryExpr(expr *lox.UnaryExpr) {
+	c.compileExpr(expr.Right)
+	token := expr.Operator
+	switch token.TokenType {
+	case lox.Bang:
+		c.emit(OpNot, token)
+	case lox.Minus:
+		c.emit(OpNegate, token)
+	default:
+		panic(fmt.Errorf("compiler error: unimplemented unary operator %s", token.TokenType))
+	}
+}
+
+func (c *Compiler) VisitVariableExpr(expr *lox.VariableExpr) {
+	if v, ok := c.lookup(expr); ok {
+		c.emitGet(v, expr.Name)
+	} else {
+		c.emitConstant(OpGetGlobal, expr.Name.Lexeme, expr.Name)
+	}
+}
+
+func (c *Compiler) VisitAssignmentExpr(expr *lox.AssignmentExpr) {
+	c.compileExpr(expr.Value)
+	if v, ok := c.lookup(expr); ok {
+		c.emitSet(v, expr.Name)
+	} else {
+		c.emitConstant(OpSetGlobal, expr.Name.Lexeme, expr.Name)
+	}
+}
+
+func (c *Compiler) VisitLogicExpr(expr *lox.LogicExpr) {
+	c.compileExpr(expr.Left)
+	var endJump int
+	if expr.Operator.TokenType == lox.Or {
+		elseJump := c.emitJump(OpJumpIfFalse, expr.Operator)
+		endJump = c.emitJump(OpJump, expr.Operator)
+		c.patchJump(elseJump)
+	} else {
+		endJump = c.emitJump(OpJumpIfFalse, expr.Operator)
+	}
+	c.emit(OpPop, expr.Operator)
+	c.compileExpr(expr.Right)
+	c.patchJump(endJump)
+}
+
+func (c *Compiler) VisitCallExpr(expr *lox.CallExpr) {
+	c.compileExpr(expr.Callee)
+	for _, arg := range expr.Args {
+		c.compileExpr(arg)
+	}
+	c.emitByte(OpCall, len(expr.Args), expr.Paren)
+}
+
+func (c *Compiler) VisitFunctionExpr(expr *lox.FunctionExpr) {
+	c.function("anonymous", expr.Params, expr.Body, namedFunc, expr.Keyword, nil)
+}
+
+func (c *Compiler) VisitGetExpr(expr *lox.GetExpr) {
+	c.compileExpr(expr.Object)
+	c.emitConstant(OpGetProperty, expr.Name.Lexeme, expr.Name)
+}
+
+func (c *Compiler) VisitSetExpr(expr *lox.SetExpr) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Value)
+	c.emitConstant(OpSetProperty, expr.Name.Lexeme, expr.Name)
+}
+
+func (c *Compiler) VisitThisExpr(expr *lox.ThisExpr) {
+	v, ok := c.lookup(expr)
+	if !ok {
+		panic(fmt.Errorf("compiler error: unresolved 'this' in line %d", expr.Keyword.Line))
+	}
+	c.emitGet(v, expr.Keyword)
+}
+
+func (c *Compiler) VisitSuperExpr(expr *lox.SuperExpr) {
+	distance, index, ok := c.res.LocalPosition(expr)
+	if !ok {
+		panic(fmt.Errorf("compiler error: unresolved 'super' in line %d", expr.Keyword.Line))
+	}
+	// 'this' is always declared in the scope immediately enclosed by 'super'.
+	c.emitGet(c.variableAt(distance-1, 0), expr.Keyword)
+	c.emitGet(c.variableAt(distance, index), expr.Keyword)
+	c.emitConstant(OpGetSuper, expr.Method.Lexeme, expr.Method)
+}
+
+func (c *Compiler) VisitListExpr(expr *lox.ListExpr) {
+	for _, elem := range expr.Elements {
+		c.compileExpr(elem)
+	}
+	c.emit16(OpList, len(expr.Elements), expr.Bracket)
+}
+
+func (c *Compiler) VisitIndexExpr(expr *lox.IndexExpr) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.emit(OpGetIndex, expr.Bracket)
+}
+
+func (c *Compiler) VisitSetIndexExpr(expr *lox.SetIndexExpr) {
+	c.compileExpr(expr.Object)
+	c.compileExpr(expr.Index)
+	c.compileExpr(expr.Value)
+	c.emit(OpSetIndex, expr.Bracket)
+}
+
+func (c *Compiler) VisitMapExpr(expr *lox.MapExpr) {
+	for i, key := range expr.Keys {
+		c.compileExpr(key)
+		c.compileExpr(expr.Values[i])
+	}
+	c.emit16(OpMap, len(expr.Keys), expr.Brace)
+}
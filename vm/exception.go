@@ -0,0 +1,80 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/brunokim/kilox"
+)
+
+// throwSignal unwinds the stack until the innermost handler.
+type throwSignal struct {
+	keyword lox.Token
+	value   any
+}
+
+// errorClass is the class of values representing runtime errors caught by a 'catch' clause.
+var errorClass = newClass("Error")
+
+func newErrorValue(err runtimeError) *instance {
+	is := newInstance(errorClass)
+	is.fields["message"] = err.msg
+	is.fields["line"] = float64(err.token.Line)
+	return is
+}
+
+// uncaughtError converts a thrown value that reached the top-level into a runtime error.
+func uncaughtError(signal throwSignal) runtimeError {
+	value := signal.value
+	if value == nil {
+		value = "nil"
+	}
+	return runtimeError{signal.keyword, fmt.Sprintf("uncaught exception: %v", value)}
+}
+
+// handler is installed by a 'try' statement to catch exceptions raised within it.
+type handler struct {
+	// Number of frames and size of the stack when the handler was installed, that are
+	// restored when an exception is caught.
+	frameCount int
+	stackSize  int
+	// Offset of the handler's code in its frame.
+	ip int
+	// Whether the handler runs a 'finally' clause, receiving a pending exception to be
+	// rethrown instead of the thrown value.
+	isFinally bool
+}
+
+// pendingException is an exception caught by a 'finally' handler, that is rethrown after the
+// clause runs.
+type pendingException struct {
+	signal any
+}
+
+// catch unwinds the stack to the innermost handler, and pushes the exception for it. Returns
+// false if r isn't an exception, or if there's no handler.
+func (vm *VM) catch(r any) bool {
+	n := len(vm.handlers)
+	if n == 0 {
+		return false
+	}
+	var value any
+	switch signal := r.(type) {
+	case throwSignal:
+		value = signal.value
+	case runtimeError:
+		value = newErrorValue(signal)
+	default:
+		return false
+	}
+	h := vm.handlers[n-1]
+	vm.handlers = vm.handlers[:n-1]
+	vm.frames = vm.frames[:h.frameCount]
+	vm.closeUpvalues(h.stackSize)
+	vm.stack = vm.stack[:h.stackSize]
+	vm.frames[h.frameCount-1].ip = h.ip
+	if h.isFinally {
+		value = &pendingException{r}
+	}
+	vm.push(value)
+	return true
+}
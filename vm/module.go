@@ -0,0 +1,116 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brunokim/kilox"
+)
+
+// module is the value bound by an import statement, exposing the globals of the imported
+// file as its members.
+type module struct {
+	name    string
+	path    string
+	globals map[string]any
+}
+
+func (m *module) String() string {
+	return fmt.Sprintf("<module %s>", m.name)
+}
+
+// moduleImport is a module being loaded.
+type moduleImport struct {
+	name string
+	path string
+}
+
+// SetSearchPath sets the directories where imported modules are looked up, as in
+// lox.Interpreter.SetSearchPath.
+func (vm *VM) SetSearchPath(dirs ...string) {
+	vm.searchPath = dirs
+}
+
+// importModule returns the module imported as name, loading it if it wasn't imported before.
+// Errors are reported at token.
+func (vm *VM) importModule(name string, token lox.Token) *module {
+	var importer string
+	if n := len(vm.importing); n > 0 {
+		importer = vm.importing[n-1].path
+	}
+	path, err := lox.FindModule(name, importer, vm.searchPath)
+	if err != nil {
+		panic(runtimeError{token, err.Error()})
+	}
+	if m, ok := vm.modules[path]; ok {
+		return m
+	}
+	for k, imp := range vm.importing {
+		if imp.path != path {
+			continue
+		}
+		var names []string
+		for _, imp := range vm.importing[k:] {
+			names = append(names, fmt.Sprintf("%q", imp.name))
+		}
+		names = append(names, fmt.Sprintf("%q", name))
+		panic(runtimeError{token, fmt.Sprintf("import cycle: %s", strings.Join(names, " -> "))})
+	}
+	m, err := vm.loadModule(name, path)
+	if err != nil {
+		panic(runtimeError{token, fmt.Sprintf("in module %q: %v", name, err)})
+	}
+	vm.modules[path] = m
+	return m
+}
+
+// loadModule compiles the file at path and runs it with its own globals, until its
+// top-level function returns.
+//
+// Handlers installed by the importer don't catch runtime errors within the module, that
+// are returned instead. Thrown values are propagated to them.
+func (vm *VM) loadModule(name, path string) (m *module, err error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := lox.NewScanner(string(bs)).ScanTokens()
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		return nil, err
+	}
+	// The interpreter only records the resolution of the module's variables.
+	res := lox.NewInterpreter()
+	if err := lox.NewResolver(res).Resolve(stmts); err != nil {
+		return nil, err
+	}
+	fn, err := NewCompiler(res).Compile(stmts)
+	if err != nil {
+		return nil, err
+	}
+	m = &module{name, path, make(map[string]any)}
+
+	vm.importing = append(vm.importing, moduleImport{name, path})
+	defer func(handlers []handler) {
+		vm.importing = vm.importing[:len(vm.importing)-1]
+		vm.handlers = handlers
+		if r := recover(); r != nil {
+			runtimeErr, ok := r.(runtimeError)
+			if !ok {
+				panic(r)
+			}
+			err = runtimeErr
+		}
+	}(vm.handlers)
+	vm.handlers = nil
+	depth := len(vm.frames)
+	cl := &closure{fn: fn, globals: m.globals}
+	vm.push(cl)
+	vm.call(cl, 0)
+	vm.run(depth)
+	return m, nil
+}
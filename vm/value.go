@@ -0,0 +1,318 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/ordered"
+)
+
+// Function is a compiled function, ready to be instantiated as a closure.
+type Function struct {
+	Name         string
+	Arity        int
+	Chunk        *Chunk
+	upvalueCount int
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return fmt.Sprintf("<fn %s>", f.Name)
+}
+
+// ----
+
+type upvalue struct {
+	// While open, the upvalue refers to a slot in the stack. Once the slot goes out of
+	// scope the upvalue is closed, and the value is moved into it.
+	slot   int
+	isOpen bool
+	closed any
+}
+
+type closure struct {
+	fn       *Function
+	upvalues []*upvalue
+	// Globals of the module where the closure was created.
+	globals map[string]any
+}
+
+func (c *closure) String() string {
+	return c.fn.String()
+}
+
+type boundMethod struct {
+	receiver any
+	method   *closure
+}
+
+func (m *boundMethod) String() string {
+	return m.method.String()
+}
+
+type native struct {
+	name     string
+	arity    int
+	variadic bool
+	fn       lox.NativeFunc
+}
+
+func (n *native) String() string {
+	return fmt.Sprintf("<native fn %s>", n.name)
+}
+
+// ----
+
+type fieldInitializer struct {
+	name  string
+	value any
+}
+
+type class struct {
+	name          string
+	superclass    *class
+	methods       map[string]*closure
+	staticMethods map[string]*closure
+	static        map[string]any
+	fieldInits    []fieldInitializer
+}
+
+func newClass(name string) *class {
+	return &class{
+		name:          name,
+		methods:       make(map[string]*closure),
+		staticMethods: make(map[string]*closure),
+		static:        make(map[string]any),
+	}
+}
+
+func (cl *class) String() string {
+	return fmt.Sprintf("<class %s>", cl.name)
+}
+
+func (cl *class) findMethod(name string) (*closure, bool) {
+	for curr := cl; curr != nil; curr = curr.superclass {
+		if m, ok := curr.methods[name]; ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+func (cl *class) findStaticMethod(name string) (*closure, bool) {
+	for curr := cl; curr != nil; curr = curr.superclass {
+		if m, ok := curr.staticMethods[name]; ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+type metaClass struct {
+	class *class
+}
+
+func (meta metaClass) String() string {
+	return fmt.Sprintf("<meta %s>", meta.class.name)
+}
+
+type metaType struct{}
+
+func (metaType) String() string {
+	return "<meta meta>"
+}
+
+type instance struct {
+	class  *class
+	fields map[string]any
+}
+
+func newInstance(cl *class) *instance {
+	is := &instance{
+		class:  cl,
+		fields: make(map[string]any),
+	}
+	for _, fieldInit := range cl.fieldInits {
+		is.fields[fieldInit.name] = fieldInit.value
+	}
+	return is
+}
+
+func (is *instance) String() string {
+	return fmt.Sprintf("<instance %s>", is.class.name)
+}
+
+// ----
+
+type list struct {
+	elements []any
+}
+
+func (l *list) String() string {
+	var b strings.Builder
+	b.WriteRune('[')
+	for i, elem := range l.elements {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(repr(elem))
+	}
+	b.WriteRune(']')
+	return b.String()
+}
+
+func (l *list) checkIndex(index any) (int, error) {
+	num, ok := index.(float64)
+	if !ok {
+		return 0, fmt.Errorf("list index must be a number, got %s (%v)", typeName(index), index)
+	}
+	if num != math.Trunc(num) {
+		return 0, fmt.Errorf("list index must be an integer, got %v", num)
+	}
+	if num < 0 || num >= float64(len(l.elements)) {
+		return 0, fmt.Errorf("index %v is out of range [0,%d)", num, len(l.elements))
+	}
+	return int(num), nil
+}
+
+// dict is a map value, whose entries are kept in insertion order.
+type dict struct {
+	entries *ordered.Map[mapKey, any]
+}
+
+func newDict() *dict {
+	return &dict{entries: ordered.MakeMap[mapKey, any]()}
+}
+
+func (d *dict) String() string {
+	var b strings.Builder
+	b.WriteRune('{')
+	for i, entry := range d.entries.Entries() {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s: %s", repr(entry.Key.value()), repr(entry.Value))
+	}
+	b.WriteRune('}')
+	return b.String()
+}
+
+type keyKind int
+
+const (
+	nilKey keyKind = iota
+	boolKey
+	numberKey
+	stringKey
+	instanceKey
+)
+
+// mapKey is a comparable representation of a hashable Lox value.
+// Instances are hashed by identity.
+type mapKey struct {
+	kind     keyKind
+	boolean  bool
+	number   float64
+	str      string
+	instance *instance
+}
+
+func hashKey(key any) (mapKey, error) {
+	switch k := key.(type) {
+	case nil:
+		return mapKey{kind: nilKey}, nil
+	case bool:
+		return mapKey{kind: boolKey, boolean: k}, nil
+	case float64:
+		return mapKey{kind: numberKey, number: k}, nil
+	case string:
+		return mapKey{kind: stringKey, str: k}, nil
+	case *instance:
+		return mapKey{kind: instanceKey, instance: k}, nil
+	default:
+		return mapKey{}, fmt.Errorf("unhashable map key %v (%s)", key, typeName(key))
+	}
+}
+
+func (k mapKey) value() any {
+	switch k.kind {
+	case boolKey:
+		return k.boolean
+	case numberKey:
+		return k.number
+	case stringKey:
+		return k.str
+	case instanceKey:
+		return k.instance
+	default:
+		return nil
+	}
+}
+
+// ----
+
+// repr returns the representation of a value within a container.
+func repr(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// typeName returns the name of a value's type as reported by the tree-walking interpreter,
+// so that both backends produce the same runtime errors.
+func typeName(v any) string {
+	switch v.(type) {
+	case *closure, *boundMethod:
+		return "lox.function"
+	case *native:
+		return "*lox.native"
+	case *class:
+		return "lox.class"
+	case metaClass:
+		return "lox.metaClass"
+	case metaType:
+		return "lox.metaType"
+	case *instance:
+		return "*lox.instance"
+	case *list:
+		return "*lox.list"
+	case *dict:
+		return "*lox.dict"
+	case *module:
+		return "*lox.module"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func isTruthy(v any) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// genericFunctionType returns the type of a function with arity params, whose
+// params and result may have any type.
+func genericFunctionType(arity int) lox.FunctionType {
+	params := make([]lox.Type, arity)
+	for i := 0; i < arity; i++ {
+		params[i] = &lox.RefType{ID: i + 1}
+	}
+	return lox.FunctionType{
+		Params: params,
+		Return: &lox.RefType{ID: arity + 1},
+	}
+}
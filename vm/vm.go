@@ -0,0 +1,647 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/brunokim/kilox"
+)
+
+// Maximum number of nested calls before reporting a stack overflow.
+const maxFrames = 1 << 16
+
+type runtimeError struct {
+	token lox.Token
+	msg   string
+}
+
+func (err runtimeError) Error() string {
+	return fmt.Sprintf("token '%s' in line %d: %s", err.token.Lexeme, err.token.Line, err.msg)
+}
+
+func (err runtimeError) Span() lox.Span {
+	return err.token.Span
+}
+
+func (err runtimeError) Diagnostic() lox.Diagnostic {
+	return lox.Diagnostic{
+		Severity: lox.SeverityError,
+		Phase:    lox.RuntimePhase,
+		Code:     "runtime-error",
+		Message:  err.msg,
+		Span:     err.token.Span,
+	}
+}
+
+type callFrame struct {
+	closure *closure
+	ip      int
+	// Position in the stack of the frame's slot 0.
+	base int
+}
+
+// VM is a stack-based virtual machine that executes compiled bytecode.
+//
+// Global variables persist across executions.
+type VM struct {
+	stack  []any
+	frames []callFrame
+	// Globals of the main script. Modules have their own globals, and all of them may
+	// refer to natives.
+	globals map[string]any
+	natives map[string]*native
+	// Directories where modules are looked up, and modules already loaded by path.
+	searchPath []string
+	modules    map[string]*module
+	// Modules being loaded, from the outermost to the innermost import.
+	importing []moduleImport
+	stdout    io.Writer
+	rand      *rand.Rand
+	clock     func() float64
+	// Open upvalues, referring to slots that are still in the stack.
+	openUpvalues []*upvalue
+	// Exception handlers installed by 'try' statements, from outermost to innermost.
+	handlers []handler
+	// Offset of the instruction being executed in the current frame.
+	opStart int
+}
+
+func New() *VM {
+	vm := &VM{
+		globals: make(map[string]any),
+		natives: make(map[string]*native),
+		modules: make(map[string]*module),
+		stdout:  os.Stdout,
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		clock:   lox.SystemClock,
+	}
+	for _, n := range builtins(vm) {
+		vm.natives[n.name] = n
+	}
+	return vm
+}
+
+func (vm *VM) SetStdout(w io.Writer) {
+	vm.stdout = w
+}
+
+// SetRandSource sets the source of random numbers for the 'random' builtin.
+func (vm *VM) SetRandSource(src rand.Source) {
+	vm.rand = rand.New(src)
+}
+
+// SetClock sets the function returning the time for the 'clock' builtin, in seconds.
+func (vm *VM) SetClock(clock func() float64) {
+	vm.clock = clock
+}
+
+// DefineNative binds a Go function with a fixed number of params to a global name.
+func (vm *VM) DefineNative(name string, arity int, fn lox.NativeFunc) {
+	vm.natives[name] = &native{name: name, arity: arity, fn: fn}
+}
+
+// DefineVariadicNative binds a Go function accepting at least minArity arguments to a global name.
+func (vm *VM) DefineVariadicNative(name string, minArity int, fn lox.NativeFunc) {
+	vm.natives[name] = &native{name: name, arity: minArity, variadic: true, fn: fn}
+}
+
+// Interpret compiles and runs stmts, that must have been resolved by lox.Resolver
+// with res.
+func (vm *VM) Interpret(stmts []lox.Stmt, res Resolution) error {
+	fn, err := NewCompiler(res).Compile(stmts)
+	if err != nil {
+		return err
+	}
+	return vm.Run(fn)
+}
+
+// Run executes a top-level function returned by the compiler.
+func (vm *VM) Run(fn *Function) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch signal := r.(type) {
+			case runtimeError:
+				err = signal
+			case throwSignal:
+				err = uncaughtError(signal)
+			default:
+				panic(r)
+			}
+			vm.reset()
+		}
+	}()
+	cl := &closure{fn: fn, globals: vm.globals}
+	vm.push(cl)
+	vm.call(cl, 0)
+	vm.run(0)
+	return nil
+}
+
+func (vm *VM) reset() {
+	vm.stack = nil
+	vm.frames = nil
+	vm.openUpvalues = nil
+	vm.handlers = nil
+	vm.importing = nil
+}
+
+// ---- stack
+
+func (vm *VM) push(v any) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *VM) pop() any {
+	n := len(vm.stack)
+	v := vm.stack[n-1]
+	vm.stack = vm.stack[:n-1]
+	return v
+}
+
+func (vm *VM) peek(distance int) any {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+func (vm *VM) errorf(format string, args ...any) {
+	frame := &vm.frames[len(vm.frames)-1]
+	token := frame.closure.fn.Chunk.Tokens[vm.opStart]
+	panic(runtimeError{token, fmt.Sprintf(format, args...)})
+}
+
+// ---- execution
+
+// run executes instructions until the number of frames drops to depth.
+func (vm *VM) run(depth int) {
+	for !vm.execute(depth) {
+	}
+}
+
+// execute runs instructions until the number of frames drops to depth, returning true. If
+// an exception is caught by a handler, it returns false so that execution resumes from there.
+func (vm *VM) execute(depth int) (done bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !vm.catch(r) {
+				panic(r)
+			}
+		}
+	}()
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := frame.closure.fn.Chunk
+	readByte := func() int {
+		b := chunk.Code[frame.ip]
+		frame.ip++
+		return int(b)
+	}
+	read16 := func() int {
+		x := chunk.read16(frame.ip)
+		frame.ip += 2
+		return int(x)
+	}
+	readString := func() string {
+		return chunk.Constants[read16()].(string)
+	}
+	for {
+		vm.opStart = frame.ip
+		switch op := OpCode(readByte()); op {
+		case OpConstant:
+			vm.push(chunk.Constants[read16()])
+		case OpNil:
+			vm.push(nil)
+		case OpTrue:
+			vm.push(true)
+		case OpFalse:
+			vm.push(false)
+		case OpPop:
+			vm.pop()
+		case OpGetLocal:
+			vm.push(vm.stack[frame.base+readByte()])
+		case OpSetLocal:
+			vm.stack[frame.base+readByte()] = vm.peek(0)
+		case OpGetGlobal:
+			name := readString()
+			value, ok := vm.getGlobal(frame.closure.globals, name)
+			if !ok {
+				vm.errorf("undefined variable %q", name)
+			}
+			vm.push(value)
+		case OpDefineGlobal:
+			frame.closure.globals[readString()] = vm.pop()
+		case OpSetGlobal:
+			name := readString()
+			if _, ok := vm.getGlobal(frame.closure.globals, name); !ok {
+				vm.errorf("undefined variable %q", name)
+			}
+			frame.closure.globals[name] = vm.peek(0)
+		case OpGetUpvalue:
+			uv := frame.closure.upvalues[readByte()]
+			if uv.isOpen {
+				vm.push(vm.stack[uv.slot])
+			} else {
+				vm.push(uv.closed)
+			}
+		case OpSetUpvalue:
+			uv := frame.closure.upvalues[readByte()]
+			if uv.isOpen {
+				vm.stack[uv.slot] = vm.peek(0)
+			} else {
+				uv.closed = vm.peek(0)
+			}
+		case OpGetProperty:
+			name := readString()
+			vm.push(vm.getProperty(vm.pop(), name))
+		case OpSetProperty:
+			name := readString()
+			value := vm.pop()
+			vm.setProperty(vm.pop(), name, value)
+			vm.push(value)
+		case OpGetSuper:
+			name := readString()
+			superclass := vm.pop().(*class)
+			this := vm.pop()
+			var method *closure
+			var ok bool
+			if _, isClass := this.(*class); isClass {
+				// Within a static method, 'super' refers to the superclass' static methods.
+				method, ok = superclass.findStaticMethod(name)
+			} else {
+				method, ok = superclass.findMethod(name)
+			}
+			if !ok {
+				vm.errorf("undefined method in superclass %s", superclass)
+			}
+			vm.push(&boundMethod{this, method})
+		case OpGetIndex:
+			index := vm.pop()
+			vm.push(vm.getIndex(vm.pop(), index))
+		case OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			vm.setIndex(vm.pop(), index, value)
+			vm.push(value)
+		case OpEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(a == b)
+		case OpNotEqual:
+			b, a := vm.pop(), vm.pop()
+			vm.push(a != b)
+		case OpGreater:
+			a, b := vm.numberOperands()
+			vm.push(a > b)
+		case OpGreaterEqual:
+			a, b := vm.numberOperands()
+			vm.push(a >= b)
+		case OpLess:
+			a, b := vm.numberOperands()
+			vm.push(a < b)
+		case OpLessEqual:
+			a, b := vm.numberOperands()
+			vm.push(a <= b)
+		case OpAdd:
+			b, a := vm.pop(), vm.pop()
+			aNum, ok1 := a.(float64)
+			bNum, ok2 := b.(float64)
+			if ok1 && ok2 {
+				vm.push(aNum + bNum)
+				break
+			}
+			aStr, ok3 := a.(string)
+			bStr, ok4 := b.(string)
+			if ok3 && ok4 {
+				vm.push(aStr + bStr)
+				break
+			}
+			vm.errorf("operands must be two numbers or two strings")
+		case OpSubtract:
+			a, b := vm.numberOperands()
+			vm.push(a - b)
+		case OpMultiply:
+			a, b := vm.numberOperands()
+			vm.push(a * b)
+		case OpDivide:
+			a, b := vm.numberOperands()
+			vm.push(a / b)
+		case OpNot:
+			vm.push(!isTruthy(vm.pop()))
+		case OpNegate:
+			num, ok := vm.pop().(float64)
+			if !ok {
+				vm.errorf("operand must be number")
+			}
+			vm.push(-num)
+		case OpPrint:
+			v := vm.pop()
+			if v == nil {
+				v = "nil"
+			}
+			fmt.Fprintln(vm.stdout, v)
+		case OpJump:
+			jump := read16()
+			frame.ip += jump
+		case OpJumpIfFalse:
+			jump := read16()
+			if !isTruthy(vm.peek(0)) {
+				frame.ip += jump
+			}
+		case OpLoop:
+			jump := read16()
+			frame.ip -= jump
+		case OpCall:
+			argc := readByte()
+			vm.callValue(vm.peek(argc), argc)
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = frame.closure.fn.Chunk
+		case OpClosure:
+			fn := chunk.Constants[read16()].(*Function)
+			cl := &closure{
+				fn:       fn,
+				upvalues: make([]*upvalue, fn.upvalueCount),
+				globals:  frame.closure.globals,
+			}
+			for i := range cl.upvalues {
+				isLocal := readByte()
+				index := readByte()
+				if isLocal == 1 {
+					cl.upvalues[i] = vm.captureUpvalue(frame.base + index)
+				} else {
+					cl.upvalues[i] = frame.closure.upvalues[index]
+				}
+			}
+			vm.push(cl)
+		case OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+		case OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.stack = vm.stack[:frame.base]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == depth {
+				return true
+			}
+			vm.push(result)
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = frame.closure.fn.Chunk
+		case OpList:
+			n := read16()
+			elems := make([]any, n)
+			copy(elems, vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(&list{elems})
+		case OpMap:
+			n := read16()
+			entries := vm.stack[len(vm.stack)-2*n:]
+			d := newDict()
+			for i := 0; i < n; i++ {
+				key, err := hashKey(entries[2*i])
+				if err != nil {
+					vm.errorf("%v", err)
+				}
+				d.entries.Put(key, entries[2*i+1])
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*n]
+			vm.push(d)
+		case OpClass:
+			vm.push(newClass(readString()))
+		case OpInherit:
+			superclass, ok := vm.peek(0).(*class)
+			if !ok {
+				value := vm.peek(0)
+				vm.errorf("superclass must be a class, got %s (%v)", typeName(value), value)
+			}
+			cl := vm.peek(1).(*class)
+			// Field initializers from the superclass are run before the ones declared in this class.
+			cl.superclass = superclass
+			cl.fieldInits = append(cl.fieldInits, superclass.fieldInits...)
+		case OpMethod:
+			name := readString()
+			method := vm.pop().(*closure)
+			vm.peek(0).(*class).methods[name] = method
+		case OpStaticMethod:
+			name := readString()
+			method := vm.pop().(*closure)
+			vm.peek(0).(*class).staticMethods[name] = method
+		case OpStaticVar:
+			name := readString()
+			value := vm.pop()
+			vm.peek(0).(*class).static[name] = value
+		case OpField:
+			name := readString()
+			value := vm.pop()
+			cl := vm.peek(0).(*class)
+			cl.fieldInits = append(cl.fieldInits, fieldInitializer{name, value})
+		case OpImport:
+			name := readString()
+			m := vm.importModule(name, chunk.Tokens[vm.opStart])
+			vm.push(m)
+			// Frames may have been reallocated while running the module.
+			frame = &vm.frames[len(vm.frames)-1]
+		case OpTry, OpTryFinally:
+			jump := read16()
+			vm.handlers = append(vm.handlers, handler{
+				frameCount: len(vm.frames),
+				stackSize:  len(vm.stack),
+				ip:         frame.ip + jump,
+				isFinally:  op == OpTryFinally,
+			})
+		case OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case OpThrow:
+			keyword := chunk.Tokens[vm.opStart]
+			panic(throwSignal{keyword, vm.pop()})
+		case OpRethrow:
+			panic(vm.pop().(*pendingException).signal)
+		default:
+			panic(fmt.Errorf("vm error: unknown opcode %v", op))
+		}
+	}
+}
+
+// getGlobal returns the value of a global variable, or of a native if there's no variable
+// with the name.
+func (vm *VM) getGlobal(globals map[string]any, name string) (any, bool) {
+	if value, ok := globals[name]; ok {
+		return value, true
+	}
+	n, ok := vm.natives[name]
+	return n, ok
+}
+
+func (vm *VM) numberOperands() (float64, float64) {
+	b, a := vm.pop(), vm.pop()
+	aNum, ok1 := a.(float64)
+	bNum, ok2 := b.(float64)
+	if !ok1 || !ok2 {
+		vm.errorf("operands must be numbers")
+	}
+	return aNum, bNum
+}
+
+// ---- calls
+
+func (vm *VM) callValue(callee any, argc int) {
+	switch c := callee.(type) {
+	case *closure:
+		vm.call(c, argc)
+	case *boundMethod:
+		vm.stack[len(vm.stack)-argc-1] = c.receiver
+		vm.call(c.method, argc)
+	case *class:
+		vm.stack[len(vm.stack)-argc-1] = newInstance(c)
+		if init, ok := c.findMethod("init"); ok {
+			vm.call(init, argc)
+		} else if argc != 0 {
+			vm.errorf("expecting 0 arguments but got %d", argc)
+		}
+	case *native:
+		vm.callNative(c, argc)
+	default:
+		vm.errorf("value %v (%s) is not callable", callee, typeName(callee))
+	}
+}
+
+func (vm *VM) call(cl *closure, argc int) {
+	if argc != cl.fn.Arity {
+		vm.errorf("expecting %d arguments but got %d", cl.fn.Arity, argc)
+	}
+	if len(vm.frames) >= maxFrames {
+		vm.errorf("stack overflow")
+	}
+	vm.frames = append(vm.frames, callFrame{
+		closure: cl,
+		base:    len(vm.stack) - argc - 1,
+	})
+}
+
+func (vm *VM) callNative(n *native, argc int) {
+	if n.variadic && argc < n.arity {
+		vm.errorf("expecting at least %d arguments but got %d", n.arity, argc)
+	}
+	if !n.variadic && argc != n.arity {
+		vm.errorf("expecting %d arguments but got %d", n.arity, argc)
+	}
+	args := make([]any, argc)
+	copy(args, vm.stack[len(vm.stack)-argc:])
+	result, err := n.fn(args)
+	if err != nil {
+		vm.errorf("%s: %v", n.name, err)
+	}
+	vm.stack = vm.stack[:len(vm.stack)-argc-1]
+	vm.push(result)
+}
+
+// ---- upvalues
+
+func (vm *VM) captureUpvalue(slot int) *upvalue {
+	for _, uv := range vm.openUpvalues {
+		if uv.slot == slot {
+			return uv
+		}
+	}
+	uv := &upvalue{slot: slot, isOpen: true}
+	vm.openUpvalues = append(vm.openUpvalues, uv)
+	return uv
+}
+
+// closeUpvalues closes all open upvalues referring to slots at or above last.
+func (vm *VM) closeUpvalues(last int) {
+	open := vm.openUpvalues[:0]
+	for _, uv := range vm.openUpvalues {
+		if uv.slot >= last {
+			uv.closed = vm.stack[uv.slot]
+			uv.isOpen = false
+		} else {
+			open = append(open, uv)
+		}
+	}
+	vm.openUpvalues = open
+}
+
+// ---- objects
+
+func (vm *VM) getProperty(obj any, name string) any {
+	switch o := obj.(type) {
+	case *instance:
+		if v, ok := o.fields[name]; ok {
+			return v
+		}
+		if m, ok := o.class.findMethod(name); ok {
+			return &boundMethod{o, m}
+		}
+	case *class:
+		if v, ok := o.static[name]; ok {
+			return v
+		}
+		if m, ok := o.findStaticMethod(name); ok {
+			return &boundMethod{o, m}
+		}
+	case *module:
+		if v, ok := o.globals[name]; ok {
+			return v
+		}
+		vm.errorf("undefined member %q in %v", name, o)
+	default:
+		vm.errorf("want an object for property access, got %s (%v)", typeName(obj), obj)
+	}
+	vm.errorf("undefined property in %s", obj)
+	return nil
+}
+
+func (vm *VM) setProperty(obj any, name string, value any) {
+	switch o := obj.(type) {
+	case *instance:
+		o.fields[name] = value
+	case *class:
+		o.static[name] = value
+	case *module:
+		vm.errorf("can't assign to member %q of %v", name, o)
+	default:
+		vm.errorf("want an object for field access, got %s (%v)", typeName(obj), obj)
+	}
+}
+
+func (vm *VM) getIndex(obj, index any) any {
+	switch o := obj.(type) {
+	case *list:
+		idx, err := o.checkIndex(index)
+		if err != nil {
+			vm.errorf("%v", err)
+		}
+		return o.elements[idx]
+	case *dict:
+		key, err := hashKey(index)
+		if err != nil {
+			vm.errorf("%v", err)
+		}
+		value, ok := o.entries.Get(key)
+		if !ok {
+			vm.errorf("key %s not found in map", repr(index))
+		}
+		return value
+	default:
+		vm.errorf("want a list or map for index access, got %s (%v)", typeName(obj), obj)
+		return nil
+	}
+}
+
+func (vm *VM) setIndex(obj, index, value any) {
+	switch o := obj.(type) {
+	case *list:
+		idx, err := o.checkIndex(index)
+		if err != nil {
+			vm.errorf("%v", err)
+		}
+		o.elements[idx] = value
+	case *dict:
+		key, err := hashKey(index)
+		if err != nil {
+			vm.errorf("%v", err)
+		}
+		o.entries.Put(key, value)
+	default:
+		vm.errorf("want a list or map for index assignment, got %s (%v)", typeName(obj), obj)
+	}
+}
@@ -0,0 +1,132 @@
+package vm_test
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/brunokim/kilox"
+	"github.com/brunokim/kilox/internal/loxtest"
+	"github.com/brunokim/kilox/typing"
+	"github.com/brunokim/kilox/vm"
+)
+
+// Seed used by tests with the 'deterministic' experiment, the same as in the lox package tests.
+const testSeed = 42
+
+func runLox(text string, experiments map[string]bool) (string, error) {
+	s := lox.NewScanner(text)
+	tokens, err := s.ScanTokens()
+	if err != nil {
+		return "", err
+	}
+	p := lox.NewParser(tokens)
+	stmts, err := p.Parse()
+	if err != nil {
+		return "", err
+	}
+	i := lox.NewInterpreter()
+	r := lox.NewResolver(i)
+	err = r.Resolve(stmts)
+	if err != nil {
+		return "", err
+	}
+	if experiments["typing"] {
+		c := typing.NewChecker()
+		_, err := c.Check(stmts)
+		if err != nil {
+			return "", err
+		}
+	}
+	var b strings.Builder
+	machine := vm.New()
+	machine.SetStdout(&b)
+	// Modules are imported with paths relative to the repository root.
+	machine.SetSearchPath("..")
+	if experiments["deterministic"] {
+		machine.SetClock(lox.FakeClock())
+		machine.SetRandSource(rand.NewSource(testSeed))
+	}
+	err = machine.Interpret(stmts, i)
+	return b.String(), err
+}
+
+func TestVM(t *testing.T) {
+	filenames, err := filepath.Glob("../testdata/*/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		testName := strings.TrimPrefix(filename, "../testdata/")
+		t.Run(testName, func(t *testing.T) {
+			bs, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			text := string(bs)
+			wantOutput := loxtest.ExtractComment(text, "output")
+			wantErr := loxtest.ExtractComment(text, "error")
+			experiments := loxtest.ExtractExperiments(text)
+			if _, ok := experiments["typing"]; !ok {
+				experiments["typing"] = true // Enable typing, if not specified.
+			}
+			if _, ok := experiments["vm"]; !ok {
+				experiments["vm"] = true // Enable VM, if not specified.
+			}
+			if !experiments["vm"] {
+				t.Skip("test disabled for the VM")
+			}
+			output, err := runLox(text, experiments)
+			errMsg := ""
+			if err != nil {
+				errMsg = err.Error() + "\n"
+			}
+			if diff := cmp.Diff(wantErr, errMsg); diff != "" {
+				t.Errorf("errors: (-want, +got)%s", diff)
+			}
+			if diff := cmp.Diff(wantOutput, output); diff != "" {
+				t.Errorf("(-want, +got)%s", diff)
+			}
+		})
+	}
+}
+
+func TestNatives(t *testing.T) {
+	text := `print double(21); print sum(1, 2, 3);`
+	s := lox.NewScanner(text)
+	tokens, err := s.ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts, err := lox.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := lox.NewInterpreter()
+	if err := lox.NewResolver(i).Resolve(stmts); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	machine := vm.New()
+	machine.SetStdout(&b)
+	machine.DefineNative("double", 1, func(args []any) (any, error) {
+		return 2 * args[0].(float64), nil
+	})
+	machine.DefineVariadicNative("sum", 0, func(args []any) (any, error) {
+		var total float64
+		for _, arg := range args {
+			total += arg.(float64)
+		}
+		return total, nil
+	})
+	if err := machine.Interpret(stmts, i); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("42\n6\n", b.String()); diff != "" {
+		t.Errorf("(-want, +got)%s", diff)
+	}
+}